@@ -21,7 +21,11 @@ type ArgsParser struct {
 	AVX           *bool
 	AVX256        *bool
 	//AVX512        *bool
-	Neon *bool
+	Neon         *bool
+	WalSync      *bool
+	CompactRatio *float64
+	SegmentSize  *int
+	PayloadCache *int
 }
 
 // Ap is a global ArgsParser
@@ -46,6 +50,10 @@ func init() {
 	Ap.AVX256 = flag.Bool("avx256", false, "Use AVX256")
 	// Ap.AVX512 = flag.Bool("avx512", false, "Use AVX512")
 	Ap.Neon = flag.Bool("neon", false, "Use Neon (ARM only)")
+	Ap.WalSync = flag.Bool("walsync", true, "Sync the write-ahead log to disk on every write")
+	Ap.CompactRatio = flag.Float64("compactratio", 0.5, "Compact a collection when this part of its data file is dead (0 disables)")
+	Ap.SegmentSize = flag.Int("segmentsize", 64, "The size of the preallocated segments of the data files in MB")
+	Ap.PayloadCache = flag.Int("payloadcache", 64, "The size of the cache of decoded payloads in MB (0 disables)")
 
 	// Parse
 	flag.Parse()
@@ -56,6 +64,16 @@ func init() {
 		panic("SearchThreads must be greater than 0")
 	}
 
+	// Check if SegmentSize is gt 0
+	if *Ap.SegmentSize <= 0 {
+		panic("SegmentSize must be greater than 0")
+	}
+
+	// Check if PayloadCache is not negative
+	if *Ap.PayloadCache < 0 {
+		panic("PayloadCache must not be negative")
+	}
+
 	// Check if Ap.FileStore ends with a slash
 	if (*Ap.FileStore)[len(*Ap.FileStore)-1] != '/' {
 		*Ap.FileStore += "/"
@@ -3,10 +3,13 @@ package Boot
 import (
 	"VreeDB/Collection"
 	"VreeDB/FileMapper"
+	"VreeDB/Ivf"
 	"VreeDB/Logger"
 	"VreeDB/Utils"
 	"VreeDB/Vector"
+	"VreeDB/Wal"
 	"encoding/json"
+	"fmt"
 	"os"
 	"strings"
 )
@@ -36,6 +39,9 @@ func (b *BootUp) RestoreCollections() map[string]*Collection.Collection {
 	}
 	for _, entry := range entries {
 		if strings.HasSuffix(entry.Name(), ".json") {
+			// Finish an interrupted compaction first - it may replace the config
+			FileMapper.Mapper.RecoverCompaction(strings.Split(entry.Name(), ".")[0])
+
 			// Open the file
 			file, err := os.Open("collections/" + entry.Name())
 			if err != nil {
@@ -51,16 +57,50 @@ func (b *BootUp) RestoreCollections() map[string]*Collection.Collection {
 				continue
 			}
 			// Enter the collection into the map
-			collections[strings.Split(entry.Name(), ".")[0]] = Collection.NewCollection(c.Name, c.VectorDimension, c.DistanceFuncName)
+			precision, err := Vector.ParsePrecision(c.Precision)
+			if err != nil {
+				Logger.Log.Log("Error restoring collection "+c.Name+": "+err.Error(), "ERROR")
+				continue
+			}
+			collection, err := Collection.NewCollection(c.Name, c.VectorDimension, c.DistanceFuncName, precision)
+			if err != nil {
+				Logger.Log.Log("Error restoring collection "+c.Name+": "+err.Error(), "ERROR")
+				continue
+			}
+			collections[strings.Split(entry.Name(), ".")[0]] = collection
+			if c.QuantizerMin != nil && c.QuantizerMax != nil {
+				collections[c.Name].Quantizer = Vector.NewQuantizer(c.QuantizerMin, c.QuantizerMax)
+			}
 
-			// Set the DiagonalLength
+			// Load the vector index of the collection - it is needed before the vectors are restored
+			err = collections[c.Name].SetVectorIndex(c.IndexType, c.IndexParams)
+			if err != nil {
+				Logger.Log.Log("Error restoring vector index of collection "+c.Name+": "+err.Error(), "ERROR")
+				delete(collections, c.Name)
+				continue
+			}
+
+			// Set the DiagonalLength and the vector format
 			collections[c.Name].DiagonalLength = c.DiagonalLength
+			collections[c.Name].FormatVersion = c.FormatVersion
+			collections[c.Name].SearchAccuracy, collections[c.Name].MaxChecks = c.SearchAccuracy, c.MaxChecks
+			collections[c.Name].AxisRange = c.AxisRange
+			collections[c.Name].Schema = c.Schema
 
 			// Create the collection in the Filemapper
-			FileMapper.Mapper.AddCollection(c.Name)
+			FileMapper.Mapper.AddCollection(c.Name, c.FormatVersion)
+
+			// Replay the write-ahead log - this will restore writes that did not reach the collection files
+			// If it fails the collection files may miss acknowledged writes - serving the collection without them or
+			// dropping it would both lose data, so the boot stops here
+			err = b.ReplayWal(collections[c.Name])
+			if err != nil {
+				Logger.Log.Log("Error replaying wal of collection "+c.Name+": "+err.Error(), "ERROR")
+				panic(fmt.Errorf("Error replaying wal of collection %s: %s - fix or remove the wal to boot", c.Name, err.Error()))
+			}
 
 			// Restore vectors (if any)
-			vectors, err := b.RestoreVectors(c.Name, collections[c.Name].VectorDimension)
+			vectors, err := b.RestoreVectors(collections[c.Name])
 			if err != nil {
 				Logger.Log.Log("Error restoring vectors: "+err.Error(), "ERROR")
 				continue
@@ -68,15 +108,38 @@ func (b *BootUp) RestoreCollections() map[string]*Collection.Collection {
 			// Set the vectors
 			collections[c.Name].Space = vectors
 
+			// Calculate the dead bytes of the data file
+			collections[c.Name].CalculateDeadBytes()
+
+			// Convert collections of older versions to the current vector format
+			if collections[c.Name].FormatVersion != collections[c.Name].CurrentFormat() {
+				err = collections[c.Name].UpgradeFormat()
+				if err != nil {
+					Logger.Log.Log("Error upgrading collection "+c.Name+": "+err.Error(), "ERROR")
+				}
+			}
+
 			// Recreate the KD-Tree
 			collections[c.Name].Recreate()
 
+			// Cluster an ivf index that was never trained
+			if ivf, ok := collections[c.Name].VectorIndex.(*Ivf.IVF); ok && !ivf.Trained() && len(*collections[c.Name].Space) > 0 {
+				err = collections[c.Name].TrainIndex()
+				if err != nil {
+					Logger.Log.Log("Error clustering ivf index of collection "+c.Name+": "+err.Error(), "ERROR")
+				}
+			}
+
 			// Set ClassifierReady
 			collections[c.Name].ClassifierReady = true
 
 			// Restore Indexes
 			err = collections[c.Name].RebuildIndex()
-			Logger.Log.Log("Collection "+c.Name+" indexes restored", "INFO")
+			if err != nil {
+				Logger.Log.Log("Error restoring indexes of collection "+c.Name+": "+err.Error(), "ERROR")
+			} else {
+				Logger.Log.Log("Collection "+c.Name+" indexes restored", "INFO")
+			}
 
 			// recreate the SVMs (if present)
 			err = collections[c.Name].ReadClassifiers()
@@ -103,7 +166,8 @@ func (b *BootUp) RestoreCollections() map[string]*Collection.Collection {
 // The restored vectors are also unindexed and their properties,
 // such as Collection, DataStart, PayloadStart, Length, and SaveVectorPosition,
 // are set based on the read data.
-func (b *BootUp) RestoreVectors(collection string, dimension int) (*map[string]*Vector.Vector, error) {
+func (b *BootUp) RestoreVectors(c *Collection.Collection) (*map[string]*Vector.Vector, error) {
+	collection, dimension := c.Name, c.VectorDimension
 	vectors := make(map[string]*Vector.Vector)
 	m, err := FileMapper.Mapper.SaveVectorRead(collection)
 	if err != nil {
@@ -122,7 +186,167 @@ func (b *BootUp) RestoreVectors(collection string, dimension int) (*map[string]*
 		vectors[v.VectorID].PayloadStart = v.PayloadStart
 		vectors[v.VectorID].Length = dimension
 		vectors[v.VectorID].SaveVectorPosition = v.SaveVectorPosition
-		vectors[v.VectorID].Unindex()
+		vectors[v.VectorID].CLength = int(v.DataLength)
+		vectors[v.VectorID].PLength = int(v.PayloadLength)
+		c.PrepareVector(vectors[v.VectorID])
+		// Collections that keep only codes of their vectors read the data from the file when they need it
+		if c.KeepsData() {
+			vectors[v.VectorID].Unindex()
+		} else {
+			vectors[v.VectorID].Release()
+		}
 	}
 	return &vectors, nil
 }
+
+// replayPayload writes the payload of a logged vector to the collection files and saves it with the data of the vector
+// to the meta file
+func replayPayload(collection string, m *map[string]FileMapper.SaveVector, sv FileMapper.SaveVector, record *Wal.Record) error {
+	ps, plen, err := FileMapper.Mapper.WritePayload(&record.Payload, collection)
+	if err != nil {
+		return err
+	}
+	pos, err := FileMapper.Mapper.SaveVectorWriter(sv.VectorID, sv.DataStart, ps, int(sv.DataLength), plen, collection)
+	if err != nil {
+		return err
+	}
+	sv.PayloadStart, sv.PayloadLength, sv.SaveVectorPosition = ps, int64(plen), pos
+	(*m)[sv.VectorID] = sv
+	return nil
+}
+
+// replayWrite writes the data and the payload of a logged vector to the collection files and saves it to the meta file
+func replayWrite(collection string, m *map[string]FileMapper.SaveVector, id string, record *Wal.Record) error {
+	ds, clen, err := FileMapper.Mapper.WriteVector(record.Data, collection)
+	if err != nil {
+		return err
+	}
+	ps, plen, err := FileMapper.Mapper.WritePayload(&record.Payload, collection)
+	if err != nil {
+		return err
+	}
+	pos, err := FileMapper.Mapper.SaveVectorWriter(id, ds, ps, clen, plen, collection)
+	if err != nil {
+		return err
+	}
+	(*m)[id] = FileMapper.SaveVector{VectorID: id, DataStart: ds, PayloadStart: ps, SaveVectorPosition: pos,
+		DataLength: int64(clen), PayloadLength: int64(plen)}
+	return nil
+}
+
+// ReplayWal replays the write-ahead log of a collection against its _meta.bin file.
+// Inserts that are not referenced in the meta file are written again, deletes of vectors that are
+// still alive in the meta file are applied again. When all records are applied the collection
+// files are synced and the log is truncated.
+func (b *BootUp) ReplayWal(collection *Collection.Collection) error {
+	m, err := FileMapper.Mapper.SaveVectorRead(collection.Name)
+	if err != nil {
+		return err
+	}
+
+	// First pass: find the last insert (or upsert) and delete of every vector, so inserts that are deleted later in
+	// the log and deletes that are followed by a new insert are not applied again. The last write of the data is
+	// tracked on its own, a payload record is applied on top of it.
+	lastInsert := make(map[string]uint64)
+	lastData := make(map[string]uint64)
+	lastDelete := make(map[string]uint64)
+	err = collection.Wal.Replay(func(record *Wal.Record) error {
+		for _, id := range record.IDs {
+			switch record.Op {
+			case Wal.Insert, Wal.Upsert:
+				lastInsert[id] = record.Seq
+				lastData[id] = record.Seq
+			case Wal.Payload:
+				lastInsert[id] = record.Seq
+			case Wal.Delete:
+				lastDelete[id] = record.Seq
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	replayed := 0
+	err = collection.Wal.Replay(func(record *Wal.Record) error {
+		switch record.Op {
+		case Wal.Insert:
+			id := record.IDs[0]
+			// If the vector is alive in the meta file the insert was applied, if it is deleted later we can skip it
+			if sv, ok := (*m)[id]; (ok && sv.DataStart >= 0) || lastDelete[id] > record.Seq {
+				return nil
+			}
+			err := replayWrite(collection.Name, m, id, record)
+			if err != nil {
+				return err
+			}
+			replayed++
+		case Wal.Upsert:
+			id := record.IDs[0]
+			// We cannot tell if the upsert was applied - the last write of the data is applied again
+			if lastData[id] != record.Seq || lastDelete[id] > record.Seq {
+				return nil
+			}
+			if sv, ok := (*m)[id]; ok && sv.DataStart >= 0 {
+				err := FileMapper.Mapper.SaveVectorWriteAt(-1, -1, collection.Name, sv.SaveVectorPosition)
+				if err != nil {
+					return err
+				}
+			}
+			err := replayWrite(collection.Name, m, id, record)
+			if err != nil {
+				return err
+			}
+			replayed++
+		case Wal.Payload:
+			id := record.IDs[0]
+			// The last payload is written again and keeps the data the vector has in the meta file
+			if lastInsert[id] != record.Seq || lastDelete[id] > record.Seq {
+				return nil
+			}
+			sv, ok := (*m)[id]
+			if !ok || sv.DataStart < 0 {
+				Logger.Log.Log(fmt.Sprintf("Collection %s: payload of missing vector %s not replayed", collection.Name, id), "WARNING")
+				return nil
+			}
+			err := FileMapper.Mapper.SaveVectorWriteAt(-1, -1, collection.Name, sv.SaveVectorPosition)
+			if err != nil {
+				return err
+			}
+			err = replayPayload(collection.Name, m, sv, record)
+			if err != nil {
+				return err
+			}
+			replayed++
+		case Wal.Delete:
+			for _, id := range record.IDs {
+				// Only vectors that are still alive need to be deleted
+				sv, ok := (*m)[id]
+				if !ok || sv.DataStart < 0 || lastInsert[id] > record.Seq {
+					continue
+				}
+				err := FileMapper.Mapper.SaveVectorWriteAt(-1, -1, collection.Name, sv.SaveVectorPosition)
+				if err != nil {
+					return err
+				}
+				delete(*m, id)
+				replayed++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if replayed > 0 {
+		Logger.Log.Log(fmt.Sprintf("Collection %s: %d operations replayed from wal", collection.Name, replayed), "INFO")
+	}
+
+	// Everything is applied - make sure it is on disk before the log is truncated
+	err = FileMapper.Mapper.Sync(collection.Name)
+	if err != nil {
+		return err
+	}
+	return collection.Wal.Checkpoint()
+}
@@ -2,25 +2,56 @@ package Collection
 
 import (
 	"VreeDB/FileMapper"
+	"VreeDB/Filter"
 	"VreeDB/Node"
 	"VreeDB/Vector"
 	"fmt"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
 )
 
+// Types of a payload Index
+const (
+	ValueIndex = "value" // a sub kd tree per value and the sorted values
+	RangeIndex = "range" // only the sorted values, for fields with many different values
+)
+
 // Index is the type to index specific vector payloads
 type Index struct {
 	// Indexes are sub kd trees
-	Entries        map[any]*Node.Node
+	Entries map[any]*Node.Node
+	// Sorted holds the values of all indexed vectors for range queries, it is sorted on the first query after an add
+	Sorted         []sortValue
+	sorted         bool
 	CollectionName string
 	Key            string
+	Type           string
 	mut            *sync.RWMutex
 }
 
+// CheckIndexType returns an error if the type is not a type of a payload Index - an empty type is a value Index
+func CheckIndexType(indexType string) error {
+	switch indexType {
+	case "", ValueIndex, RangeIndex:
+		return nil
+	}
+	return fmt.Errorf("Invalid index type %s, must be %s or %s", indexType, ValueIndex, RangeIndex)
+}
+
 // NewIndex returns a new Index
-func NewIndex(payloadkey string, space *map[string]*Vector.Vector, collection string) (*Index, error) {
+func NewIndex(payloadkey, indexType string, space *map[string]*Vector.Vector, collection string) (*Index, error) {
+	if err := CheckIndexType(indexType); err != nil {
+		return nil, err
+	}
+	if indexType == "" {
+		indexType = ValueIndex
+	}
+
 	// Create the Indexstruct
-	index := &Index{Entries: make(map[any]*Node.Node), CollectionName: collection, Key: payloadkey, mut: &sync.RWMutex{}}
+	index := &Index{Entries: make(map[any]*Node.Node), CollectionName: collection, Key: payloadkey, Type: indexType,
+		mut: &sync.RWMutex{}}
 
 	// Create a vectorMap as starting point to create the subtrees
 	vectorMap, err := index.getVectorFromPayloadIndex(payloadkey, space)
@@ -31,7 +62,11 @@ func NewIndex(payloadkey string, space *map[string]*Vector.Vector, collection st
 	}
 
 	// Build the subtrees
-	for _, vectors := range *vectorMap {
+	for value, vectors := range *vectorMap {
+		index.addSorted(value, vectors...)
+		if index.Type == RangeIndex {
+			continue
+		}
 		// Create a new Node
 		n := &Node.Node{Depth: 0}
 		// Insert the vectors into the Node
@@ -39,21 +74,30 @@ func NewIndex(payloadkey string, space *map[string]*Vector.Vector, collection st
 			n.Insert(vector)
 		}
 
-		// Get the payload from the hdd
-		payload, err := FileMapper.Mapper.ReadPayload(vectors[0].PayloadStart, collection)
-		if err != nil {
-			return nil, err
-		}
-
 		// Insert the Node into the Index
-		switch v := (*payload)[payloadkey].(type) {
+		index.Entries[value] = n
+	}
+	return index, nil
+}
+
+// indexValues returns the values of the payload key that a vector is indexed with. The key can be a field path, the
+// elements of array fields are indexed one by one.
+func indexValues(payload *map[string]interface{}, payloadkey string) ([]any, error) {
+	var values []any
+	seen := make(map[any]bool)
+	for _, value := range Filter.Values(*payload, payloadkey) {
+		// only string, int and float64 are allowed
+		switch value.(type) {
 		case int, float64, string:
-			index.Entries[v] = n
 		default:
 			return nil, fmt.Errorf("only string, float64 and int are allowed")
 		}
+		if !seen[value] {
+			seen[value] = true
+			values = append(values, value)
+		}
 	}
-	return index, nil
+	return values, nil
 }
 
 // getVectorFromPayloadIndex returns a map for a specific payload
@@ -70,21 +114,14 @@ func (i *Index) getVectorFromPayloadIndex(payloadkey string, space *map[string]*
 			return nil, err
 		}
 
-		// Check if key is in the Payload
-		if _, ok := (*payload)[payloadkey]; ok {
-			// only string, int and float64 are allowed
-			switch v := (*payload)[payloadkey].(type) {
-			case int, float64, string:
-				if _, ok := vectorMap[v]; !ok {
-					vectorMap[v] = []*Vector.Vector{}
-				}
-				// Add to the vectorMap
-				vectorMap[v] = append(vectorMap[v], vector)
-			default:
-				return nil, fmt.Errorf("only string, float64 and int are allowed")
-			}
-		} else {
-			continue
+		// Get the values of the key in the Payload
+		values, err := indexValues(payload, payloadkey)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range values {
+			// Add to the vectorMap
+			vectorMap[v] = append(vectorMap[v], vector)
 		}
 	}
 	return &vectorMap, nil
@@ -98,14 +135,185 @@ func (i *Index) AddToIndex(vector *Vector.Vector) error {
 	if err != nil {
 		return err
 	}
+	return i.add(vector, payload)
+}
 
-	// Check if the key is in the Payload
-	if _, ok := i.Entries[(*payload)[i.Key]]; !ok {
-		// Add the key to the Index
-		i.Entries[(*payload)[i.Key]] = &Node.Node{Depth: 0}
+// add adds a vector with the payload to the Index - the caller must hold the write lock of the Index
+func (i *Index) add(vector *Vector.Vector, payload *map[string]interface{}) error {
+	// Get the values of the key in the Payload
+	values, err := indexValues(payload, i.Key)
+	if err != nil {
+		return err
 	}
+	for _, value := range values {
+		i.addSorted(value, vector)
+		if i.Type == RangeIndex {
+			continue
+		}
+		// Check if the value is in the Index
+		if _, ok := i.Entries[value]; !ok {
+			// Add the value to the Index
+			i.Entries[value] = &Node.Node{Depth: 0}
+		}
 
-	// add it to the Node
-	i.Entries[(*vector.Payload)[i.Key]].Insert(vector)
+		// add it to the Node
+		i.Entries[value].Insert(vector)
+	}
 	return nil
 }
+
+// remove removes a vector with the payload from the Index, the sub kd trees of its values are built again without
+// it - the caller must hold the write lock of the Index
+func (i *Index) remove(vector *Vector.Vector, payload *map[string]interface{}) error {
+	values, err := indexValues(payload, i.Key)
+	if err != nil {
+		return err
+	}
+	i.sortValues()
+	for _, value := range values {
+		// Remove the vector from the sorted values of the value
+		if key, ok := newSortValue(vector, value); ok {
+			j := sort.Search(len(i.Sorted), func(j int) bool {
+				return i.Sorted[j].compare(&key) >= 0
+			})
+			for j < len(i.Sorted) && i.Sorted[j].compare(&key) == 0 {
+				if i.Sorted[j].vector == vector {
+					i.Sorted = slices.Delete(i.Sorted, j, j+1)
+					continue
+				}
+				j++
+			}
+		}
+
+		// Build the sub kd tree of the value without the vector
+		node, ok := i.Entries[value]
+		if !ok {
+			continue
+		}
+		rebuilt := &Node.Node{Depth: 0}
+		node.Walk(func(v *Vector.Vector) {
+			if v != vector {
+				rebuilt.Insert(v)
+			}
+		})
+		if rebuilt.Vector == nil {
+			delete(i.Entries, value)
+		} else {
+			i.Entries[value] = rebuilt
+		}
+	}
+	return nil
+}
+
+// addSorted adds the vectors with the value to the sorted values
+func (i *Index) addSorted(value any, vectors ...*Vector.Vector) {
+	for _, vector := range vectors {
+		if sv, ok := newSortValue(vector, value); ok {
+			i.Sorted = append(i.Sorted, sv)
+			i.sorted = false
+		}
+	}
+}
+
+// Range calls fn for every vector with a value that can pass a condition of the operator on the value. It supports
+// eq, gt, ge, lt, le, between and prefix and returns false for other operators and values. Deleted vectors are not
+// removed from the Index, fn may be called for them and more than once for vectors with many values.
+func (i *Index) Range(op Filter.Operator, value any, fn func(*Vector.Vector)) bool {
+	i.mut.Lock()
+	defer i.mut.Unlock()
+	low, high, ok := i.bounds(op, value)
+	if !ok {
+		return false
+	}
+	for _, sv := range i.Sorted[low:high] {
+		fn(sv.vector)
+	}
+	return true
+}
+
+// RangeSize returns the number of values a Range query visits
+func (i *Index) RangeSize(op Filter.Operator, value any) (int, bool) {
+	i.mut.Lock()
+	defer i.mut.Unlock()
+	low, high, ok := i.bounds(op, value)
+	return high - low, ok
+}
+
+// bounds returns the part of the sorted values that can pass a condition of the operator on the value. The caller
+// must hold the write lock of the Index.
+func (i *Index) bounds(op Filter.Operator, value any) (int, int, bool) {
+	i.sortValues()
+
+	// first returns the position of the first value that is greater (or equal if orEqual) than the key
+	first := func(key *sortValue, orEqual bool) int {
+		return sort.Search(len(i.Sorted), func(j int) bool {
+			c := i.Sorted[j].compare(key)
+			return c > 0 || orEqual && c == 0
+		})
+	}
+	// ranks returns the part of the values that have the rank of the key, values of other ranks never compare
+	ranks := func(key *sortValue) (int, int) {
+		low := sort.Search(len(i.Sorted), func(j int) bool { return i.Sorted[j].rank >= key.rank })
+		high := sort.Search(len(i.Sorted), func(j int) bool { return i.Sorted[j].rank > key.rank })
+		return low, high
+	}
+
+	if op == Filter.Between {
+		bounds, ok := value.([]interface{})
+		if !ok || len(bounds) != 2 {
+			return 0, 0, false
+		}
+		low, okLow := newSortValue(nil, bounds[0])
+		high, okHigh := newSortValue(nil, bounds[1])
+		if !okLow || !okHigh || low.rank != high.rank {
+			return 0, 0, false
+		}
+		return first(&low, true), max(first(&low, true), first(&high, false)), true
+	}
+
+	key, ok := newSortValue(nil, value)
+	if !ok {
+		return 0, 0, false
+	}
+	rankLow, rankHigh := ranks(&key)
+	switch op {
+	case Filter.Equal:
+		return first(&key, true), first(&key, false), true
+	case Filter.GreaterThan:
+		return first(&key, false), rankHigh, true
+	case Filter.GreaterThanOrEqual:
+		return first(&key, true), rankHigh, true
+	case Filter.LessThan:
+		return rankLow, first(&key, true), true
+	case Filter.LessThanOrEqual:
+		return rankLow, first(&key, false), true
+	case Filter.Prefix:
+		// Datetimes are not sorted by their text, they could start with the prefix too
+		if key.rank != 2 || i.hasRank(1) {
+			return 0, 0, false
+		}
+		low := first(&key, true)
+		high := low + sort.Search(len(i.Sorted)-low, func(j int) bool {
+			sv := i.Sorted[low+j]
+			return sv.rank > 2 || !strings.HasPrefix(sv.text, key.text)
+		})
+		return low, high, true
+	}
+	return 0, 0, false
+}
+
+// sortValues sorts the values if values were added since the last sort - the caller must hold the write lock
+func (i *Index) sortValues() {
+	if !i.sorted {
+		sort.Slice(i.Sorted, func(a, b int) bool {
+			return i.Sorted[a].compare(&i.Sorted[b]) < 0
+		})
+		i.sorted = true
+	}
+}
+
+// hasRank returns true if one of the sorted values has the rank
+func (i *Index) hasRank(rank int) bool {
+	j := sort.Search(len(i.Sorted), func(j int) bool { return i.Sorted[j].rank >= rank })
+	return j < len(i.Sorted) && i.Sorted[j].rank == rank
+}
@@ -10,12 +10,15 @@ import (
 	"VreeDB/Svm"
 	"VreeDB/Utils"
 	"VreeDB/Vector"
+	"VreeDB/Wal"
+	"bytes"
 	"encoding/gob"
 	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -25,6 +28,8 @@ type Collection struct {
 	Nodes              *Node.Node
 	VectorDimension    int
 	DistanceFunc       func(*Vector.Vector, *Vector.Vector) (float64, error)
+	FullDistanceFunc   func(*Vector.Vector, *Vector.Vector) (float64, error)
+	BatchDistanceFunc  func(target, block, out []float64)
 	Mut                sync.RWMutex
 	Space              *map[string]*Vector.Vector
 	DeletedVectors     *map[string]*Vector.Vector
@@ -37,17 +42,37 @@ type Collection struct {
 	ClassifierReady    bool
 	Indexes            map[string]*Index
 	ClassifierTraining map[string]Classifier
+	Wal                *Wal.Wal
+	DeadBytes          int64
+	FormatVersion      int
+	Precision          Vector.Precision
+	Quantizer          *Vector.Quantizer
+	IndexType          string
+	IndexParams        map[string]int
+	VectorIndex        VectorIndex
+	SearchAccuracy     float64
+	MaxChecks          int
+	AxisRange          bool
+	Schema             []Utils.SchemaField
+	compacting         atomic.Bool
+	retraining         atomic.Bool // a retrain of the quantizer runs in the background
+	quantizerStale     atomic.Bool // the quantizer does not cover all vectors
 }
 
+// walCheckpointSize is the size of the write-ahead log after which it will be checkpointed
+const walCheckpointSize = 64 * 1024 * 1024
+
 // Interface for the Classifier
 type Classifier interface {
 	Predict([]float64) any
 }
 
-// NewCollection returns a new Collection
-func NewCollection(name string, vectorDimension int, distanceFuncName string) *Collection {
+// NewCollection returns a new Collection that keeps its vectors in memory with the given precision. It returns an
+// error if the write-ahead log of the Collection cannot be opened.
+func NewCollection(name string, vectorDimension int, distanceFuncName string, precision Vector.Precision) (*Collection, error) {
 	// Vars
-	var distanceFunc func(*Vector.Vector, *Vector.Vector) (float64, error)
+	var distanceFunc, fullDistanceFunc func(*Vector.Vector, *Vector.Vector) (float64, error)
+	var batchDistanceFunc func(target, block, out []float64)
 
 	// Create the max,min and diff vectors
 	ma := &Vector.Vector{Data: make([]float64, vectorDimension), Length: vectorDimension}
@@ -56,28 +81,42 @@ func NewCollection(name string, vectorDimension int, distanceFuncName string) *C
 
 	if strings.ToLower(distanceFuncName) == "euclid" {
 		if *ArgsParser.Ap.AVX256 {
-			distanceFunc = Utils.Utils.EuclideanDistanceAVX256
+			fullDistanceFunc = Utils.Utils.EuclideanDistanceAVX256
+			distanceFunc = lowPrecision(precision, fullDistanceFunc, Utils.Utils.EuclideanDistance32AVX256, Utils.Utils.EuclideanDistanceInt8AVX256)
 		} else {
-			distanceFunc = Utils.Utils.EuclideanDistance
+			fullDistanceFunc = Utils.Utils.EuclideanDistance
+			distanceFunc = lowPrecision(precision, fullDistanceFunc, Utils.Utils.EuclideanDistance32, Utils.Utils.EuclideanDistanceInt8)
 		}
+		batchDistanceFunc = batchKernel(Utils.Utils.EuclideanDistances, Utils.Utils.EuclideanDistancesAVX256, Utils.Utils.EuclideanDistancesNEON)
 	} else {
 		if *ArgsParser.Ap.AVX256 {
-			distanceFunc = Utils.Utils.CosineDistanceAVX256
+			fullDistanceFunc = Utils.Utils.CosineDistanceAVX256
+			distanceFunc = lowPrecision(precision, fullDistanceFunc, Utils.Utils.CosineDistance32AVX256, Utils.Utils.CosineDistanceInt8AVX256)
 		} else {
-			distanceFunc = Utils.Utils.CosineDistance
+			fullDistanceFunc = Utils.Utils.CosineDistance
+			distanceFunc = lowPrecision(precision, fullDistanceFunc, Utils.Utils.CosineDistance32, Utils.Utils.CosineDistanceInt8)
 		}
+		batchDistanceFunc = batchKernel(Utils.Utils.CosineDistances, Utils.Utils.CosineDistancesAVX256, Utils.Utils.CosineDistancesNEON)
 	}
 
 	// create the collection
-	col := &Collection{Name: name, VectorDimension: vectorDimension, Nodes: &Node.Node{Depth: 0}, DistanceFunc: distanceFunc, Space: &map[string]*Vector.Vector{},
+	col := &Collection{Name: name, VectorDimension: vectorDimension, Nodes: &Node.Node{Depth: 0}, DistanceFunc: distanceFunc,
+		FullDistanceFunc: fullDistanceFunc, BatchDistanceFunc: batchDistanceFunc, Precision: precision, Space: &map[string]*Vector.Vector{},
 		MaxVector: ma, MinVector: mi, DimensionDiff: dd, DistanceFuncName: distanceFuncName, Classifiers: make(map[string]Classifier),
 		ClassifierReady: false, ClassifierTraining: make(map[string]Classifier), Indexes: make(map[string]*Index),
-		DeletedVectors: &map[string]*Vector.Vector{}, Mut: sync.RWMutex{}}
+		DeletedVectors: &map[string]*Vector.Vector{}, Mut: sync.RWMutex{}, FormatVersion: FileMapper.CurrentFormat(precision == Vector.Float32), IndexType: KDTree}
+
+	// Open the write-ahead log - without it we cannot guarantee that acknowledged writes survive a crash
+	wal, err := Wal.NewWal(name)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening the wal of collection %s: %s", name, err.Error())
+	}
+	col.Wal = wal
 
 	// Start the DeleteWatcher - it will watch in the background for deleted vectors
 	go col.DeleteWatcher()
 
-	return col
+	return col, nil
 }
 
 // Insert inserts a vector into the collection
@@ -89,19 +128,35 @@ func (c *Collection) Insert(vector *Vector.Vector) error {
 	} else if c.CheckID(vector.Id) {
 		return fmt.Errorf("Vector with ID %s already exists", vector.Id)
 	}
+	c.PrepareVector(vector)
 
-	// Insert the vector into the KD-Tree
-	c.Nodes.Insert(vector)
+	// New vectors are logged and written to the collection files
+	if vector.SaveVectorPosition == -1 {
+		// Check the payload against the schema
+		if err := c.CheckPayload(vector.Payload); err != nil {
+			return fmt.Errorf("Vector with ID %s: %s", vector.Id, err.Error())
+		}
+		// Log the insert before it is applied
+		_, err := c.Wal.Append(Wal.Insert, []string{vector.Id}, vector.Data, vector.Payload)
+		if err != nil {
+			return err
+		}
+		// Write the data and the payload
+		err = vector.Persist()
+		if err != nil {
+			return err
+		}
+	}
 
-	// Set diagonal Space
-	c.SetDiaSpace(vector)
+	// Insert the vector into the index and set the diagonal Space
+	c.addToIndex(vector)
 
 	// add it to the Space
 	(*c.Space)[vector.Id] = vector
 
 	// Save the Collection to the FS - only if this is a new vector
 	if vector.SaveVectorPosition == -1 {
-		pos, err := FileMapper.Mapper.SaveVectorWriter(vector.Id, vector.DataStart, vector.PayloadStart, c.Name)
+		pos, err := FileMapper.Mapper.SaveVectorWriter(vector.Id, vector.DataStart, vector.PayloadStart, vector.CLength, vector.PLength, c.Name)
 		if err != nil {
 			Logger.Log.Log("Error saving vector to file: "+err.Error(), "ERROR")
 			return err
@@ -110,11 +165,88 @@ func (c *Collection) Insert(vector *Vector.Vector) error {
 		vector.SaveVectorPosition = pos
 	}
 
+	// Checkpoint the wal if it grew too large
+	c.checkpointWal()
+
 	// Set classifier ready to true
 	c.ClassifierReady = true
 
 	// Check if there is an Index with a key from the Payload - if so add the vector to the Index
-	go c.CheckIndex(vector)
+	err := c.CheckIndex(vector)
+	if err != nil {
+		Logger.Log.Log("Error indexing vector "+vector.Id+": "+err.Error(), "ERROR")
+	}
+	return nil
+}
+
+// InsertBatch inserts many new vectors into the collection. The vectors are logged, written to the collection
+// files and saved to the meta file with one append each. If a vector is invalid no vector will be inserted and a
+// BatchError with all invalid vectors is returned.
+func (c *Collection) InsertBatch(vectors []*Vector.Vector) error {
+	c.Mut.Lock()
+	defer c.Mut.Unlock()
+
+	// Check all vectors first
+	err := c.checkBatch(vectors)
+	if err != nil {
+		return err
+	}
+	if len(vectors) == 0 {
+		return nil
+	}
+	for _, vector := range vectors {
+		c.PrepareVector(vector)
+	}
+
+	// Log the inserts before they are applied
+	records := make([]*Wal.Record, len(vectors))
+	for i, vector := range vectors {
+		records[i] = &Wal.Record{Op: Wal.Insert, IDs: []string{vector.Id}, Data: vector.Data}
+		if vector.Payload != nil {
+			records[i].Payload = *vector.Payload
+		}
+	}
+	err = c.Wal.AppendBatch(records)
+	if err != nil {
+		return err
+	}
+
+	// Write the data and the payloads
+	err = Vector.PersistBatch(vectors, c.Name)
+	if err != nil {
+		return err
+	}
+
+	// Save the vectors to the meta file
+	svs := make([]FileMapper.SaveVector, len(vectors))
+	for i, vector := range vectors {
+		svs[i] = FileMapper.SaveVector{VectorID: vector.Id, DataStart: vector.DataStart, PayloadStart: vector.PayloadStart,
+			DataLength: int64(vector.CLength), PayloadLength: int64(vector.PLength)}
+	}
+	positions, err := FileMapper.Mapper.SaveVectorBatchWriter(svs, c.Name)
+	if err != nil {
+		Logger.Log.Log("Error saving vectors to file: "+err.Error(), "ERROR")
+		return err
+	}
+
+	for i, vector := range vectors {
+		vector.SaveVectorPosition = positions[i]
+		// Insert the vector into the index and set the diagonal Space
+		c.addToIndex(vector)
+		// add it to the Space
+		(*c.Space)[vector.Id] = vector
+		// Check if there is an Index with a key from the Payload - if so add the vector to the Index
+		err = c.CheckIndex(vector)
+		if err != nil {
+			Logger.Log.Log("Error indexing vector "+vector.Id+": "+err.Error(), "ERROR")
+		}
+	}
+
+	// Checkpoint the wal if it grew too large
+	c.checkpointWal()
+
+	// Set classifier ready to true
+	c.ClassifierReady = true
 	return nil
 }
 
@@ -123,12 +255,25 @@ func (c *Collection) Insert(vector *Vector.Vector) error {
 func (c *Collection) DeleteVectorByID(ids []string) error {
 	c.Mut.Lock()
 	defer c.Mut.Unlock()
+	return c.deleteVectorByID(ids)
+}
 
-	// Check if the vector exists
+// deleteVectorByID deletes the vectors with the given ids - the caller must hold the write lock of the Collection
+func (c *Collection) deleteVectorByID(ids []string) error {
+	// Check if the vectors exist
 	for _, id := range ids {
 		if _, ok := (*c.Space)[id]; !ok {
 			return fmt.Errorf("Vector with ID %s does not exist", id)
 		}
+	}
+
+	// Log the delete before it is applied
+	_, err := c.Wal.Append(Wal.Delete, ids, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
 		// set the datasatrt in SaveVector to -1
 		err := FileMapper.Mapper.SaveVectorWriteAt(-1, -1, c.Name, (*c.Space)[id].SaveVectorPosition)
 		if err != nil {
@@ -138,10 +283,43 @@ func (c *Collection) DeleteVectorByID(ids []string) error {
 		(*c.Space)[id].Delete()
 		// add the vector to the deleted vectors
 		(*c.DeletedVectors)[id] = (*c.Space)[id]
+		// the data of the vector is dead now
+		c.DeadBytes += int64((*c.Space)[id].CLength + (*c.Space)[id].PLength)
+	}
+
+	// Checkpoint the wal if it grew too large
+	c.checkpointWal()
+
+	// Compact the collection files if there is too much dead data - Compact will wait for our lock
+	if c.NeedsCompaction() {
+		go func() {
+			err := c.Compact()
+			if err != nil {
+				Logger.Log.Log("Error compacting collection "+c.Name+": "+err.Error(), "ERROR")
+			}
+		}()
 	}
 	return nil
 }
 
+// checkpointWal will sync the collection files and truncate the wal when it exceeds walCheckpointSize.
+// The caller must hold the write lock of the Collection, so no mutation is in flight.
+func (c *Collection) checkpointWal() {
+	if c.Wal.Size() < walCheckpointSize {
+		return
+	}
+	err := FileMapper.Mapper.Sync(c.Name)
+	if err != nil {
+		Logger.Log.Log("Error syncing collection files, wal not checkpointed: "+err.Error(), "ERROR")
+		return
+	}
+	err = c.Wal.Checkpoint()
+	if err != nil {
+		Logger.Log.Log("Error checkpointing wal: "+err.Error(), "ERROR")
+	}
+	c.saveVectorIndex()
+}
+
 // DeleteWatcher will delete all collected deleted Vectors from the Collection - it will be called every 10 seconds in a go routine
 func (c *Collection) DeleteWatcher() {
 	for {
@@ -157,15 +335,25 @@ func (c *Collection) DeleteWatcher() {
 // DeleteMarkedVectors deletes vectors that are marked as deleted in the collection's space.
 // It iterates over the space and removes vectors that have the `deleted` flag set to true.
 func (c *Collection) DeleteMarkedVectors() {
-	c.Mut.RLock()
+	c.Mut.Lock()
+	defer c.Mut.Unlock()
+	removed := 0
 	for _, v := range *c.DeletedVectors {
-		if v.IsDeleted() {
+		// The ID may belong to a vector that replaced the deleted one
+		if v.IsDeleted() && (*c.Space)[v.Id] == v {
 			delete(*c.Space, v.Id)
+			if c.VectorIndex != nil {
+				c.VectorIndex.Remove(v)
+				removed++
+			}
 		}
 	}
 	// Delete the deleted vectors from the deleted vectors
 	c.DeletedVectors = &map[string]*Vector.Vector{}
-	c.Mut.RUnlock()
+	// The vector index only changed if a vector was removed from it
+	if removed > 0 {
+		c.saveVectorIndex()
+	}
 }
 
 // SetDiaSpace will set the diagonal space of the Collection
@@ -209,53 +397,87 @@ func (c *Collection) GetNodeCount() int64 {
 func (c *Collection) WriteConfig() error {
 	c.Mut.RLock()
 	defer c.Mut.RUnlock()
+	return c.writeConfig(*ArgsParser.Ap.FileStore + c.Name + ".json")
+}
+
+// writeConfig writes the Collection config to the given file - the caller must hold the lock
+func (c *Collection) writeConfig(name string) error {
+	// The range of the quantizer - the codes in memory are restored with it on boot
+	var quantizerMin, quantizerMax []float64
+	if c.Quantizer != nil {
+		quantizerMin, quantizerMax = c.Quantizer.Min, c.Quantizer.Max
+	}
 
 	// We need to save the CollectionConfig, this will be done via a struct that saves the important configs of the Collection
-	file, err := os.Create(*ArgsParser.Ap.FileStore + c.Name + ".json")
+	file, err := os.Create(name)
 	if err != nil {
 		return err
 	}
+	defer file.Close()
 	// Save the struct to it
 	err = json.NewEncoder(file).Encode(Utils.CollectionConfig{
 		Name:             c.Name,
 		VectorDimension:  c.VectorDimension,
 		DistanceFuncName: c.DistanceFuncName,
 		DiagonalLength:   c.DiagonalLength,
+		FormatVersion:    c.FormatVersion,
+		Precision:        c.Precision.String(),
+		QuantizerMin:     quantizerMin,
+		QuantizerMax:     quantizerMax,
+		IndexType:        c.IndexType,
+		IndexParams:      c.IndexParams,
+		SearchAccuracy:   c.SearchAccuracy,
+		MaxChecks:        c.MaxChecks,
+		AxisRange:        c.AxisRange,
+		Schema:           c.Schema,
 	})
 	if err != nil {
 		return err
 	}
-	return nil
+	return file.Sync()
 }
 
-// Recreate will recreate the KD-Tree from the SpaceMap
+// Recreate will recreate the KD-Tree from the SpaceMap - it is built balanced at once
 func (c *Collection) Recreate() {
 	c.Mut.Lock()
 	defer c.Mut.Unlock()
-	c.Nodes = &Node.Node{Depth: 0}
+	vectors := make([]*Vector.Vector, 0, len(*c.Space))
 	for _, v := range *c.Space {
 		if !v.IsDeleted() {
 			v.RecreateMut() // This needed to recreate the vector mut, it will not be saved in the gob file
-			c.Nodes.Insert(v)
-			c.SetDiaSpace(v)
+			if c.VectorIndex != nil {
+				c.addToIndex(v)
+				continue
+			}
+			c.SetDiaSpace(c.fullVector(v))
+			vectors = append(vectors, v)
 		}
 	}
+	c.Nodes = Node.Build(vectors, c.DimensionDiff)
+	// The quantizer of the config may be missing or too small
+	if c.Precision == Vector.Int8 && (c.Quantizer == nil || !c.Quantizer.Covers(c.MinVector.Data) || !c.Quantizer.Covers(c.MaxVector.Data)) {
+		c.retrainQuantizer(nil)
+	}
 }
 
-// Rebuild will create a new KD-Tree from the SpaceMap
+// Rebuild will create a new balanced KD-Tree from the SpaceMap - ivf indexes are clustered again
 func (c *Collection) Rebuild() {
 	minn := &Vector.Vector{Data: make([]float64, c.VectorDimension), Length: c.VectorDimension}
 	maxx := &Vector.Vector{Data: make([]float64, c.VectorDimension), Length: c.VectorDimension}
 	diff := &Vector.Vector{Data: make([]float64, c.VectorDimension), Length: c.VectorDimension}
-	nodes := &Node.Node{Depth: 0}
+	vectors := make([]*Vector.Vector, 0, len(*c.Space))
 	length := float64(0)
 	c.Mut.RLock()
 	for _, v := range *c.Space {
 		if !v.IsDeleted() {
-			nodes.Insert(v)
-			c.SetLocalDiaSpace(diff, minn, maxx, v, &length, &c.VectorDimension)
+			// Collections with a vector index have no KD-Tree
+			if c.VectorIndex == nil {
+				vectors = append(vectors, v)
+			}
+			c.SetLocalDiaSpace(diff, minn, maxx, c.fullVector(v), &length, &c.VectorDimension)
 		}
 	}
+	nodes := Node.Build(vectors, diff)
 	c.Mut.RUnlock()
 	c.Mut.Lock()
 	c.Nodes = nodes
@@ -264,6 +486,7 @@ func (c *Collection) Rebuild() {
 	c.DimensionDiff = diff
 	c.DiagonalLength = length
 	c.Mut.Unlock()
+	c.reclusterIndex()
 }
 
 // CheckID will Check if the given ID is already in the Collection Space
@@ -424,8 +647,8 @@ func (c *Collection) ClassifierToSlice() []string {
 	return slice
 }
 
-// CreateIndex will create a new Index
-func (c *Collection) CreateIndex(name, key string) error {
+// CreateIndex will create a new Index of the type, see ValueIndex and RangeIndex
+func (c *Collection) CreateIndex(name, key, indexType string) error {
 	c.Mut.Lock()
 	defer c.Mut.Unlock()
 
@@ -435,7 +658,7 @@ func (c *Collection) CreateIndex(name, key string) error {
 	}
 
 	// Create the index
-	index, err := NewIndex(key, c.Space, c.Name)
+	index, err := NewIndex(key, indexType, c.Space, c.Name)
 	if err != nil {
 		return err
 	}
@@ -444,34 +667,44 @@ func (c *Collection) CreateIndex(name, key string) error {
 	return nil
 }
 
-// CheckIndex Check if a specific Index exists
+// CheckIndex adds the vector to the Indexes with a key in its payload. The caller must hold the write lock, so the
+// vector is in the Indexes when the next search takes the lock.
 func (c *Collection) CheckIndex(vector *Vector.Vector) error {
 	// First check if there is an Index
 	if len(c.Indexes) == 0 {
 		return nil
 	}
 
-	// the result slice
-	var result []string
-
 	// Get the Payload from the hdd
 	payload, err := FileMapper.Mapper.ReadPayload(vector.PayloadStart, c.Name)
 	if err != nil {
 		return err
 	}
 
-	// check if an Index Key is in the Payload
-	for k := range c.Indexes {
-		c.Indexes[k].mut.RLock()
-		if _, ok := (*payload)[c.Indexes[k].Key]; ok {
-			result = append(result, k)
+	// Add the vector to every Index with a key in the Payload
+	for _, index := range c.Indexes {
+		if _, _, ok := Filter.Lookup(*payload, index.Key); !ok {
+			continue
+		}
+		index.mut.Lock()
+		err = index.add(vector, payload)
+		index.mut.Unlock()
+		if err != nil {
+			return err
 		}
-		c.Indexes[k].mut.RUnlock()
 	}
+	return nil
+}
 
-	// If there is a result, add the vector to the Index
-	if len(result) > 0 {
-		err = c.addVectorToIndexes(result, vector)
+// uncheckIndex removes a replaced vector with the payload from the Indexes - the caller must hold the write lock
+func (c *Collection) uncheckIndex(vector *Vector.Vector, payload *map[string]interface{}) error {
+	for _, index := range c.Indexes {
+		if _, _, ok := Filter.Lookup(*payload, index.Key); !ok {
+			continue
+		}
+		index.mut.Lock()
+		err := index.remove(vector, payload)
+		index.mut.Unlock()
 		if err != nil {
 			return err
 		}
@@ -479,6 +712,13 @@ func (c *Collection) CheckIndex(vector *Vector.Vector) error {
 	return nil
 }
 
+// IndexInfo is the saved definition of an Index
+type IndexInfo struct {
+	Name string
+	Key  string
+	Type string
+}
+
 // SaveIndexes saves the indexes of the collection to a file in the file store directory.
 func (c *Collection) SaveIndexes() error {
 	file, err := os.Create(*ArgsParser.Ap.FileStore + c.Name + "_indexes.gob")
@@ -487,18 +727,14 @@ func (c *Collection) SaveIndexes() error {
 	}
 	defer file.Close()
 
-	// make empty string slice
-	indexes := make([]string, 0)
-
-	// Get all the indexnames from  then c.Indexes map
-	for indexName := range c.Indexes {
-		indexes = append(indexes, indexName)
+	// Get the definitions of all indexes from the c.Indexes map
+	indexes := make([]IndexInfo, 0, len(c.Indexes))
+	for indexName, index := range c.Indexes {
+		indexes = append(indexes, IndexInfo{Name: indexName, Key: index.Key, Type: index.Type})
 	}
 
 	// Create Encoder
 	enc := gob.NewEncoder(file)
-
-	// The indexname is the index Field in the payload
 	err = enc.Encode(indexes)
 	if err != nil {
 		return err
@@ -506,51 +742,45 @@ func (c *Collection) SaveIndexes() error {
 	return nil
 }
 
+// loadIndexes reads the saved definitions of the indexes. Older files only hold the names, the name is the
+// index Field in the payload of these value indexes.
+func (c *Collection) loadIndexes() ([]IndexInfo, error) {
+	data, err := os.ReadFile(*ArgsParser.Ap.FileStore + c.Name + "_indexes.gob")
+	if err != nil {
+		return nil, err
+	}
+
+	var indexes []IndexInfo
+	if err = gob.NewDecoder(bytes.NewReader(data)).Decode(&indexes); err == nil {
+		return indexes, nil
+	}
+	var names []string
+	if err = gob.NewDecoder(bytes.NewReader(data)).Decode(&names); err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		indexes = append(indexes, IndexInfo{Name: name, Key: name, Type: ValueIndex})
+	}
+	return indexes, nil
+}
+
 // RebuildIndex index will rebuild the indexes
 func (c *Collection) RebuildIndex() error {
-	// Open the file
-	file, err := os.Open(*ArgsParser.Ap.FileStore + c.Name + "_indexes.gob")
+	indexes, err := c.loadIndexes()
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-
-	// Make empty string slice
-	var indexes []string
-
-	// Create Decoder
-	dec := gob.NewDecoder(file)
-	dec.Decode(&indexes)
 
 	// Create c.Indexes map
 	c.Indexes = make(map[string]*Index)
 
 	// Now loop over the indexes and recreate them
-	for _, indexName := range indexes {
-		index, err := NewIndex(indexName, c.Space, c.Name)
+	for _, info := range indexes {
+		index, err := NewIndex(info.Key, info.Type, c.Space, c.Name)
 		if err != nil {
 			return err
 		}
-		c.Indexes[indexName] = index
-	}
-	return nil
-}
-
-// addVectorToIndexes to Add a vector to the Index(es)
-func (c *Collection) addVectorToIndexes(keys []string, vector *Vector.Vector) error {
-	c.Mut.RLock()
-	defer c.Mut.RUnlock()
-
-	// Add the vector to the Indexes
-	for _, k := range keys {
-		if index, ok := c.Indexes[k]; ok {
-			c.Indexes[k].mut.Lock()
-			err := index.AddToIndex(vector)
-			c.Indexes[k].mut.Unlock()
-			if err != nil {
-				return err
-			}
-		}
+		c.Indexes[info.Name] = index
 	}
 	return nil
 }
@@ -578,8 +808,8 @@ func (c *Collection) GetClassifierTrainingPhase(name string) (*NN.TrainProgress,
 // - Locks the collection's mutex to ensure exclusive access
 // - Initializes an empty slice to store the IDs of the vectors that match the filter
 // - Iterates through the collection's vector space
-// - If a vector satisfies the filter condition, its ID is appended to the slice of IDs
-// - Calls DeleteVectorByID with the slice of IDs to delete the vectors
+// - If a vector passes all filters like in a search, its ID is appended to the slice of IDs
+// - Deletes the vectors with the slice of IDs
 // - Unlocks the collection's mutex
 // - Returns an error if an error occurs during the deletion process, otherwise returns nil
 func (c *Collection) SerialDelete(filters []Filter.Filter) error {
@@ -590,16 +820,14 @@ func (c *Collection) SerialDelete(filters []Filter.Filter) error {
 	ids := []string{}
 
 	// Loop through the vectorspace
-	for _, filter := range filters {
-		for _, vector := range *c.Space {
-			if ok, _ := filter.ValidateFilter(vector); ok {
-				ids = append(ids, vector.Id)
-			}
+	for _, vector := range *c.Space {
+		if !vector.IsDeleted() && Filter.Validate(&filters, vector) {
+			ids = append(ids, vector.Id)
 		}
 	}
 
 	// Delete the vectors
-	err := c.DeleteVectorByID(ids)
+	err := c.deleteVectorByID(ids)
 	if err != nil {
 		return err
 	}
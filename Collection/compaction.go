@@ -0,0 +1,148 @@
+package Collection
+
+import (
+	"VreeDB/ArgsParser"
+	"VreeDB/FileMapper"
+	"VreeDB/Logger"
+	"VreeDB/Vector"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// compactMinDeadBytes is the minimum of dead bytes before a collection will be compacted automatically
+const compactMinDeadBytes = 1024 * 1024
+
+// DeadByteRatio returns the part of the data file that is not referenced by a live vector anymore
+func (c *Collection) DeadByteRatio() float64 {
+	size := FileMapper.Mapper.Size(c.Name)
+	if size == 0 {
+		return 0
+	}
+	return float64(c.DeadBytes) / float64(size)
+}
+
+// NeedsCompaction returns true if the dead-byte ratio exceeds the configured threshold
+func (c *Collection) NeedsCompaction() bool {
+	if *ArgsParser.Ap.CompactRatio <= 0 || c.DeadBytes < compactMinDeadBytes || c.compacting.Load() {
+		return false
+	}
+	return c.DeadByteRatio() > *ArgsParser.Ap.CompactRatio
+}
+
+// CalculateDeadBytes calculates the dead bytes of the data file from the lengths of the live vectors.
+// If the length of a vector is unknown (collections written by older versions), the dead bytes stay 0.
+func (c *Collection) CalculateDeadBytes() {
+	c.Mut.Lock()
+	defer c.Mut.Unlock()
+	var live int64
+	for _, v := range *c.Space {
+		if v.CLength == 0 || v.PLength == 0 {
+			c.DeadBytes = 0
+			return
+		}
+		live += int64(v.CLength + v.PLength)
+	}
+	c.DeadBytes = FileMapper.Mapper.Size(c.Name) - live
+}
+
+// Compact rewrites the data file and the meta file of the collection with the live vectors only.
+// The collection is locked while it is compacted.
+func (c *Collection) Compact() error {
+	return c.compact(c.FormatVersion, nil)
+}
+
+// CurrentFormat returns the vector format the files of the collection are written in by this version
+func (c *Collection) CurrentFormat() int {
+	return FileMapper.CurrentFormat(c.Precision == Vector.Float32)
+}
+
+// UpgradeFormat rewrites the collection files in the current vector format. The new config is written next to the
+// old one when the new files are synced and replaced after them, so a crash in between can be recovered on boot.
+func (c *Collection) UpgradeFormat() error {
+	old := c.FormatVersion
+	configName := *ArgsParser.Ap.FileStore + c.Name + ".json.compact"
+	format := c.CurrentFormat()
+	err := c.compact(format, func() error {
+		// The caller holds the lock of the collection
+		c.FormatVersion = format
+		return c.writeConfig(configName)
+	})
+	if err != nil {
+		c.FormatVersion = old
+		os.Remove(configName)
+		return err
+	}
+	err = FileMapper.Mapper.CommitConfig(c.Name)
+	if err != nil {
+		return err
+	}
+	Logger.Log.Log(fmt.Sprintf("Collection %s upgraded from vector format %d to %d", c.Name, old, c.FormatVersion), "INFO")
+	return nil
+}
+
+// compact rewrites the collection files with the live vectors in the given vector format, beforeSwap is passed to
+// FileMapper.Compact
+func (c *Collection) compact(format int, beforeSwap func() error) error {
+	// Only one compaction at a time
+	if !c.compacting.CompareAndSwap(false, true) {
+		return fmt.Errorf("Collection %s is already being compacted", c.Name)
+	}
+	defer c.compacting.Store(false)
+
+	c.Mut.Lock()
+	defer c.Mut.Unlock()
+
+	t := time.Now()
+	size := FileMapper.Mapper.Size(c.Name)
+
+	// Collect the live vectors - in the order they were written
+	live := make([]*Vector.Vector, 0, len(*c.Space))
+	for _, v := range *c.Space {
+		if !v.IsDeleted() && v.DataStart >= 0 {
+			live = append(live, v)
+		}
+	}
+	sort.Slice(live, func(i, j int) bool {
+		return live[i].SaveVectorPosition < live[j].SaveVectorPosition
+	})
+	vectors := make([]FileMapper.SaveVector, len(live))
+	for i, v := range live {
+		vectors[i] = FileMapper.SaveVector{VectorID: v.Id, DataStart: v.DataStart, PayloadStart: v.PayloadStart}
+	}
+
+	// Rewrite the files
+	result, err := FileMapper.Mapper.Compact(c.Name, vectors, c.VectorDimension, format, beforeSwap)
+	if err != nil {
+		return err
+	}
+
+	// Set the new positions
+	for i, v := range live {
+		v.DataStart = result[i].DataStart
+		v.PayloadStart = result[i].PayloadStart
+		v.SaveVectorPosition = result[i].SaveVectorPosition
+		v.CLength = int(result[i].DataLength)
+		v.PLength = int(result[i].PayloadLength)
+	}
+
+	// The deleted vectors point into the old file - they must never be read again
+	for _, v := range *c.DeletedVectors {
+		v.DataStart = -1
+		v.PayloadStart = -1
+	}
+	c.DeadBytes = 0
+
+	// The saved codes of the vector index refer to the old positions
+	c.saveVectorIndex()
+
+	// All mutations are in the new files now
+	err = c.Wal.Checkpoint()
+	if err != nil {
+		Logger.Log.Log("Error checkpointing wal: "+err.Error(), "ERROR")
+	}
+	Logger.Log.Log(fmt.Sprintf("Collection %s compacted from %d to %d bytes in %s", c.Name, size,
+		FileMapper.Mapper.Size(c.Name), time.Since(t).String()), "INFO")
+	return nil
+}
@@ -0,0 +1,176 @@
+package Collection
+
+import (
+	"VreeDB/FileMapper"
+	"VreeDB/Filter"
+	"VreeDB/Vector"
+	"fmt"
+	"math"
+)
+
+// Facet is the aggregation of a payload key over the counted vectors - the elements of arrays are counted one by one
+type Facet struct {
+	Counts  map[string]int // number of vectors per value of the key
+	Numeric int            // number of numeric values - Min, Max and Avg are only set if it is not 0
+	Min     float64
+	Max     float64
+	Avg     float64
+}
+
+// CountResult is the number of vectors that pass a filter and the facets of the requested payload keys
+type CountResult struct {
+	Count  int
+	Facets map[string]*Facet
+}
+
+// add counts the value count times
+func (f *Facet) add(value any, count int) {
+	f.Counts[fmt.Sprint(value)] += count
+	var number float64
+	switch v := value.(type) {
+	case float64:
+		number = v
+	case int:
+		number = float64(v)
+	default:
+		return
+	}
+	if f.Numeric == 0 {
+		f.Min, f.Max = number, number
+	}
+	f.Min, f.Max = math.Min(f.Min, number), math.Max(f.Max, number)
+	// Avg holds the sum until the count is done
+	f.Avg += number * float64(count)
+	f.Numeric += count
+}
+
+// Count returns the number of vectors that pass the filter and the facets of the given payload keys. An equal filter
+// on the key of a payload Index only scans the vectors of that Index entry, facets of indexed keys are read from the
+// Index if there is no filter.
+func (c *Collection) Count(filter *[]Filter.Filter, facetKeys []string) (*CountResult, error) {
+	c.Mut.RLock()
+	defer c.Mut.RUnlock()
+
+	result := &CountResult{Facets: make(map[string]*Facet, len(facetKeys))}
+	var scanKeys []string
+	for _, key := range facetKeys {
+		if _, ok := result.Facets[key]; ok {
+			continue
+		}
+		result.Facets[key] = &Facet{Counts: make(map[string]int)}
+		// Without a filter the facets of indexed keys are the entries of the Index
+		if index := c.indexByKey(key); index != nil && filter == nil {
+			index.mut.RLock()
+			for value, node := range index.Entries {
+				if count := c.countAlive(node.Walk); count > 0 {
+					result.Facets[key].add(value, count)
+				}
+			}
+			index.mut.RUnlock()
+			continue
+		}
+		scanKeys = append(scanKeys, key)
+	}
+
+	// Count the vectors and aggregate the payloads of the keys without an Index
+	var err error
+	c.candidates(filter)(func(vector *Vector.Vector) {
+		if err != nil || !Filter.Validate(filter, vector) {
+			return
+		}
+		result.Count++
+		if len(scanKeys) == 0 {
+			return
+		}
+		var payload *map[string]interface{}
+		payload, err = FileMapper.Mapper.ReadPayload(vector.PayloadStart, c.Name)
+		if err != nil {
+			return
+		}
+		for _, key := range scanKeys {
+			for _, value := range Filter.Values(*payload, key) {
+				result.Facets[key].add(value, 1)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, facet := range result.Facets {
+		if facet.Numeric > 0 {
+			facet.Avg /= float64(facet.Numeric)
+		}
+	}
+	return result, nil
+}
+
+// candidates returns a walk over the alive vectors that can pass the filter. If the filter has an equal condition
+// on the key of a payload Index only the vectors of the matching entry are walked.
+func (c *Collection) candidates(filter *[]Filter.Filter) func(func(*Vector.Vector)) {
+	if filter != nil {
+		for _, f := range *filter {
+			switch f.Value.(type) {
+			case int, float64, string:
+			default:
+				// Only these values are keys of an Index
+				continue
+			}
+			if f.Op != Filter.Equal {
+				continue
+			}
+			index := c.indexByKey(f.Field)
+			if index == nil {
+				continue
+			}
+			index.mut.RLock()
+			node, ok := index.Entries[f.Value]
+			index.mut.RUnlock()
+			if ok {
+				return func(fn func(*Vector.Vector)) {
+					index.mut.RLock()
+					defer index.mut.RUnlock()
+					c.walkAlive(node.Walk, fn)
+				}
+			}
+		}
+	}
+	return func(fn func(*Vector.Vector)) {
+		for _, vector := range *c.Space {
+			if !vector.IsDeleted() {
+				fn(vector)
+			}
+		}
+	}
+}
+
+// walkAlive calls fn once for each vector of the walk that is alive and still stored under its id
+func (c *Collection) walkAlive(walk func(func(*Vector.Vector)), fn func(*Vector.Vector)) {
+	seen := make(map[*Vector.Vector]bool)
+	walk(func(vector *Vector.Vector) {
+		// A vector can be in an Index entry twice if it was indexed while the Index was created
+		if !vector.IsDeleted() && (*c.Space)[vector.Id] == vector && !seen[vector] {
+			seen[vector] = true
+			fn(vector)
+		}
+	})
+}
+
+// countAlive returns the number of alive vectors of the walk
+func (c *Collection) countAlive(walk func(func(*Vector.Vector))) int {
+	count := 0
+	c.walkAlive(walk, func(*Vector.Vector) {
+		count++
+	})
+	return count
+}
+
+// indexByKey returns a payload Index on the key or nil
+func (c *Collection) indexByKey(key string) *Index {
+	for _, index := range c.Indexes {
+		if index.Key == key {
+			return index
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package Collection
+
+import (
+	"VreeDB/ArgsParser"
+	"VreeDB/Filter"
+	"VreeDB/Node"
+	"VreeDB/Utils"
+	"VreeDB/Vector"
+	"container/heap"
+	"runtime"
+	"sync"
+)
+
+// exactBatchSize is the number of vectors that are passed to the distance kernel at once
+const exactBatchSize = 64
+
+// batchKernel returns the batch distance function for the instruction set that is enabled
+func batchKernel(plain, avx, neon func(target, block, out []float64)) func(target, block, out []float64) {
+	if *ArgsParser.Ap.AVX256 {
+		return avx
+	} else if *ArgsParser.Ap.Neon {
+		return neon
+	}
+	return plain
+}
+
+// ExactSearch returns the k nearest vectors to the target that pass the filter. Every vector of the collection is
+// compared to the target in full precision, the Space is scanned in parallel. The caller must hold the read lock.
+func (c *Collection) ExactSearch(target []float64, k int, filter *[]Filter.Filter) []*Utils.HeapItem {
+	if len(target) != c.VectorDimension || k < 1 {
+		return []*Utils.HeapItem{}
+	}
+	vectors := make([]*Vector.Vector, 0, len(*c.Space))
+	for _, v := range *c.Space {
+		if !v.IsDeleted() {
+			vectors = append(vectors, v)
+		}
+	}
+	return c.exactSearch(vectors, target, k, filter)
+}
+
+// exactSearch returns the k nearest of the vectors to the target that pass the filter, see ExactSearch
+func (c *Collection) exactSearch(vectors []*Vector.Vector, target []float64, k int, filter *[]Filter.Filter) []*Utils.HeapItem {
+	if len(target) != c.VectorDimension || k < 1 {
+		return []*Utils.HeapItem{}
+	}
+
+	// Every worker scans a part of the vectors
+	workers := max(1, min(runtime.NumCPU(), len(vectors)/exactBatchSize))
+	heaps := make([]Utils.Heap, workers)
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			heaps[w] = c.scan(vectors[w*len(vectors)/workers:(w+1)*len(vectors)/workers], target, k, filter)
+		}(w)
+	}
+	wg.Wait()
+
+	// Merge the results of the workers
+	h := Utils.Heap{}
+	for _, wh := range heaps {
+		for _, item := range wh {
+			heap.Push(&h, item)
+			if h.Len() > k {
+				heap.Pop(&h)
+			}
+		}
+	}
+	return h
+}
+
+// scan compares the target to the vectors in batches and returns the k nearest vectors that pass the filter.
+// The filter is only validated for vectors that would make it into the result.
+func (c *Collection) scan(vectors []*Vector.Vector, target []float64, k int, filter *[]Filter.Filter) Utils.Heap {
+	block := make([]float64, exactBatchSize*c.VectorDimension)
+	distances := make([]float64, exactBatchSize)
+	h := Utils.Heap{}
+	for start := 0; start < len(vectors); start += exactBatchSize {
+		batch := vectors[start:min(start+exactBatchSize, len(vectors))]
+		for i, v := range batch {
+			copy(block[i*c.VectorDimension:], *v.GetData())
+		}
+		c.BatchDistanceFunc(target, block[:len(batch)*c.VectorDimension], distances[:len(batch)])
+
+		for i, v := range batch {
+			if h.Len() >= k && distances[i] >= h[0].Distance {
+				continue
+			}
+			if !Filter.Validate(filter, v) {
+				continue
+			}
+			heap.Push(&h, &Utils.HeapItem{Node: &Node.Node{Vector: v}, Distance: distances[i]})
+			if h.Len() > k {
+				heap.Pop(&h)
+			}
+		}
+	}
+	return h
+}
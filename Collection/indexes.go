@@ -0,0 +1,169 @@
+package Collection
+
+import (
+	"VreeDB/ArgsParser"
+	"VreeDB/Node"
+	"VreeDB/Utils"
+	"VreeDB/Vector"
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"unsafe"
+)
+
+// maxStatsValues is the number of values with the most vectors that IndexStats returns the counts of
+const maxStatsValues = 1000
+
+// IndexStats are the statistics of a payload Index
+type IndexStats struct {
+	IndexInfo
+	Cardinality int            // number of values with alive vectors
+	Vectors     int            // number of alive vectors with a value
+	Counts      map[string]int // alive vectors per value
+	Truncated   bool           // true if Counts only holds the values with the most vectors
+	Memory      int64          // estimated bytes
+}
+
+// ListIndexes returns the definitions of the indexes sorted by name
+func (c *Collection) ListIndexes() []IndexInfo {
+	c.Mut.RLock()
+	defer c.Mut.RUnlock()
+	indexes := make([]IndexInfo, 0, len(c.Indexes))
+	for name, index := range c.Indexes {
+		indexes = append(indexes, IndexInfo{Name: name, Key: index.Key, Type: index.Type})
+	}
+	slices.SortFunc(indexes, func(a, b IndexInfo) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return indexes
+}
+
+// HasIndex returns true if the collection has a payload Index with the name
+func (c *Collection) HasIndex(name string) bool {
+	c.Mut.RLock()
+	defer c.Mut.RUnlock()
+	_, ok := c.Indexes[name]
+	return ok
+}
+
+// DropIndex removes the Index and saves the remaining indexes. If the Index was created for an indexed field of the
+// schema, the field is not indexed anymore.
+func (c *Collection) DropIndex(name string) error {
+	c.Mut.Lock()
+	defer c.Mut.Unlock()
+	if _, ok := c.Indexes[name]; !ok {
+		return fmt.Errorf("Index with name %s does not exist", name)
+	}
+
+	// The schema fields are indexed by the index with their name
+	if i := slices.IndexFunc(c.Schema, func(f Utils.SchemaField) bool { return f.Name == name && f.Indexed }); i >= 0 {
+		old := c.Schema
+		c.Schema = slices.Clone(old)
+		c.Schema[i].Indexed = false
+		err := c.writeConfig(*ArgsParser.Ap.FileStore + c.Name + ".json")
+		if err != nil {
+			c.Schema = old
+			return err
+		}
+	}
+	delete(c.Indexes, name)
+	return c.SaveIndexes()
+}
+
+// RecreateIndex builds the Index again from the payloads of the alive vectors, this removes the deleted vectors and
+// the old values of changed payloads from it
+func (c *Collection) RecreateIndex(name string) error {
+	c.Mut.Lock()
+	defer c.Mut.Unlock()
+	old, ok := c.Indexes[name]
+	if !ok {
+		return fmt.Errorf("Index with name %s does not exist", name)
+	}
+	alive := make(map[string]*Vector.Vector, len(*c.Space))
+	for id, vector := range *c.Space {
+		if !vector.IsDeleted() {
+			alive[id] = vector
+		}
+	}
+	index, err := NewIndex(old.Key, old.Type, &alive, c.Name)
+	if err != nil {
+		return err
+	}
+	c.Indexes[name] = index
+	return nil
+}
+
+// IndexStats returns the statistics of the Index
+func (c *Collection) IndexStats(name string) (*IndexStats, error) {
+	c.Mut.RLock()
+	defer c.Mut.RUnlock()
+	index, ok := c.Indexes[name]
+	if !ok {
+		return nil, fmt.Errorf("Index with name %s does not exist", name)
+	}
+	index.mut.Lock()
+	defer index.mut.Unlock()
+	index.sortValues()
+
+	stats := &IndexStats{IndexInfo: IndexInfo{Name: name, Key: index.Key, Type: index.Type}, Counts: make(map[string]int)}
+	vectors := make(map[*Vector.Vector]bool)
+	// The sorted values of a value are next to each other, a vector can be in them twice if it was indexed while
+	// the Index was created
+	var inValue map[*Vector.Vector]bool
+	for j := range index.Sorted {
+		value := &index.Sorted[j]
+		if j == 0 || value.compare(&index.Sorted[j-1]) != 0 {
+			inValue = make(map[*Vector.Vector]bool)
+		}
+		if value.vector.IsDeleted() || (*c.Space)[value.vector.Id] != value.vector || inValue[value.vector] {
+			continue
+		}
+		inValue[value.vector] = true
+		vectors[value.vector] = true
+		key := value.text
+		if value.rank == 0 {
+			key = strconv.FormatFloat(value.number, 'f', -1, 64)
+		}
+		if len(inValue) == 1 {
+			stats.Cardinality++
+		}
+		stats.Counts[key]++
+	}
+	stats.Vectors = len(vectors)
+
+	// Keep the values with the most vectors
+	if len(stats.Counts) > maxStatsValues {
+		keys := make([]string, 0, len(stats.Counts))
+		for key := range stats.Counts {
+			keys = append(keys, key)
+		}
+		slices.SortFunc(keys, func(a, b string) int {
+			return cmp.Or(cmp.Compare(stats.Counts[b], stats.Counts[a]), cmp.Compare(a, b))
+		})
+		for _, key := range keys[maxStatsValues:] {
+			delete(stats.Counts, key)
+		}
+		stats.Truncated = true
+	}
+
+	stats.Memory = index.memory()
+	return stats, nil
+}
+
+// memory estimates the bytes used by the sorted values and the sub kd trees - the caller must hold the lock
+func (i *Index) memory() int64 {
+	memory := int64(cap(i.Sorted)) * int64(unsafe.Sizeof(sortValue{}))
+	for j := range i.Sorted {
+		memory += int64(len(i.Sorted[j].text))
+	}
+	nodes := 0
+	for _, node := range i.Entries {
+		node.Walk(func(*Vector.Vector) {
+			nodes++
+		})
+	}
+	// Every entry is a map key with a pointer to a sub kd tree
+	memory += int64(nodes)*int64(unsafe.Sizeof(Node.Node{})) + int64(len(i.Entries))*(16+8)
+	return memory
+}
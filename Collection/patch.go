@@ -0,0 +1,184 @@
+package Collection
+
+import (
+	"VreeDB/FileMapper"
+	"VreeDB/Filter"
+	"fmt"
+	"maps"
+	"strings"
+)
+
+// PayloadPatch changes single keys of payloads. The operations are applied in the order unset, set, append and
+// increment.
+type PayloadPatch struct {
+	Set       map[string]interface{} // keys set to the value
+	Unset     []string               // keys removed from the payload
+	Append    map[string]interface{} // values appended to array fields - arrays are appended element by element
+	Increment map[string]float64     // numeric fields incremented by the value - missing fields start at 0
+}
+
+// Validate checks if the patch changes anything
+func (p *PayloadPatch) Validate() error {
+	if len(p.Set) == 0 && len(p.Unset) == 0 && len(p.Append) == 0 && len(p.Increment) == 0 {
+		return fmt.Errorf("The patch has no operations")
+	}
+	return nil
+}
+
+// Apply returns a copy of the payload with the patch applied. Keys may be paths of nested objects separated by dots
+// like the paths of a Filter, a key that exists in the payload as it is wins over the path. Missing objects on the
+// path are created, elements of arrays cannot be patched.
+func (p *PayloadPatch) Apply(payload map[string]interface{}) (map[string]interface{}, error) {
+	patched := maps.Clone(payload)
+	if patched == nil {
+		patched = make(map[string]interface{})
+	}
+	for _, key := range p.Unset {
+		object, last, err := target(patched, key, false)
+		if err != nil {
+			return nil, err
+		} else if object != nil {
+			delete(object, last)
+		}
+	}
+	for key, value := range p.Set {
+		object, last, err := target(patched, key, true)
+		if err != nil {
+			return nil, err
+		}
+		object[last] = value
+	}
+	for key, value := range p.Append {
+		object, last, err := target(patched, key, true)
+		if err != nil {
+			return nil, err
+		}
+		var array []interface{}
+		switch current := object[last].(type) {
+		case nil:
+		case []interface{}:
+			array = append(array, current...)
+		default:
+			return nil, fmt.Errorf("Field %s is not an array", key)
+		}
+		if values, ok := value.([]interface{}); ok {
+			array = append(array, values...)
+		} else {
+			array = append(array, value)
+		}
+		object[last] = array
+	}
+	for key, value := range p.Increment {
+		object, last, err := target(patched, key, true)
+		if err != nil {
+			return nil, err
+		}
+		switch current := object[last].(type) {
+		case nil:
+			object[last] = value
+		case float64:
+			object[last] = current + value
+		case int:
+			object[last] = float64(current) + value
+		default:
+			return nil, fmt.Errorf("Field %s is not a number", key)
+		}
+	}
+	return patched, nil
+}
+
+// target returns the object of the patched payload that holds the last key of the path. The objects on the path
+// are copied, the payload read from the file must not be changed. Missing objects are created if create is true,
+// otherwise a nil object is returned.
+func target(payload map[string]interface{}, path string, create bool) (map[string]interface{}, string, error) {
+	if _, ok := payload[path]; ok || !strings.ContainsAny(path, ".[]") {
+		return payload, path, nil
+	} else if strings.ContainsAny(path, "[]") {
+		return nil, "", fmt.Errorf("Field %s: elements of arrays cannot be patched", path)
+	}
+	keys := strings.Split(path, ".")
+	object := payload
+	for i, key := range keys[:len(keys)-1] {
+		if key == "" {
+			return nil, "", fmt.Errorf("Field %s is not a valid path", path)
+		}
+		var child map[string]interface{}
+		switch value := object[key].(type) {
+		case nil:
+			if !create {
+				return nil, "", nil
+			}
+			child = make(map[string]interface{})
+		case map[string]interface{}:
+			child = maps.Clone(value)
+		default:
+			return nil, "", fmt.Errorf("Field %s is not an object", strings.Join(keys[:i+1], "."))
+		}
+		object[key] = child
+		object = child
+	}
+	if keys[len(keys)-1] == "" {
+		return nil, "", fmt.Errorf("Field %s is not a valid path", path)
+	}
+	return object, keys[len(keys)-1], nil
+}
+
+// PatchPayload applies the patch to the payloads of the vectors with the given ids or, without ids, of all vectors
+// that match the filter. All payloads are patched and checked before the first one is written, so an invalid patch
+// changes nothing. The payloads are written one by one - if writing one fails, the payloads before it stay patched.
+// It returns the ids of the patched vectors.
+func (c *Collection) PatchPayload(ids []string, filter *[]Filter.Filter, patch *PayloadPatch) ([]string, error) {
+	err := patch.Validate()
+	if err != nil {
+		return nil, err
+	} else if len(ids) == 0 && filter == nil {
+		return nil, fmt.Errorf("Either ids or a filter are needed")
+	}
+	c.Mut.Lock()
+	defer c.Mut.Unlock()
+
+	// Find the vectors
+	if len(ids) == 0 {
+		for id, vector := range *c.Space {
+			if !vector.IsDeleted() && Filter.Validate(filter, vector) {
+				ids = append(ids, id)
+			}
+		}
+	}
+	seen := make(map[string]bool, len(ids))
+	unique := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if vector, ok := (*c.Space)[id]; !ok || vector.IsDeleted() {
+			return nil, fmt.Errorf("Vector with ID %s does not exist", id)
+		} else if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	ids = unique
+
+	// Patch all payloads before anything is written
+	payloads := make([]map[string]interface{}, len(ids))
+	for i, id := range ids {
+		payload, err := FileMapper.Mapper.ReadPayload((*c.Space)[id].PayloadStart, c.Name)
+		if err != nil {
+			return nil, err
+		}
+		payloads[i], err = patch.Apply(*payload)
+		if err == nil {
+			err = c.CheckPayload(&payloads[i])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Vector with ID %s: %s", id, err.Error())
+		}
+	}
+
+	// Replace the payloads
+	for i, id := range ids {
+		err := c.upsert(id, nil, &payloads[i])
+		if err != nil {
+			return ids[:i], err
+		}
+	}
+	return ids, nil
+}
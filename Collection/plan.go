@@ -0,0 +1,148 @@
+package Collection
+
+import (
+	"VreeDB/Filter"
+	"VreeDB/Logger"
+	"VreeDB/Utils"
+	"VreeDB/Vector"
+	"strconv"
+)
+
+// maxCandidateShare is the largest share of the collection that is pre-selected from a payload Index, larger
+// selections cost more to build than the payload reads they save
+const maxCandidateShare = 0.5
+
+// Settings of the search planner
+const (
+	// planSampleSize is the number of vectors the filter is checked for to estimate its selectivity, smaller
+	// collections are always searched by brute force
+	planSampleSize = 256
+	// bruteForceShare is the selectivity up to which the matching vectors are searched by brute force, a filtered
+	// traversal of the vector index would visit most of the vectors to find enough matches
+	bruteForceShare = 0.05
+	// bruteForceCandidates is the number of pre-selected candidates that are always searched by brute force
+	bruteForceCandidates = 10000
+)
+
+// Strategies of a SearchPlan
+const (
+	// Traverse searches the vector index or kd tree and checks the filter for the visited vectors
+	Traverse = "traverse"
+	// BruteForce compares the target to every candidate that passes the filter
+	BruteForce = "brute_force"
+)
+
+// SearchPlan is the way a filtered search is done
+type SearchPlan struct {
+	Strategy string
+	// Filter holds the filters and a Filter.Candidates filter with the vectors pre-selected by the payload indexes,
+	// the search skips the payloads of all other vectors
+	Filter *[]Filter.Filter
+	// Candidates are the vectors pre-selected by the payload indexes, nil if all vectors are candidates
+	Candidates []*Vector.Vector
+	// Selectivity is the estimated share of the vectors that pass the filter
+	Selectivity float64
+}
+
+// PlanSearch estimates the selectivity of the filter and chooses the strategy of the search. The selectivity is
+// the share of the vectors pre-selected by the payload indexes or the share of a sample of vectors that pass the
+// filter. Selective filters are searched by brute force over the candidates, the others by a filtered traversal.
+// The caller must hold the read lock of the Collection.
+func (c *Collection) PlanSearch(filter *[]Filter.Filter) *SearchPlan {
+	plan := &SearchPlan{Strategy: Traverse, Filter: filter, Selectivity: 1}
+	if filter == nil || len(*filter) == 0 || len(*c.Space) == 0 {
+		return plan
+	}
+
+	// Use the pre-selected vectors of the payload indexes
+	if candidates := c.preselect(filter); candidates != nil {
+		planned := append([]Filter.Filter{Filter.Candidates(candidates)}, *filter...)
+		plan.Filter = &planned
+		plan.Candidates = make([]*Vector.Vector, 0, len(candidates))
+		for vector := range candidates {
+			plan.Candidates = append(plan.Candidates, vector)
+		}
+		plan.Selectivity = float64(len(candidates)) / float64(len(*c.Space))
+		if len(candidates) <= bruteForceCandidates || plan.Selectivity <= bruteForceShare {
+			plan.Strategy = BruteForce
+		}
+		return plan
+	}
+
+	// Otherwise check the filter for a sample of the vectors
+	if len(*c.Space) <= planSampleSize {
+		plan.Strategy = BruteForce
+		return plan
+	}
+	sampled, passed := 0, 0
+	for _, vector := range *c.Space {
+		if vector.IsDeleted() {
+			continue
+		}
+		if Filter.Validate(filter, vector) {
+			passed++
+		}
+		if sampled++; sampled == planSampleSize {
+			break
+		}
+	}
+	plan.Selectivity = float64(passed) / float64(max(1, sampled))
+	if plan.Selectivity <= bruteForceShare {
+		plan.Strategy = BruteForce
+	}
+	return plan
+}
+
+// Search returns the k nearest vectors to the target that pass the filter of the plan. Brute force plans compare
+// the target to every candidate, traverse plans call traverse with the filter of the plan. A traversal that found
+// less than k vectors is repeated by brute force, so k vectors are returned if enough vectors pass the filter.
+// The returned bool is true if the vectors were compared in full precision. The caller must hold the read lock.
+func (p *SearchPlan) Search(c *Collection, target *Vector.Vector, k int, traverse func(filter *[]Filter.Filter) []*Utils.HeapItem) ([]*Utils.HeapItem, bool) {
+	if p.Strategy == Traverse {
+		data := traverse(p.Filter)
+		if len(data) >= k || p.Filter == nil || len(*p.Filter) == 0 {
+			return data, false
+		}
+		Logger.Log.Log("Filtered traversal found "+strconv.Itoa(len(data))+" of "+strconv.Itoa(k)+" vectors, searching by brute force", "INFO")
+	}
+	if p.Candidates != nil {
+		return c.exactSearch(p.Candidates, target.Data, k, p.Filter), true
+	}
+	return c.ExactSearch(target.Data, k, p.Filter), true
+}
+
+// preselect returns the alive vectors that can pass all conditions on indexed keys or nil if there is no such
+// condition with a small enough selection
+func (c *Collection) preselect(filter *[]Filter.Filter) map[*Vector.Vector]bool {
+	if filter == nil || len(c.Indexes) == 0 {
+		return nil
+	}
+	limit := int(float64(len(*c.Space)) * maxCandidateShare)
+
+	var candidates map[*Vector.Vector]bool
+	for _, f := range *filter {
+		if f.IsGroup() {
+			continue
+		}
+		index := c.indexByKey(f.Field)
+		if index == nil {
+			continue
+		}
+		if size, ok := index.RangeSize(f.Op, f.Value); !ok || size > limit {
+			continue
+		}
+
+		// Keep the vectors that passed the conditions before
+		selected := make(map[*Vector.Vector]bool)
+		index.Range(f.Op, f.Value, func(vector *Vector.Vector) {
+			if candidates != nil && !candidates[vector] {
+				return
+			}
+			if !vector.IsDeleted() && (*c.Space)[vector.Id] == vector {
+				selected[vector] = true
+			}
+		})
+		candidates = selected
+	}
+	return candidates
+}
@@ -0,0 +1,278 @@
+package Collection
+
+import (
+	"VreeDB/FileMapper"
+	"VreeDB/Filter"
+	"VreeDB/Vector"
+	"cmp"
+	"container/heap"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Point is a stored vector with its payload
+type Point struct {
+	Id      string
+	Payload *map[string]interface{}
+	Vector  *[]float64
+}
+
+// GetPoints returns the points with the given ids in the order of the ids - unknown or deleted ids are skipped.
+// The vectors are only returned if getVectors is true.
+func (c *Collection) GetPoints(ids []string, getVectors bool) ([]*Point, error) {
+	c.Mut.RLock()
+	defer c.Mut.RUnlock()
+
+	points := make([]*Point, 0, len(ids))
+	for _, id := range ids {
+		vector, ok := (*c.Space)[id]
+		if !ok || vector.IsDeleted() {
+			continue
+		}
+		point, err := c.point(vector, getVectors)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, point)
+	}
+	return points, nil
+}
+
+// OrderBy sorts points by the value of a payload key - numbers, datetimes and strings are sorted in this order
+type OrderBy struct {
+	Key        string
+	Descending bool
+}
+
+// Scroll returns up to limit points that pass the filter in ascending id order, starting with the id offset.
+// An empty offset starts at the first id. The returned offset is the id to continue with, it is empty if there
+// are no more points. With an orderBy the points are sorted by the payload key instead, see scrollOrdered.
+func (c *Collection) Scroll(offset string, limit int, filter *[]Filter.Filter, orderBy *OrderBy, getVectors bool) ([]*Point, string, error) {
+	if limit < 1 {
+		return nil, "", fmt.Errorf("The limit must be at least 1")
+	}
+	c.Mut.RLock()
+	defer c.Mut.RUnlock()
+	if orderBy != nil {
+		return c.scrollOrdered(offset, limit, filter, orderBy, getVectors)
+	}
+
+	// Sort the ids to get a stable order
+	ids := make([]string, 0, len(*c.Space))
+	for id, vector := range *c.Space {
+		if !vector.IsDeleted() && id >= offset {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	points := make([]*Point, 0, min(limit, len(ids)))
+	for _, id := range ids {
+		vector := (*c.Space)[id]
+		if !Filter.Validate(filter, vector) {
+			continue
+		}
+		// The first matching point after the page is the next offset
+		if len(points) == limit {
+			return points, id, nil
+		}
+		point, err := c.point(vector, getVectors)
+		if err != nil {
+			return nil, "", err
+		}
+		points = append(points, point)
+	}
+	return points, "", nil
+}
+
+// point reads the payload and, if getVector is true, the data of the vector
+func (c *Collection) point(vector *Vector.Vector, getVector bool) (*Point, error) {
+	payload, err := FileMapper.Mapper.ReadPayload(vector.PayloadStart, c.Name)
+	if err != nil {
+		return nil, err
+	}
+	point := &Point{Id: vector.Id, Payload: payload}
+	if getVector {
+		point.Vector = vector.GetData()
+	}
+	return point, nil
+}
+
+// sortValue is the value of a point that is sorted by an OrderBy
+type sortValue struct {
+	vector *Vector.Vector
+	rank   int // numbers before datetimes before strings
+	number float64
+	time   time.Time
+	text   string
+}
+
+// newSortValue returns the sortValue of a payload value, it returns false if the value is not a number, datetime or
+// string. Without a vector the value is a filter value, which may be a time relative to now.
+func newSortValue(vector *Vector.Vector, value any) (sortValue, bool) {
+	sv := sortValue{vector: vector}
+	switch v := value.(type) {
+	case float64:
+		sv.number = v
+	case int:
+		sv.number = float64(v)
+	case string:
+		sv.rank, sv.text = 2, v
+		parse := Filter.Time
+		if vector == nil {
+			parse = Filter.OperandTime
+		}
+		if t, ok := parse(v); ok {
+			sv.rank, sv.time = 1, t
+		}
+	default:
+		return sv, false
+	}
+	return sv, true
+}
+
+// compare returns -1, 0 or 1 if the value is smaller, equal or greater than the other value
+func (s *sortValue) compare(other *sortValue) int {
+	switch {
+	case s.rank != other.rank:
+		return cmp.Compare(s.rank, other.rank)
+	case s.rank == 0:
+		return cmp.Compare(s.number, other.number)
+	case s.rank == 1:
+		return s.time.Compare(other.time)
+	}
+	return strings.Compare(s.text, other.text)
+}
+
+// scrollOrdered returns up to limit points that pass the filter sorted by the payload key of orderBy, ties are
+// sorted by id. The offset is a cursor that holds the sort value and the id of the first point of the page, the
+// returned offset is the cursor of the next page. Points without a number, datetime or string at the key are left
+// out, arrays are sorted by their first element. The caller must hold the read lock of the Collection.
+func (c *Collection) scrollOrdered(offset string, limit int, filter *[]Filter.Filter, orderBy *OrderBy, getVectors bool) ([]*Point, string, error) {
+	var cursor *sortValue
+	if offset != "" {
+		var err error
+		cursor, err = decodeCursor(offset)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	order := func(a, b *sortValue) int {
+		c := a.compare(b)
+		if orderBy.Descending {
+			c = -c
+		}
+		return cmp.Or(c, cmp.Compare(a.vector.Id, b.vector.Id))
+	}
+
+	// Keep the first limit+1 values from the cursor on, the last one is the cursor of the next page
+	page := &sortHeap{order: order}
+	for _, vector := range *c.Space {
+		if vector.IsDeleted() || !Filter.Validate(filter, vector) {
+			continue
+		}
+		payload, err := FileMapper.Mapper.ReadPayload(vector.PayloadStart, c.Name)
+		if err != nil {
+			return nil, "", err
+		}
+		keyValues := Filter.Values(*payload, orderBy.Key)
+		if len(keyValues) == 0 {
+			continue
+		}
+		value, ok := newSortValue(vector, keyValues[0])
+		if !ok || (cursor != nil && order(&value, cursor) < 0) {
+			continue
+		}
+		if page.Len() <= limit {
+			heap.Push(page, value)
+		} else if order(&value, &page.values[0]) < 0 {
+			page.values[0] = value
+			heap.Fix(page, 0)
+		}
+	}
+
+	// Sort the page
+	values := page.values
+	sort.Slice(values, func(i, j int) bool {
+		return order(&values[i], &values[j]) < 0
+	})
+
+	points := make([]*Point, 0, min(limit, len(values)))
+	for i := 0; i < len(values) && i < limit; i++ {
+		point, err := c.point(values[i].vector, getVectors)
+		if err != nil {
+			return nil, "", err
+		}
+		points = append(points, point)
+	}
+	if len(values) > limit {
+		next, err := encodeCursor(&values[limit])
+		return points, next, err
+	}
+	return points, "", nil
+}
+
+// sortHeap is a max heap of sortValues by the order of an ordered scroll
+type sortHeap struct {
+	values []sortValue
+	order  func(a, b *sortValue) int
+}
+
+func (h *sortHeap) Len() int           { return len(h.values) }
+func (h *sortHeap) Less(i, j int) bool { return h.order(&h.values[i], &h.values[j]) > 0 }
+func (h *sortHeap) Swap(i, j int)      { h.values[i], h.values[j] = h.values[j], h.values[i] }
+func (h *sortHeap) Push(x any)         { h.values = append(h.values, x.(sortValue)) }
+func (h *sortHeap) Pop() any {
+	last := h.values[len(h.values)-1]
+	h.values = h.values[:len(h.values)-1]
+	return last
+}
+
+// scrollCursor is the position of an ordered scroll - the sort value and the id of a point
+type scrollCursor struct {
+	Rank   int     `json:"r"`
+	Number float64 `json:"n,omitempty"`
+	Text   string  `json:"t,omitempty"`
+	Id     string  `json:"id"`
+}
+
+// encodeCursor returns the offset of an ordered scroll that starts at the value
+func encodeCursor(value *sortValue) (string, error) {
+	cursor := scrollCursor{Rank: value.rank, Number: value.number, Text: value.text, Id: value.vector.Id}
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor returns the sort value of the offset of an ordered scroll, the id of the point is held by a
+// vector that is not part of the Collection
+func decodeCursor(offset string) (*sortValue, error) {
+	invalid := fmt.Errorf("The offset of an ordered scroll must be the next_offset of the previous page")
+	data, err := base64.RawURLEncoding.DecodeString(offset)
+	if err != nil {
+		return nil, invalid
+	}
+	var cursor scrollCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, invalid
+	}
+	value := &sortValue{vector: &Vector.Vector{Id: cursor.Id}, rank: cursor.Rank, number: cursor.Number, text: cursor.Text}
+	switch cursor.Rank {
+	case 0, 2:
+	case 1:
+		t, ok := Filter.Time(cursor.Text)
+		if !ok {
+			return nil, invalid
+		}
+		value.time = t
+	default:
+		return nil, invalid
+	}
+	return value, nil
+}
@@ -0,0 +1,169 @@
+package Collection
+
+import (
+	"VreeDB/ArgsParser"
+	"VreeDB/Logger"
+	"VreeDB/Utils"
+	"VreeDB/Vector"
+	"math"
+	"sort"
+)
+
+// RescoreOversampling is the factor of candidates that are searched in low precision before they are rescored
+const RescoreOversampling = 4
+
+// quantizerMargin widens the range of a new quantizer, so it does not have to be retrained for every new extreme
+const quantizerMargin = 0.1
+
+// lowPrecision returns the distance function for the given precision. Int8 vectors without codes (the quantizer
+// was not trained when they were loaded) fall back to the full precision.
+func lowPrecision(precision Vector.Precision, full, float32Func, int8Func func(*Vector.Vector, *Vector.Vector) (float64, error)) func(*Vector.Vector, *Vector.Vector) (float64, error) {
+	switch precision {
+	case Vector.Float32:
+		return float32Func
+	case Vector.Int8:
+		return func(v, target *Vector.Vector) (float64, error) {
+			if v.Quant == nil {
+				return full(v, target)
+			}
+			return int8Func(v, target)
+		}
+	}
+	return full
+}
+
+// PrepareVector sets the precision and the quantizer of the collection on the vector. The first vector trains the
+// quantizer. If the data of a new vector is outside of the range of the quantizer its codes are clamped to the range
+// and the quantizer is retrained in the background - the caller must hold the lock.
+func (c *Collection) PrepareVector(v *Vector.Vector) {
+	v.Precision = c.Precision
+	if c.Precision != Vector.Int8 {
+		return
+	}
+	if v.Data != nil && c.Quantizer == nil {
+		c.retrainQuantizer(v.Data)
+	} else if v.Data != nil && !c.Quantizer.Covers(v.Data) {
+		c.scheduleRetrain()
+	}
+	v.Quantizer = c.Quantizer
+}
+
+// PrepareTarget converts a search target to the precision the distance functions of the collection expect
+func (c *Collection) PrepareTarget(target *Vector.Vector) {
+	if c.Precision != Vector.Float64 && target.Data32 == nil {
+		target.ToFloat32()
+	}
+}
+
+// scheduleRetrain retrains the quantizer in the background. Only one retrain runs at a time, widenings while it
+// runs are covered by one more retrain.
+func (c *Collection) scheduleRetrain() {
+	c.quantizerStale.Store(true)
+	if !c.retraining.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		for c.quantizerStale.Swap(false) {
+			c.RetrainQuantizer()
+		}
+		c.retraining.Store(false)
+		// A widening between the last retrain and the reset of the flag
+		if c.quantizerStale.Load() {
+			c.scheduleRetrain()
+		}
+	}()
+}
+
+// RetrainQuantizer trains a new quantizer on the range of the collection and quantizes all vectors again. The
+// data is read while the collection is read locked, only the swap of the codes holds the write lock.
+func (c *Collection) RetrainQuantizer() {
+	c.Mut.RLock()
+	quantizer := c.newQuantizer(nil)
+	codes := make(map[*Vector.Vector][]int8, len(*c.Space))
+	for _, v := range *c.Space {
+		if v.IsDeleted() || v.DataStart < 0 {
+			continue
+		}
+		codes[v] = quantizer.Quantize(*v.GetData())
+	}
+	c.Mut.RUnlock()
+
+	c.Mut.Lock()
+	defer c.Mut.Unlock()
+	c.Quantizer = quantizer
+	for _, v := range *c.Space {
+		if v.IsDeleted() || v.DataStart < 0 {
+			continue
+		}
+		if code, ok := codes[v]; ok {
+			v.SetCodes(quantizer, code)
+			continue
+		}
+		// Added while the data was read
+		v.Quantizer = quantizer
+		v.Unindex()
+	}
+
+	// Save the new range
+	err := c.writeConfig(*ArgsParser.Ap.FileStore + c.Name + ".json")
+	if err != nil {
+		Logger.Log.Log("Error writing collection config: "+err.Error(), "ERROR")
+	}
+}
+
+// newQuantizer returns a quantizer for the range of the collection (and the given data) widened by the
+// quantizerMargin - the caller must hold the lock
+func (c *Collection) newQuantizer(data []float64) *Vector.Quantizer {
+	min := make([]float64, c.VectorDimension)
+	max := make([]float64, c.VectorDimension)
+	for i := range min {
+		min[i], max[i] = c.MinVector.Data[i], c.MaxVector.Data[i]
+		if data != nil {
+			min[i], max[i] = math.Min(min[i], data[i]), math.Max(max[i], data[i])
+		}
+		margin := (max[i] - min[i]) * quantizerMargin
+		min[i], max[i] = min[i]-margin, max[i]+margin
+	}
+	return Vector.NewQuantizer(min, max)
+}
+
+// retrainQuantizer trains a new quantizer on the range of the collection (and the given data) and quantizes
+// all vectors again from their full precision data - the caller must hold the lock
+func (c *Collection) retrainQuantizer(data []float64) {
+	c.Quantizer = c.newQuantizer(data)
+
+	// Quantize the vectors again
+	for _, v := range *c.Space {
+		if v.IsDeleted() || v.DataStart < 0 {
+			continue
+		}
+		v.Quantizer = c.Quantizer
+		v.Unindex()
+	}
+
+	// Save the new range
+	err := c.writeConfig(*ArgsParser.Ap.FileStore + c.Name + ".json")
+	if err != nil {
+		Logger.Log.Log("Error writing collection config: "+err.Error(), "ERROR")
+	}
+}
+
+// Rescore calculates the distances of the candidates in full precision and returns the k nearest
+func (c *Collection) Rescore(candidates []*Utils.HeapItem, target *Vector.Vector, k int) []*Utils.HeapItem {
+	for _, item := range candidates {
+		data := item.Node.Vector.GetData()
+		distance, err := c.FullDistanceFunc(&Vector.Vector{Data: *data, Length: c.VectorDimension}, target)
+		if err != nil {
+			Logger.Log.Log("Error rescoring vector: "+err.Error(), "ERROR")
+			continue
+		}
+		item.Distance = distance
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Distance < candidates[j].Distance
+	})
+	if len(candidates) > k {
+		candidates = candidates[:k]
+	}
+	return candidates
+}
@@ -0,0 +1,227 @@
+package Collection
+
+import (
+	"VreeDB/ArgsParser"
+	"VreeDB/FileMapper"
+	"VreeDB/Filter"
+	"VreeDB/Utils"
+	"VreeDB/Vector"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Types of the fields of a payload schema
+const (
+	FieldString   = "string"
+	FieldNumber   = "number"
+	FieldBool     = "bool"
+	FieldDatetime = "datetime" // a RFC3339 string
+	FieldGeo      = "geo"      // a {"lat": .., "lon": ..} object
+	FieldArray    = "array"
+	FieldObject   = "object"
+)
+
+// maxReportedErrors limits the number of points a BatchError or a schema check reports
+const maxReportedErrors = 100
+
+// PointError is the error of a single point of a batch
+type PointError struct {
+	Index int // -1 for stored points
+	Id    string
+	Error string
+}
+
+// BatchError lists the invalid points of a batch
+type BatchError struct {
+	Points []PointError
+}
+
+// Error returns the errors of the points
+func (b *BatchError) Error() string {
+	errors := make([]string, len(b.Points))
+	for i, p := range b.Points {
+		if p.Index < 0 {
+			errors[i] = fmt.Sprintf("point %s: %s", p.Id, p.Error)
+		} else {
+			errors[i] = fmt.Sprintf("point %d (%s): %s", p.Index, p.Id, p.Error)
+		}
+	}
+	return fmt.Sprintf("%d invalid points: %s", len(b.Points), strings.Join(errors, "; "))
+}
+
+// ValidateSchema checks the names and the types of the fields of a schema
+func ValidateSchema(schema []Utils.SchemaField) error {
+	names := make(map[string]bool, len(schema))
+	for _, field := range schema {
+		if field.Name == "" {
+			return fmt.Errorf("Schema fields need a name")
+		} else if names[field.Name] {
+			return fmt.Errorf("Schema field %s is declared twice", field.Name)
+		}
+		names[field.Name] = true
+		switch field.Type {
+		case FieldString, FieldNumber, FieldBool, FieldDatetime, FieldGeo, FieldArray, FieldObject:
+		default:
+			return fmt.Errorf("Schema field %s has the unknown type %s - use string, number, bool, datetime, geo, array or object", field.Name, field.Type)
+		}
+		if field.Indexed && field.Type != FieldString && field.Type != FieldNumber && field.Type != FieldDatetime &&
+			field.Type != FieldArray {
+			return fmt.Errorf("Schema field %s of type %s can not be indexed", field.Name, field.Type)
+		}
+	}
+	return nil
+}
+
+// CheckPayload returns an error if the payload does not match the schema of the collection. Fields that are not in
+// the schema are not checked, null fields are missing.
+func (c *Collection) CheckPayload(payload *map[string]interface{}) error {
+	return checkPayload(c.Schema, payload)
+}
+
+// checkPayload returns an error if the payload does not match the schema
+func checkPayload(schema []Utils.SchemaField, payload *map[string]interface{}) error {
+	for _, field := range schema {
+		var value interface{}
+		ok := false
+		if payload != nil {
+			value, _, ok = Filter.Lookup(*payload, field.Name)
+		}
+		if !ok || value == nil {
+			if field.Required {
+				return fmt.Errorf("Field %s is required", field.Name)
+			}
+			continue
+		}
+		if !hasType(value, field.Type) {
+			return fmt.Errorf("Field %s must be of type %s", field.Name, field.Type)
+		}
+	}
+	return nil
+}
+
+// hasType returns true if the value is of the schema type
+func hasType(value any, fieldType string) bool {
+	switch v := value.(type) {
+	case string:
+		if fieldType == FieldDatetime {
+			_, err := time.Parse(time.RFC3339Nano, v)
+			return err == nil
+		}
+		return fieldType == FieldString
+	case float64, float32, int, int64:
+		return fieldType == FieldNumber
+	case bool:
+		return fieldType == FieldBool
+	case []interface{}:
+		return fieldType == FieldArray
+	case map[string]interface{}:
+		if fieldType == FieldGeo {
+			_, _, ok := Filter.GeoPoint(v)
+			return ok
+		}
+		return fieldType == FieldObject
+	}
+	return false
+}
+
+// checkBatch returns a BatchError with the vectors that can not be inserted - the caller must hold a lock of the
+// Collection
+func (c *Collection) checkBatch(vectors []*Vector.Vector) error {
+	batchErr := &BatchError{}
+	ids := make(map[string]bool, len(vectors))
+	for i, vector := range vectors {
+		var err error
+		if vector.Length != c.VectorDimension {
+			err = fmt.Errorf("Vector length is %d, expected %d", vector.Length, c.VectorDimension)
+		} else if c.CheckID(vector.Id) || ids[vector.Id] {
+			err = fmt.Errorf("Vector with ID %s already exists", vector.Id)
+		} else {
+			err = c.CheckPayload(vector.Payload)
+		}
+		ids[vector.Id] = true
+		if err != nil && len(batchErr.Points) < maxReportedErrors {
+			batchErr.Points = append(batchErr.Points, PointError{Index: i, Id: vector.Id, Error: err.Error()})
+		}
+	}
+	if len(batchErr.Points) > 0 {
+		return batchErr
+	}
+	return nil
+}
+
+// CheckBatch returns a BatchError with the vectors that can not be inserted
+func (c *Collection) CheckBatch(vectors []*Vector.Vector) error {
+	c.Mut.RLock()
+	defer c.Mut.RUnlock()
+	return c.checkBatch(vectors)
+}
+
+// SetSchema replaces the payload schema of the collection. All stored payloads must match the new schema, otherwise
+// a BatchError with the ids of the mismatching vectors is returned. Payload indexes are created for indexed fields,
+// the schema is only set when they are created and saved.
+func (c *Collection) SetSchema(schema []Utils.SchemaField) error {
+	err := ValidateSchema(schema)
+	if err != nil {
+		return err
+	}
+
+	c.Mut.Lock()
+	defer c.Mut.Unlock()
+	// Check the stored payloads
+	batchErr := &BatchError{}
+	for id, vector := range *c.Space {
+		if vector.IsDeleted() {
+			continue
+		}
+		payload, err := FileMapper.Mapper.ReadPayload(vector.PayloadStart, c.Name)
+		if err != nil {
+			return err
+		}
+		if err := checkPayload(schema, payload); err != nil && len(batchErr.Points) < maxReportedErrors {
+			batchErr.Points = append(batchErr.Points, PointError{Index: -1, Id: id, Error: err.Error()})
+		}
+	}
+	if len(batchErr.Points) > 0 {
+		return batchErr
+	}
+
+	// Index the indexed fields
+	created := make(map[string]*Index)
+	for _, field := range schema {
+		if _, ok := c.Indexes[field.Name]; field.Indexed && !ok {
+			// Numbers and datetimes have many different values and are queried by ranges
+			indexType := ValueIndex
+			if field.Type == FieldNumber || field.Type == FieldDatetime {
+				indexType = RangeIndex
+			}
+			index, err := NewIndex(field.Name, indexType, c.Space, c.Name)
+			if err != nil {
+				return err
+			}
+			created[field.Name] = index
+		}
+	}
+	if len(created) > 0 {
+		for name, index := range created {
+			c.Indexes[name] = index
+		}
+		err = c.SaveIndexes()
+		if err != nil {
+			for name := range created {
+				delete(c.Indexes, name)
+			}
+			return err
+		}
+	}
+
+	// Save the schema
+	old := c.Schema
+	c.Schema = schema
+	err = c.writeConfig(*ArgsParser.Ap.FileStore + c.Name + ".json")
+	if err != nil {
+		c.Schema = old
+		return err
+	}
+	return nil
+}
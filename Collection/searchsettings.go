@@ -0,0 +1,45 @@
+package Collection
+
+import (
+	"VreeDB/Filter"
+	"VreeDB/Utils"
+	"fmt"
+	"strings"
+)
+
+// ValidateSearchSettings checks the search accuracy and the maximum number of distance calculations of a search
+func ValidateSearchSettings(accuracy float64, maxChecks int) error {
+	if accuracy < 0 || accuracy > 1 {
+		return fmt.Errorf("search_accuracy must be between 0 and 1")
+	} else if maxChecks < 0 {
+		return fmt.Errorf("max_checks must not be negative")
+	}
+	return nil
+}
+
+// SetSearchSettings sets the search accuracy, the maximum number of distance calculations and the test for the
+// other side of a split the KD-Tree of the collection is searched with if a request does not set them
+func (c *Collection) SetSearchSettings(accuracy float64, maxChecks int, axisRange bool) error {
+	err := ValidateSearchSettings(accuracy, maxChecks)
+	if err != nil {
+		return err
+	}
+	c.Mut.Lock()
+	c.SearchAccuracy, c.MaxChecks, c.AxisRange = accuracy, maxChecks, axisRange
+	c.Mut.Unlock()
+	return c.WriteConfig()
+}
+
+// NewSearchUnit returns a SearchUnit for the KD-Tree with the settings of the request - unset settings are taken
+// from the collection
+func (c *Collection) NewSearchUnit(filter *[]Filter.Filter, params Utils.SearchParams) *Utils.SearchUnit {
+	accuracy, maxChecks := c.SearchAccuracy, c.MaxChecks
+	if params.Accuracy > 0 {
+		accuracy = params.Accuracy
+	}
+	if params.MaxChecks > 0 {
+		maxChecks = params.MaxChecks
+	}
+	return Utils.NewSearchUnit(filter, accuracy, maxChecks, strings.ToLower(c.DistanceFuncName) == "euclid",
+		c.AxisRange || params.AxisRange)
+}
@@ -0,0 +1,136 @@
+package Collection
+
+import (
+	"VreeDB/FileMapper"
+	"VreeDB/Logger"
+	"VreeDB/Vector"
+	"VreeDB/Wal"
+	"fmt"
+)
+
+// Upsert inserts a vector or replaces the vector with the same ID. A nil data or payload keeps the one of the
+// replaced vector. The replaced vector is marked as deleted and removed from the payload Indexes, the new one is
+// searchable and in the payload Indexes as soon as Upsert returns.
+func (c *Collection) Upsert(id string, data []float64, payload *map[string]interface{}) error {
+	c.Mut.Lock()
+	defer c.Mut.Unlock()
+	return c.upsert(id, data, payload)
+}
+
+// upsert inserts or replaces the vector - the caller must hold the write lock
+func (c *Collection) upsert(id string, data []float64, payload *map[string]interface{}) error {
+	old, exists := (*c.Space)[id]
+	exists = exists && !old.IsDeleted()
+	if data == nil && !exists {
+		return fmt.Errorf("Vector with ID %s does not exist", id)
+	} else if data != nil && len(data) != c.VectorDimension {
+		return fmt.Errorf("Vector length is %d, expected %d", len(data), c.VectorDimension)
+	}
+
+	// Keep the data and the payload that are not replaced
+	keepData := data == nil
+	if keepData {
+		data = *old.GetData()
+	}
+	var oldPayload *map[string]interface{}
+	if exists && (payload == nil || len(c.Indexes) > 0) {
+		p, err := FileMapper.Mapper.ReadPayload(old.PayloadStart, c.Name)
+		if err != nil {
+			return err
+		}
+		oldPayload = p
+	}
+	if payload == nil {
+		payload = oldPayload
+	}
+	if err := c.CheckPayload(payload); err != nil {
+		return fmt.Errorf("Vector with ID %s: %s", id, err.Error())
+	}
+	vector := Vector.NewVector(id, data, payload, c.Name)
+	c.PrepareVector(vector)
+
+	// Log the upsert before it is applied - the data is not logged again if it stays in place
+	var err error
+	if keepData {
+		_, err = c.Wal.Append(Wal.Payload, []string{id}, nil, payload)
+	} else {
+		_, err = c.Wal.Append(Wal.Upsert, []string{id}, data, payload)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Write the payload and the data if it changed
+	if keepData {
+		err = vector.PersistPayload(old)
+	} else {
+		err = vector.Persist()
+	}
+	if err != nil {
+		return err
+	}
+
+	// The replaced vector is deleted - the KD-Tree skips it until the DeleteWatcher rebuilds it
+	if exists {
+		err = FileMapper.Mapper.SaveVectorWriteAt(-1, -1, c.Name, old.SaveVectorPosition)
+		if err != nil {
+			return err
+		}
+		old.Delete()
+		(*c.DeletedVectors)[id] = old
+		c.DeadBytes += int64(old.PLength)
+		if !keepData {
+			c.DeadBytes += int64(old.CLength)
+		}
+		if c.VectorIndex != nil {
+			c.VectorIndex.Remove(old)
+		}
+		err = c.uncheckIndex(old, oldPayload)
+		if err != nil {
+			Logger.Log.Log("Error removing vector "+id+" from the indexes: "+err.Error(), "ERROR")
+		}
+	}
+
+	// Insert the vector into the index and set the diagonal Space
+	c.addToIndex(vector)
+	(*c.Space)[id] = vector
+
+	// Save the vector to the meta file
+	pos, err := FileMapper.Mapper.SaveVectorWriter(vector.Id, vector.DataStart, vector.PayloadStart, vector.CLength, vector.PLength, c.Name)
+	if err != nil {
+		Logger.Log.Log("Error saving vector to file: "+err.Error(), "ERROR")
+		return err
+	}
+	vector.SaveVectorPosition = pos
+
+	// Checkpoint the wal if it grew too large
+	c.checkpointWal()
+
+	// Compact the collection files if there is too much dead data - Compact will wait for our lock
+	if exists && c.NeedsCompaction() {
+		go func() {
+			err := c.Compact()
+			if err != nil {
+				Logger.Log.Log("Error compacting collection "+c.Name+": "+err.Error(), "ERROR")
+			}
+		}()
+	}
+
+	// Set classifier ready to true
+	c.ClassifierReady = true
+
+	// Check if there is an Index with a key from the Payload - if so add the vector to the Index
+	err = c.CheckIndex(vector)
+	if err != nil {
+		Logger.Log.Log("Error indexing vector "+id+": "+err.Error(), "ERROR")
+	}
+	return nil
+}
+
+// UpdatePayload replaces the payload of an existing vector - the data of the vector stays in place
+func (c *Collection) UpdatePayload(id string, payload *map[string]interface{}) error {
+	if payload == nil {
+		payload = &map[string]interface{}{}
+	}
+	return c.Upsert(id, nil, payload)
+}
@@ -0,0 +1,184 @@
+package Collection
+
+import (
+	"VreeDB/ArgsParser"
+	"VreeDB/Filter"
+	"VreeDB/Hnsw"
+	"VreeDB/Ivf"
+	"VreeDB/Logger"
+	"VreeDB/Pq"
+	"VreeDB/Utils"
+	"VreeDB/Vector"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// KDTree is the index type of collections that are searched with the KD-Tree
+const KDTree = "kdtree"
+
+// VectorIndex is an index the nearest neighbours of a collection are searched with instead of the KD-Tree
+type VectorIndex interface {
+	// Insert adds a vector to the index
+	Insert(v *Vector.Vector)
+	// Remove removes a deleted vector from the index
+	Remove(v *Vector.Vector)
+	// Search returns the k nearest vectors to the target that pass the filter
+	Search(target *Vector.Vector, k int, filter *[]Filter.Filter, params Utils.SearchParams) []*Utils.HeapItem
+	// Train trains the index on the given vectors
+	Train(vectors []*Vector.Vector) error
+	// InMemory returns true if the index needs the data of the vectors in memory
+	InMemory() bool
+	// Save writes the index next to the collection files
+	Save() error
+	// Delete removes the file of the index
+	Delete() error
+}
+
+// ValidateVectorIndex checks if a collection with the given dimension and precision can use the index type and parameters
+func ValidateVectorIndex(indexType string, params map[string]int, dimension int, precision Vector.Precision) error {
+	switch strings.ToLower(indexType) {
+	case "", KDTree:
+		if len(params) > 0 {
+			return fmt.Errorf("The kdtree index has no parameters")
+		}
+	case "pq":
+		if precision != Vector.Float64 {
+			return fmt.Errorf("pq collections keep codes instead of the vectors, the precision must be float64")
+		}
+		_, _, _, err := Pq.CheckParams(dimension, params)
+		return err
+	case "hnsw":
+		_, _, _, err := Hnsw.CheckParams(params)
+		return err
+	case "ivf":
+		_, _, err := Ivf.CheckParams(params)
+		return err
+	default:
+		return fmt.Errorf("Unknown index type %s - use kdtree, pq, hnsw or ivf", indexType)
+	}
+	return nil
+}
+
+// SetVectorIndex sets the index type of the collection. A saved index of the collection is loaded, otherwise a new
+// one is created with the given parameters.
+func (c *Collection) SetVectorIndex(indexType string, params map[string]int) error {
+	err := ValidateVectorIndex(indexType, params, c.VectorDimension, c.Precision)
+	if err != nil {
+		return err
+	}
+	c.IndexType, c.IndexParams = strings.ToLower(indexType), params
+	if c.IndexType == "" {
+		c.IndexType = KDTree
+	}
+
+	path := *ArgsParser.Ap.FileStore + c.Name + "_" + c.IndexType + ".gob"
+	switch c.IndexType {
+	case "pq":
+		index, err := Pq.Load(path, c.FullDistanceFunc)
+		if os.IsNotExist(err) {
+			index, err = Pq.New(path, c.VectorDimension, params, c.DistanceFuncName != "euclid", c.FullDistanceFunc)
+		}
+		if err != nil {
+			return err
+		}
+		c.VectorIndex = index
+	case "hnsw":
+		index, err := Hnsw.Load(path, c.DistanceFunc, c.searchTarget)
+		if os.IsNotExist(err) {
+			index, err = Hnsw.New(path, params, c.DistanceFunc, c.searchTarget)
+		}
+		if err != nil {
+			return err
+		}
+		c.VectorIndex = index
+	case "ivf":
+		index, err := Ivf.Load(path, c.DistanceFunc)
+		if os.IsNotExist(err) {
+			index, err = Ivf.New(path, params, c.DistanceFuncName != "euclid", c.DistanceFunc)
+		}
+		if err != nil {
+			return err
+		}
+		c.VectorIndex = index
+	}
+	return nil
+}
+
+// TrainIndex trains the vector index of the collection on the vectors of its Space and saves it
+func (c *Collection) TrainIndex() error {
+	if c.VectorIndex == nil {
+		return fmt.Errorf("Collection %s has no vector index to train", c.Name)
+	}
+	c.Mut.RLock()
+	vectors := make([]*Vector.Vector, 0, len(*c.Space))
+	for _, v := range *c.Space {
+		if !v.IsDeleted() {
+			vectors = append(vectors, v)
+		}
+	}
+	c.Mut.RUnlock()
+
+	err := c.VectorIndex.Train(vectors)
+	if err != nil {
+		return err
+	}
+	return c.VectorIndex.Save()
+}
+
+// reclusterIndex trains an ivf index again - its lists are clustered on the vectors of the collection
+func (c *Collection) reclusterIndex() {
+	if _, ok := c.VectorIndex.(*Ivf.IVF); !ok {
+		return
+	}
+	err := c.TrainIndex()
+	if err != nil {
+		Logger.Log.Log("Error clustering ivf index of collection "+c.Name+": "+err.Error(), "ERROR")
+	}
+}
+
+// KeepsData returns true if the vectors of the collection are kept in memory
+func (c *Collection) KeepsData() bool {
+	return c.VectorIndex == nil || c.VectorIndex.InMemory()
+}
+
+// addToIndex updates the diagonal space with the vector and inserts it into the vector index or the KD-Tree
+func (c *Collection) addToIndex(v *Vector.Vector) {
+	if c.VectorIndex == nil {
+		c.Nodes.Insert(v)
+		c.SetDiaSpace(c.fullVector(v))
+		return
+	}
+	c.SetDiaSpace(c.fullVector(v))
+	c.VectorIndex.Insert(v)
+}
+
+// fullVector returns the vector with its data - vectors that are only in the file are read from it, so are int8
+// vectors, their codes are clamped to the range of the quantizer
+func (c *Collection) fullVector(v *Vector.Vector) *Vector.Vector {
+	if v.Data != nil || v.Data32 != nil {
+		return v
+	}
+	return &Vector.Vector{Data: *v.GetData(), Length: c.VectorDimension}
+}
+
+// searchTarget returns the vector as a search target in the precision of the collection
+func (c *Collection) searchTarget(v *Vector.Vector) *Vector.Vector {
+	if c.Precision == Vector.Float64 && v.Data != nil {
+		return v
+	}
+	target := &Vector.Vector{Data: *v.GetData(), Length: c.VectorDimension}
+	c.PrepareTarget(target)
+	return target
+}
+
+// saveVectorIndex saves the vector index of the collection (if any)
+func (c *Collection) saveVectorIndex() {
+	if c.VectorIndex == nil {
+		return
+	}
+	err := c.VectorIndex.Save()
+	if err != nil {
+		Logger.Log.Log("Error saving vector index of collection "+c.Name+": "+err.Error(), "ERROR")
+	}
+}
@@ -11,7 +11,6 @@ import (
 	"io"
 	"os"
 	"sync"
-	"syscall"
 )
 
 type SaveVector struct {
@@ -19,15 +18,17 @@ type SaveVector struct {
 	DataStart          int64
 	PayloadStart       int64
 	SaveVectorPosition int64
+	DataLength         int64
+	PayloadLength      int64
 }
 
 type FileMapper struct {
 	CollectionNames []string
 	FileName        map[string]string
-	File            map[string]*os.File
 	Mut             map[string]*sync.RWMutex
-	MappedData      map[string][]byte
-	Mapped          map[string]bool
+	Stores          map[string]*SegmentStore
+	Formats         map[string]int
+	Cache           *PayloadCache
 }
 
 // the filemapper is a singleton
@@ -39,10 +40,10 @@ func init() {
 	Mapper = &FileMapper{}
 	// init the maps
 	Mapper.FileName = make(map[string]string)
-	Mapper.File = make(map[string]*os.File)
 	Mapper.Mut = make(map[string]*sync.RWMutex)
-	Mapper.MappedData = make(map[string][]byte)
-	Mapper.Mapped = make(map[string]bool)
+	Mapper.Stores = make(map[string]*SegmentStore)
+	Mapper.Formats = make(map[string]int)
+	Mapper.Cache = NewPayloadCache(int64(*ArgsParser.Ap.PayloadCache) * 1024 * 1024)
 }
 
 func (f *FileMapper) Start(collections []string) {
@@ -86,53 +87,60 @@ func (f *FileMapper) GetCompressedBuffer(arr []float64) (bytes.Buffer, error) {
 	return buf, nil
 }
 
-// WriteVector will write data to the file
+// WriteVector will write data to the file and returns the start position and the length of the written data
 func (f *FileMapper) WriteVector(arr []float64, collection string) (int64, int, error) {
-	// Lock the file for writing
-	f.Mut[collection].Lock()
-	defer f.Mut[collection].Unlock()
-	// Unmap the file from memory
-	f.Unmap(collection)
-	// open the file again for writing und append
-	file, err := os.OpenFile(f.FileName[collection], os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		Logger.Log.Log("Error opening file: "+err.Error(), "ERROR")
-		// Here we panic because we can't continue without the file
-		panic(err)
-	}
-	f.File[collection] = file
-	defer f.File[collection].Close()
+	// Writers only need the read lock - the store serializes the appends itself
+	f.Mut[collection].RLock()
+	defer f.Mut[collection].RUnlock()
 
-	// Get the Start position
-	start, err := f.File[collection].Seek(0, io.SeekEnd)
+	// Encode []float64
+	data, err := f.encodeVector(arr, f.Formats[collection])
 	if err != nil {
-		Logger.Log.Log("Error seeking to end of file: "+err.Error(), "ERROR")
-		// Here we panic because we can't continue without the start position
-		panic(err)
+		return 0, 0, err
 	}
-
-	// Compress []float64
-	buf, err := f.GetCompressedBuffer(arr)
+	offsets, err := f.Stores[collection].Append([][]byte{data})
 	if err != nil {
 		return 0, 0, err
 	}
+	return offsets[0], len(data), nil
+}
 
-	// Write the data to the file
-	n, err := f.File[collection].Write(buf.Bytes())
-	if err != nil {
-		Logger.Log.Log("Error writing to file: "+err.Error(), "ERROR")
-		// Here we panic because we can't continue without writing to the file
-		panic(err)
+// WritePoints writes the data and the payloads of many vectors with a single append. The returned
+// SaveVectors hold the positions and lengths in the same order, SaveVectorPosition is not set.
+func (f *FileMapper) WritePoints(arrs [][]float64, payloads []*map[string]interface{}, collection string) ([]SaveVector, error) {
+	if len(arrs) != len(payloads) {
+		return nil, fmt.Errorf("got %d vectors but %d payloads", len(arrs), len(payloads))
 	}
 
-	// Close the file and reopen it
-	err = f.File[collection].Close()
+	f.Mut[collection].RLock()
+	defer f.Mut[collection].RUnlock()
 
-	// Map the file again
-	f.MapFile(collection)
+	// Encode everything before the store is touched
+	records := make([][]byte, 0, 2*len(arrs))
+	for i := range arrs {
+		data, err := f.encodeVector(arrs[i], f.Formats[collection])
+		if err != nil {
+			return nil, err
+		}
+		encoded, err := f.encodePayload(payloads[i])
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, data, encoded)
+	}
+
+	// Append the records
+	offsets, err := f.Stores[collection].Append(records)
+	if err != nil {
+		return nil, err
+	}
 
-	// Return the start position and the length of the array
-	return start, n, err
+	result := make([]SaveVector, len(arrs))
+	for i := range result {
+		result[i] = SaveVector{DataStart: offsets[2*i], PayloadStart: offsets[2*i+1],
+			DataLength: int64(len(records[2*i])), PayloadLength: int64(len(records[2*i+1]))}
+	}
+	return result, nil
 }
 
 // ReadVector will read data from the file
@@ -140,39 +148,28 @@ func (f *FileMapper) ReadVector(start int64, length int, collection string) *[]f
 	// Lock the file for reading
 	f.Mut[collection].RLock()
 	defer f.Mut[collection].RUnlock()
+	return f.decodeVector(start, length, collection)
+}
 
-	// if not mapped we map it
-	if !f.Mapped[collection] {
-		f.MapFile(collection)
+// decodeVector decodes the vector at the given position of the mapped file - the caller must hold the lock
+func (f *FileMapper) decodeVector(start int64, length int, collection string) *[]float64 {
+	// Get the mapped data from the start position on
+	data, err := f.Stores[collection].Slice(start)
+	if err != nil {
+		Logger.Log.Log("Error reading vector: "+err.Error(), "ERROR")
+		arr := make([]float64, length)
+		return &arr
 	}
 
-	// Create the array
-	arr := make([]float64, length)
-
-	// Check if the slice is not empty
-	if len(f.MappedData[collection]) > 0 {
-		// Extract the gzip compressed data
-		compressedData := f.MappedData[collection][start:]
-		buf := bytes.NewBuffer(compressedData)
-		gz, err := gzip.NewReader(buf)
-		if err != nil {
-			panic("cannot create gzip reader")
-		}
-		dec := gob.NewDecoder(gz)
-		err = dec.Decode(&arr)
+	arr, err := f.decodeVectorData(data, length, f.Formats[collection])
+	if err != nil {
+		Logger.Log.Log("Error decoding vector: "+err.Error(), "ERROR")
 	}
 	return &arr
 }
 
-// WritePayload will write the payload to the file
-func (f *FileMapper) WritePayload(payload *map[string]interface{}, collection string) (int64, error) {
-	// Lock the file for writing
-	f.Mut[collection].Lock()
-	defer f.Mut[collection].Unlock()
-
-	// Unmap the file from memory
-	f.Unmap(collection)
-
+// encodePayload gob encodes the given payload
+func (f *FileMapper) encodePayload(payload *map[string]interface{}) ([]byte, error) {
 	// Map in einen Byte-Slice serialisieren
 	var buf bytes.Buffer
 	enc := gob.NewEncoder(&buf)
@@ -185,65 +182,53 @@ func (f *FileMapper) WritePayload(payload *map[string]interface{}, collection st
 	err := enc.Encode(payload)
 	if err != nil {
 		Logger.Log.Log("Error encoding payload: "+err.Error(), "ERROR")
-		return 0, err
-	}
-	encodedBytes := buf.Bytes()
-
-	// open the file for writing und append
-	file, err := os.OpenFile(f.FileName[collection], os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		// Here we panic because we can't continue without the file
-		panic(err)
-	}
-	f.File[collection] = file
-	defer f.File[collection].Close()
-
-	// Sicherstellen, dass die Datei groß genug ist
-	fileInfo, err := f.File[collection].Stat()
-	if err != nil {
-		Logger.Log.Log("Error getting file info: "+err.Error(), "ERROR")
-		return 0, err
-	}
-
-	// Get the actual File size
-	fileSize := fileInfo.Size()
-
-	// We need to get the required size
-	requiredSize := fileSize + int64(len(encodedBytes))
-	if requiredSize >= fileSize {
-		err = file.Truncate(requiredSize)
-		if err != nil {
-			Logger.Log.Log("Error truncating file: "+err.Error(), "ERROR")
-			return 0, err
-		}
+		return nil, err
 	}
+	return buf.Bytes(), nil
+}
 
-	// Zur letzten Position in der Datei springen
-	offset, err := file.Seek(0, io.SeekEnd)
+// WritePayload will write the payload to the file and returns the offset and the length of the written data
+func (f *FileMapper) WritePayload(payload *map[string]interface{}, collection string) (int64, int, error) {
+	// Encode the payload
+	encodedBytes, err := f.encodePayload(payload)
 	if err != nil {
-		Logger.Log.Log("Error seeking to end of file: "+err.Error(), "ERROR")
-		return 0, err
+		return 0, 0, err
 	}
 
-	// Serialisierte Daten in die Datei schreiben
-	_, err = file.Write(encodedBytes)
+	// Writers only need the read lock - the store serializes the appends itself
+	f.Mut[collection].RLock()
+	defer f.Mut[collection].RUnlock()
+	offsets, err := f.Stores[collection].Append([][]byte{encodedBytes})
 	if err != nil {
-		Logger.Log.Log("Error writing to file: "+err.Error(), "ERROR")
-		return 0, err
+		return 0, 0, err
 	}
-	// Map the file again
-	f.MapFile(collection)
-	// Return the offset
-	return offset, nil
+	return offsets[0], len(encodedBytes), nil
 }
 
-// ReadPayload will read the payload from the file
+// ReadPayload will read the payload from the file. Payloads are cached, the returned payload is shared and must not
+// be changed.
 func (f *FileMapper) ReadPayload(offset int64, collection string) (*map[string]interface{}, error) {
 	// Lock the file for reading
 	f.Mut[collection].RLock()
 	defer f.Mut[collection].RUnlock()
+	if payload, ok := f.Cache.Get(collection, offset); ok {
+		return payload, nil
+	}
+	payload, err := f.decodePayload(offset, collection)
+	if err != nil {
+		return nil, err
+	}
+	f.Cache.Add(collection, offset, payload)
+	return payload, nil
+}
+
+// decodePayload decodes the payload at the given position of the mapped file - the caller must hold the lock
+func (f *FileMapper) decodePayload(offset int64, collection string) (*map[string]interface{}, error) {
 	// Bytes-Slice ab der gegebenen Position erstellen
-	data := f.MappedData[collection][offset:]
+	data, err := f.Stores[collection].Slice(offset)
+	if err != nil {
+		return nil, fmt.Errorf("payload offset %d out of range in collection %s", offset, collection)
+	}
 
 	// Gob register types
 	gob.Register(map[string]interface{}{})
@@ -253,7 +238,7 @@ func (f *FileMapper) ReadPayload(offset int64, collection string) (*map[string]i
 	var m map[string]interface{}
 	buf := bytes.NewBuffer(data)
 	dec := gob.NewDecoder(buf)
-	err := dec.Decode(&m)
+	err = dec.Decode(&m)
 	if err != nil {
 		Logger.Log.Log("Error decoding payload: "+err.Error(), "ERROR")
 		return nil, err
@@ -261,56 +246,61 @@ func (f *FileMapper) ReadPayload(offset int64, collection string) (*map[string]i
 	return &m, nil
 }
 
-// MapFile will map the file to memory
+// MapFile will open the segment store of the collection and map it to memory - the caller must hold the lock
 func (f *FileMapper) MapFile(collection string) {
-	// Open the file
-	file, err := os.Open(f.FileName[collection])
-	f.File[collection] = file
-	// get the file info
-	fileInfo, err := f.File[collection].Stat()
-	if err != nil {
-		Logger.Log.Log("Error getting file info: "+err.Error(), "ERROR")
-		// We panic here because we can't continue without the file info
+	// The payloads can be at other offsets now
+	f.Cache.Drop(collection)
+	var err error
+	// Reuse the store if the collection was mapped before, so readers never see a missing store
+	if store, ok := f.Stores[collection]; ok {
+		err = store.Reopen(f.metaEnd(collection))
+	} else {
+		f.Stores[collection], err = OpenSegmentStore(f.FileName[collection], int64(*ArgsParser.Ap.SegmentSize)*1024*1024, f.metaEnd(collection))
+	}
+	if err != nil {
+		// We panic here because we can't continue without the mapped data
+		Logger.Log.Log("Error mapping file: "+err.Error(), "ERROR")
 		panic(err)
 	}
+}
 
-	// if the file is not empty we map it to memory
-	if fileInfo.Size() != 0 {
-		mappedData, err := syscall.Mmap(int(f.File[collection].Fd()), 0, int(fileInfo.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
-		if err != nil {
-			Logger.Log.Log("Error mapping file: "+err.Error(), "ERROR")
-			// We panic here because we can't continue without the mapped data
-			panic(err)
-		}
-		f.MappedData[collection] = mappedData
-		f.Mapped[collection] = true
-	} else {
-		f.MappedData[collection] = nil
+// Unmap will unmap the file from memory and close it - the caller must hold the lock
+func (f *FileMapper) Unmap(collection string) {
+	f.Cache.Drop(collection)
+	store, ok := f.Stores[collection]
+	if !ok {
+		return
+	}
+	err := store.Close()
+	if err != nil {
+		// We panic here because we can't continue without the mapped data
+		panic(err)
 	}
 }
 
-// Unmap will unmap the file from memory
-func (f *FileMapper) Unmap(collection string) {
-	// Check if the file is mapped
-	if f.MappedData[collection] != nil && f.Mapped[collection] {
-		err := syscall.Munmap(f.MappedData[collection])
-		if err != nil {
-			// We panic here because we can't continue without the mapped data
-			Logger.Log.Log("Error unmapping file: "+err.Error(), "ERROR")
-			panic(err)
+// metaEnd returns the end of the data that is referenced by the meta file or -1 if it is unknown
+func (f *FileMapper) metaEnd(collection string) int64 {
+	vectors, err := f.readMeta(collection)
+	if err != nil {
+		return -1
+	}
+	var end int64
+	for _, sv := range *vectors {
+		// Deleted entries
+		if sv.VectorID == "" {
+			continue
 		}
-		f.Mapped[collection] = false
-		// Close the file
-		err = f.File[collection].Close()
-		if err != nil {
-			// We panic here because we can't continue without the file
-			panic(err)
+		// Written by an older version without lengths
+		if sv.DataLength == 0 || sv.PayloadLength == 0 {
+			return -1
 		}
+		end = max(end, sv.DataStart+sv.DataLength, sv.PayloadStart+sv.PayloadLength)
 	}
+	return end
 }
 
-// AddCollection adds a collection to the FileMapper
-func (f *FileMapper) AddCollection(collection string) {
+// AddCollection adds a collection with the given vector format to the FileMapper
+func (f *FileMapper) AddCollection(collection string, format int) {
 	// Check if data.cin file exists
 	_, err := os.Stat(*ArgsParser.Ap.FileStore + collection + ".bin")
 	if err != nil {
@@ -323,6 +313,7 @@ func (f *FileMapper) AddCollection(collection string) {
 	}
 	f.FileName[collection] = *ArgsParser.Ap.FileStore + collection + ".bin"
 	f.Mut[collection] = &sync.RWMutex{}
+	f.Formats[collection] = format
 	f.CollectionNames = append(f.CollectionNames, collection)
 	f.MapFile(collection)
 }
@@ -330,7 +321,11 @@ func (f *FileMapper) AddCollection(collection string) {
 // DelCollection deletes a collection from the FileMapper
 func (f *FileMapper) DelCollection(collection string) {
 	// Unmap the file from memory
+	f.Mut[collection].Lock()
 	f.Unmap(collection)
+	delete(f.Stores, collection)
+	delete(f.Formats, collection)
+	f.Mut[collection].Unlock()
 	// Delete the file
 	err := os.Remove(f.FileName[collection])
 	if err != nil {
@@ -361,8 +356,8 @@ func (f *FileMapper) DelCollection(collection string) {
 	}
 }
 
-// SaveVectorWriter will write the vector.ID, vector.DataStart, vector.PayloadStart to the file system
-func (w *FileMapper) SaveVectorWriter(id string, datastart, payloadstart int64, collection string) (int64, error) {
+// SaveVectorWriter will write the vector.ID, vector.DataStart, vector.PayloadStart and the lengths of the data to the file system
+func (w *FileMapper) SaveVectorWriter(id string, datastart, payloadstart int64, datalength, payloadlength int, collection string) (int64, error) {
 	// Lock the Wal
 	w.Mut[collection].Lock()
 	defer w.Mut[collection].Unlock()
@@ -383,7 +378,8 @@ func (w *FileMapper) SaveVectorWriter(id string, datastart, payloadstart int64,
 	}
 
 	// Create the SaveVector
-	sv := SaveVector{VectorID: id, DataStart: datastart, PayloadStart: payloadstart, SaveVectorPosition: pos}
+	sv := SaveVector{VectorID: id, DataStart: datastart, PayloadStart: payloadstart, SaveVectorPosition: pos,
+		DataLength: int64(datalength), PayloadLength: int64(payloadlength)}
 
 	// use json to encode the SaveVector
 	encoder := json.NewEncoder(file)
@@ -395,12 +391,61 @@ func (w *FileMapper) SaveVectorWriter(id string, datastart, payloadstart int64,
 	return pos, nil
 }
 
+// SaveVectorBatchWriter writes many SaveVectors to the meta file at once and returns their positions
+func (w *FileMapper) SaveVectorBatchWriter(svs []SaveVector, collection string) ([]int64, error) {
+	// Lock the Wal
+	w.Mut[collection].Lock()
+	defer w.Mut[collection].Unlock()
+
+	// Open the file "collection"_meta.bin
+	file, err := os.OpenFile(*ArgsParser.Ap.FileStore+collection+"_meta.bin", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		Logger.Log.Log("Error opening meta.json file: "+err.Error(), "ERROR")
+		return nil, err
+	}
+	defer file.Close()
+
+	// Get the position of the Filepointer
+	pos, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		Logger.Log.Log("Error seeking to end of file: "+err.Error(), "ERROR")
+		return nil, err
+	}
+
+	// Encode all SaveVectors into one buffer - one json line each
+	var buf bytes.Buffer
+	positions := make([]int64, len(svs))
+	for i := range svs {
+		positions[i] = pos + int64(buf.Len())
+		svs[i].SaveVectorPosition = positions[i]
+		line, err := json.Marshal(svs[i])
+		if err != nil {
+			Logger.Log.Log("Error encoding SaveVector: "+err.Error(), "ERROR")
+			return nil, err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	// Write them with a single call
+	_, err = file.Write(buf.Bytes())
+	if err != nil {
+		Logger.Log.Log("Error writing to file: "+err.Error(), "ERROR")
+		return nil, err
+	}
+	return positions, nil
+}
+
 // SaveVectorRead will read the vector.ID, vector.DataStart, vector.PayloadStart from the file system and returns a map of vectors
 func (w *FileMapper) SaveVectorRead(collection string) (*map[string]SaveVector, error) {
 	// Lock the Wal - we use a write lock because here will be no memory mapped file
 	w.Mut[collection].Lock()
 	defer w.Mut[collection].Unlock()
+	return w.readMeta(collection)
+}
 
+// readMeta reads all SaveVectors from the meta file - the caller must hold the lock
+func (w *FileMapper) readMeta(collection string) (*map[string]SaveVector, error) {
 	// Create the map
 	vectors := make(map[string]SaveVector)
 	// Open the file "collection"_meta.bin if existing
@@ -423,8 +468,19 @@ func (w *FileMapper) SaveVectorRead(collection string) (*map[string]SaveVector,
 				Logger.Log.Log("Error getting position: "+err.Error(), "ERROR")
 				return nil, err
 			}
+			// The end of the last complete SaveVector
+			end := decoder.InputOffset()
 			if err := decoder.Decode(&sv); err == io.EOF {
 				break
+			} else if err == io.ErrUnexpectedEOF {
+				// The last line was not written completely (crash while writing) - cut it off, the wal will restore it
+				Logger.Log.Log("Incomplete SaveVector at the end of "+collection+"_meta.bin - truncating", "WARNING")
+				err = os.Truncate(*ArgsParser.Ap.FileStore+collection+"_meta.bin", end)
+				if err != nil {
+					Logger.Log.Log("Error truncating meta file: "+err.Error(), "ERROR")
+					return nil, err
+				}
+				break
 			} else if err != nil {
 				Logger.Log.Log("Error decoding SaveVector: "+err.Error(), "ERROR")
 				return nil, err
@@ -435,6 +491,34 @@ func (w *FileMapper) SaveVectorRead(collection string) (*map[string]SaveVector,
 	return &vectors, nil
 }
 
+// Sync flushes the data file and the meta file of the collection to disk
+func (w *FileMapper) Sync(collection string) error {
+	// Lock the files
+	w.Mut[collection].Lock()
+	defer w.Mut[collection].Unlock()
+
+	// The data file
+	err := w.Stores[collection].Sync()
+	if err != nil {
+		Logger.Log.Log("Error syncing file: "+err.Error(), "ERROR")
+		return err
+	}
+
+	// The meta file
+	file, err := os.OpenFile(*ArgsParser.Ap.FileStore+collection+"_meta.bin", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		Logger.Log.Log("Error opening file: "+err.Error(), "ERROR")
+		return err
+	}
+	defer file.Close()
+	err = file.Sync()
+	if err != nil {
+		Logger.Log.Log("Error syncing file: "+err.Error(), "ERROR")
+		return err
+	}
+	return nil
+}
+
 // SaveVectorWriteAt will write the vector.ID, vector.DataStart, vector.PayloadStart to the file system at a specific position
 func (w *FileMapper) SaveVectorWriteAt(datastart, payloadstart int64, collection string, pos int64) error {
 	// Lock the Wal
@@ -0,0 +1,134 @@
+package FileMapper
+
+import (
+	"container/list"
+	"sync"
+)
+
+// PayloadCache is a LRU cache of decoded payloads. Its size is the estimated memory of the payloads, the least
+// recently used payloads are evicted if it grows over the limit. Payloads are only written once at their offset,
+// so a cached payload stays valid until the store of the collection is mapped again.
+type PayloadCache struct {
+	mut     sync.Mutex
+	limit   int64
+	size    int64
+	entries map[payloadKey]*list.Element
+	lru     *list.List // most recently used first
+	stats   CacheStats
+}
+
+// CacheStats are the metrics of the PayloadCache
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Entries   int
+	Size      int64 // estimated bytes
+	Limit     int64
+}
+
+// payloadKey is the position of a payload
+type payloadKey struct {
+	collection string
+	offset     int64
+}
+
+// cacheEntry is a cached payload
+type cacheEntry struct {
+	key     payloadKey
+	payload *map[string]interface{}
+	size    int64
+}
+
+// NewPayloadCache returns a PayloadCache with the limit in bytes - a limit of 0 disables the cache
+func NewPayloadCache(limit int64) *PayloadCache {
+	return &PayloadCache{limit: limit, entries: make(map[payloadKey]*list.Element), lru: list.New()}
+}
+
+// Get returns the cached payload at the offset of the collection
+func (p *PayloadCache) Get(collection string, offset int64) (*map[string]interface{}, bool) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	if p.limit <= 0 {
+		return nil, false
+	}
+	element, ok := p.entries[payloadKey{collection, offset}]
+	if !ok {
+		p.stats.Misses++
+		return nil, false
+	}
+	p.stats.Hits++
+	p.lru.MoveToFront(element)
+	return element.Value.(*cacheEntry).payload, true
+}
+
+// Add caches the payload at the offset of the collection and evicts the least recently used payloads if the cache
+// grows over its limit. Payloads larger than the limit are not cached.
+func (p *PayloadCache) Add(collection string, offset int64, payload *map[string]interface{}) {
+	size := payloadSize(*payload)
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	if size > p.limit {
+		return
+	}
+	key := payloadKey{collection, offset}
+	if element, ok := p.entries[key]; ok {
+		p.remove(element)
+	}
+	p.entries[key] = p.lru.PushFront(&cacheEntry{key: key, payload: payload, size: size})
+	p.size += size
+	for p.size > p.limit {
+		p.remove(p.lru.Back())
+		p.stats.Evictions++
+	}
+}
+
+// Drop removes all payloads of the collection
+func (p *PayloadCache) Drop(collection string) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	for key, element := range p.entries {
+		if key.collection == collection {
+			p.remove(element)
+		}
+	}
+}
+
+// Stats returns the metrics of the cache
+func (p *PayloadCache) Stats() CacheStats {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	stats := p.stats
+	stats.Entries, stats.Size, stats.Limit = len(p.entries), p.size, p.limit
+	return stats
+}
+
+// remove removes the element from the cache - the caller must hold the lock
+func (p *PayloadCache) remove(element *list.Element) {
+	entry := p.lru.Remove(element).(*cacheEntry)
+	delete(p.entries, entry.key)
+	p.size -= entry.size
+}
+
+// payloadSize estimates the memory of a decoded payload value in bytes
+func payloadSize(value interface{}) int64 {
+	// every value is held by an interface
+	const header = 16
+	switch v := value.(type) {
+	case map[string]interface{}:
+		size := int64(header + 48)
+		for key, item := range v {
+			size += header + int64(len(key)) + payloadSize(item)
+		}
+		return size
+	case []interface{}:
+		size := int64(header + 24)
+		for _, item := range v {
+			size += payloadSize(item)
+		}
+		return size
+	case string:
+		return header + 16 + int64(len(v))
+	}
+	return header + 8
+}
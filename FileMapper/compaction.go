@@ -0,0 +1,203 @@
+package FileMapper
+
+import (
+	"VreeDB/ArgsParser"
+	"VreeDB/Logger"
+	"bufio"
+	"encoding/json"
+	"os"
+)
+
+// compactSuffix is appended to the files that are written during a compaction
+const compactSuffix = ".compact"
+
+// Size returns the size of the data file of the collection
+func (f *FileMapper) Size(collection string) int64 {
+	f.Mut[collection].RLock()
+	defer f.Mut[collection].RUnlock()
+	return f.Stores[collection].End()
+}
+
+// Compact rewrites the data file and the meta file of the collection so that they only contain the given vectors,
+// stored in the given vector format. The new files are written next to the old ones and swapped in while the
+// collection is locked, readers will see either the old or the new mapping. The returned SaveVectors hold the
+// new positions in the same order. If beforeSwap is not nil it is called when the new files are synced and before
+// they are swapped - an upgrade writes the new config next to the old one there, see RecoverCompaction.
+func (f *FileMapper) Compact(collection string, vectors []SaveVector, dimension int, format int, beforeSwap func() error) ([]SaveVector, error) {
+	// Lock the file - nobody can read or write while we swap the files
+	f.Mut[collection].Lock()
+	defer f.Mut[collection].Unlock()
+
+	dataName := f.FileName[collection]
+	metaName := *ArgsParser.Ap.FileStore + collection + "_meta.bin"
+
+	// Create the new files
+	dataFile, err := os.Create(dataName + compactSuffix)
+	if err != nil {
+		Logger.Log.Log("Error creating file: "+err.Error(), "ERROR")
+		return nil, err
+	}
+	defer dataFile.Close()
+	metaFile, err := os.Create(metaName + compactSuffix)
+	if err != nil {
+		Logger.Log.Log("Error creating file: "+err.Error(), "ERROR")
+		return nil, err
+	}
+	defer metaFile.Close()
+
+	dataWriter := bufio.NewWriter(dataFile)
+	metaWriter := bufio.NewWriter(metaFile)
+	encoder := json.NewEncoder(metaWriter)
+
+	// Copy all live vectors and payloads
+	result := make([]SaveVector, len(vectors))
+	var dataPos, metaPos int64
+	for i, sv := range vectors {
+		// Vector
+		arr := f.decodeVector(sv.DataStart, dimension, collection)
+		data, err := f.encodeVector(*arr, format)
+		if err != nil {
+			return nil, err
+		}
+		dataLength, err := dataWriter.Write(data)
+		if err != nil {
+			Logger.Log.Log("Error writing to file: "+err.Error(), "ERROR")
+			return nil, err
+		}
+
+		// Payload
+		payload, err := f.decodePayload(sv.PayloadStart, collection)
+		if err != nil {
+			return nil, err
+		}
+		encoded, err := f.encodePayload(payload)
+		if err != nil {
+			return nil, err
+		}
+		payloadLength, err := dataWriter.Write(encoded)
+		if err != nil {
+			Logger.Log.Log("Error writing to file: "+err.Error(), "ERROR")
+			return nil, err
+		}
+
+		// Meta
+		result[i] = SaveVector{VectorID: sv.VectorID, DataStart: dataPos, PayloadStart: dataPos + int64(dataLength),
+			SaveVectorPosition: metaPos, DataLength: int64(dataLength), PayloadLength: int64(payloadLength)}
+		line, err := json.Marshal(result[i])
+		if err != nil {
+			return nil, err
+		}
+		err = encoder.Encode(result[i])
+		if err != nil {
+			Logger.Log.Log("Error encoding SaveVector: "+err.Error(), "ERROR")
+			return nil, err
+		}
+		dataPos += int64(dataLength + payloadLength)
+		metaPos += int64(len(line) + 1)
+	}
+
+	// Flush and sync the new files before they replace the old ones
+	for _, w := range []*bufio.Writer{dataWriter, metaWriter} {
+		if err := w.Flush(); err != nil {
+			Logger.Log.Log("Error writing to file: "+err.Error(), "ERROR")
+			return nil, err
+		}
+	}
+	for _, file := range []*os.File{dataFile, metaFile} {
+		if err := file.Sync(); err != nil {
+			Logger.Log.Log("Error syncing file: "+err.Error(), "ERROR")
+			return nil, err
+		}
+	}
+
+	// The new config must be on disk before the swap starts
+	if beforeSwap != nil {
+		err = beforeSwap()
+		if err != nil {
+			Logger.Log.Log("Error preparing swap: "+err.Error(), "ERROR")
+			return nil, err
+		}
+		f.syncDir()
+	}
+
+	// Swap the files - the data file first, RecoverCompaction relies on that order
+	f.Unmap(collection)
+	err = os.Rename(dataName+compactSuffix, dataName)
+	if err != nil {
+		Logger.Log.Log("Error replacing data file: "+err.Error(), "ERROR")
+		f.MapFile(collection)
+		return nil, err
+	}
+	err = os.Rename(metaName+compactSuffix, metaName)
+	if err != nil {
+		// We panic here because the data file and the meta file do not match anymore
+		Logger.Log.Log("Error replacing meta file: "+err.Error(), "ERROR")
+		panic(err)
+	}
+	f.syncDir()
+
+	// Map the new file
+	f.Formats[collection] = format
+	f.MapFile(collection)
+	return result, nil
+}
+
+// RecoverCompaction finishes or rolls back a compaction that was interrupted by a crash. The new config is only
+// written when the new data and meta files are complete and synced, then the files are swapped in the order data,
+// meta, config. If the new data file exists the swap did not start and all new files are removed, otherwise the
+// swap is finished with the remaining files. It must be called before the config of the collection is read.
+func (f *FileMapper) RecoverCompaction(collection string) {
+	dataName := *ArgsParser.Ap.FileStore + collection + ".bin"
+	metaName := *ArgsParser.Ap.FileStore + collection + "_meta.bin"
+	configName := *ArgsParser.Ap.FileStore + collection + ".json"
+
+	if _, err := os.Stat(dataName + compactSuffix); err == nil {
+		// The swap did not start - the old files are intact
+		Logger.Log.Log("Removing files of interrupted compaction of collection "+collection, "WARNING")
+		for _, name := range []string{dataName, metaName, configName} {
+			os.Remove(name + compactSuffix)
+		}
+		return
+	}
+
+	// The data file was swapped - finish the compaction
+	for _, name := range []string{metaName, configName} {
+		if _, err := os.Stat(name + compactSuffix); err != nil {
+			continue
+		}
+		Logger.Log.Log("Finishing interrupted compaction of collection "+collection, "WARNING")
+		err := os.Rename(name+compactSuffix, name)
+		if err != nil {
+			// Without the matching files the collection cannot be restored
+			panic(err)
+		}
+		f.syncDir()
+	}
+}
+
+// CommitConfig replaces the config of the collection with the new config written next to it. It is called
+// after a compaction that changed the vector format, so the config always matches the data file.
+func (f *FileMapper) CommitConfig(collection string) error {
+	configName := *ArgsParser.Ap.FileStore + collection + ".json"
+	err := os.Rename(configName+compactSuffix, configName)
+	if err != nil {
+		Logger.Log.Log("Error replacing config file: "+err.Error(), "ERROR")
+		return err
+	}
+	f.syncDir()
+	return nil
+}
+
+// syncDir syncs the file store directory, so renames inside it are persisted
+func (f *FileMapper) syncDir() {
+	dir, err := os.Open(*ArgsParser.Ap.FileStore)
+	if err != nil {
+		Logger.Log.Log("Error opening file store: "+err.Error(), "ERROR")
+		return
+	}
+	defer dir.Close()
+	err = dir.Sync()
+	if err != nil {
+		Logger.Log.Log("Error syncing file store: "+err.Error(), "ERROR")
+	}
+}
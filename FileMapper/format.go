@@ -0,0 +1,91 @@
+package FileMapper
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"math"
+)
+
+const (
+	// FormatGob stores every vector as a gzip compressed gob encoding (collections of older versions)
+	FormatGob = 0
+	// FormatRaw stores every vector as fixed-width little-endian float64 values
+	FormatRaw = 1
+	// FormatRaw32 stores every vector as fixed-width little-endian float32 values
+	FormatRaw32 = 2
+)
+
+// CurrentFormat returns the format of new collections - FormatRaw32 for collections of float32 vectors and
+// FormatRaw for all others. Collections in another format will be upgraded during boot.
+func CurrentFormat(float32Values bool) int {
+	if float32Values {
+		return FormatRaw32
+	}
+	return FormatRaw
+}
+
+// Format returns the vector format of the collection
+func (f *FileMapper) Format(collection string) int {
+	f.Mut[collection].RLock()
+	defer f.Mut[collection].RUnlock()
+	return f.Formats[collection]
+}
+
+// encodeVector encodes the vector in the given format
+func (f *FileMapper) encodeVector(arr []float64, format int) ([]byte, error) {
+	switch format {
+	case FormatGob:
+		buf, err := f.GetCompressedBuffer(arr)
+		if err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case FormatRaw:
+		data := make([]byte, 8*len(arr))
+		for i, v := range arr {
+			binary.LittleEndian.PutUint64(data[8*i:], math.Float64bits(v))
+		}
+		return data, nil
+	case FormatRaw32:
+		data := make([]byte, 4*len(arr))
+		for i, v := range arr {
+			binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(float32(v)))
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("unknown vector format %d", format)
+}
+
+// decodeVectorData decodes a vector with the given dimension from data in the given format
+func (f *FileMapper) decodeVectorData(data []byte, length int, format int) ([]float64, error) {
+	arr := make([]float64, length)
+	switch format {
+	case FormatGob:
+		gz, err := gzip.NewReader(bytes.NewBuffer(data))
+		if err != nil {
+			return arr, err
+		}
+		err = gob.NewDecoder(gz).Decode(&arr)
+		return arr, err
+	case FormatRaw:
+		if len(data) < 8*length {
+			return arr, fmt.Errorf("vector record is truncated")
+		}
+		for i := range arr {
+			arr[i] = math.Float64frombits(binary.LittleEndian.Uint64(data[8*i:]))
+		}
+		return arr, nil
+	case FormatRaw32:
+		if len(data) < 4*length {
+			return arr, fmt.Errorf("vector record is truncated")
+		}
+		for i := range arr {
+			arr[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:])))
+		}
+		return arr, nil
+	}
+	return arr, fmt.Errorf("unknown vector format %d", format)
+}
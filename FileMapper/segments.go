@@ -0,0 +1,219 @@
+package FileMapper
+
+import (
+	"VreeDB/Logger"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"syscall"
+)
+
+// Segment is a memory mapped region of a data file
+type Segment struct {
+	Start int64
+	Data  []byte
+}
+
+// SegmentStore is the append-friendly storage of a data file. The file is grown in fixed-size, preallocated
+// segments and every segment is mapped on its own, so growing the file never remaps the existing data. The data
+// of the file when it is opened is mapped as one segment, the store does not know where its records start.
+// A record never spans two segments - records larger than a segment get a segment of their own.
+type SegmentStore struct {
+	Path        string
+	SegmentSize int64
+	file        *os.File
+	segments    []*Segment
+	end         int64 // the logical end of the data - everything behind it is preallocated space
+	capacity    int64 // the size of the file
+	writeMut    sync.Mutex
+	segMut      sync.RWMutex
+}
+
+// OpenSegmentStore opens the data file at path. If the file is a multiple of the segment size it was preallocated
+// and the logical end of the data has to be given by the caller (metaEnd), otherwise the end of the file is used.
+func OpenSegmentStore(path string, segmentSize int64, metaEnd int64) (*SegmentStore, error) {
+	s := &SegmentStore{Path: path, SegmentSize: segmentSize}
+	err := s.open(metaEnd)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reopen opens and maps the data file again after it was closed (e.g. because it was replaced)
+func (s *SegmentStore) Reopen(metaEnd int64) error {
+	return s.open(metaEnd)
+}
+
+// open opens the data file, preallocates it up to the next segment boundary and maps it
+func (s *SegmentStore) open(metaEnd int64) error {
+	s.writeMut.Lock()
+	defer s.writeMut.Unlock()
+
+	file, err := os.OpenFile(s.Path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		Logger.Log.Log("Error opening file: "+err.Error(), "ERROR")
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		Logger.Log.Log("Error getting file info: "+err.Error(), "ERROR")
+		return err
+	}
+	s.file = file
+
+	// Find the logical end of the data
+	size := info.Size()
+	if size%s.SegmentSize != 0 || metaEnd < 0 || metaEnd > size {
+		// Not preallocated (older versions, compaction) or the end is unknown
+		s.end = size
+	} else {
+		s.end = metaEnd
+	}
+
+	// Preallocate the file up to the next segment boundary
+	s.capacity = s.roundUp(size)
+	if s.capacity > size {
+		err = file.Truncate(s.capacity)
+		if err != nil {
+			file.Close()
+			Logger.Log.Log("Error preallocating file: "+err.Error(), "ERROR")
+			return err
+		}
+	}
+
+	// Map the file as one segment, so records that were larger than a segment stay in one mapping
+	if s.capacity > 0 {
+		err = s.mapSegment(0, s.capacity)
+		if err != nil {
+			s.Close()
+			return err
+		}
+	}
+	return nil
+}
+
+// roundUp rounds n up to a multiple of the segment size
+func (s *SegmentStore) roundUp(n int64) int64 {
+	return ((n + s.SegmentSize - 1) / s.SegmentSize) * s.SegmentSize
+}
+
+// mapSegment maps the region [start, start+length) of the file as a new segment
+func (s *SegmentStore) mapSegment(start, length int64) error {
+	data, err := syscall.Mmap(int(s.file.Fd()), start, int(length), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		Logger.Log.Log("Error mapping file: "+err.Error(), "ERROR")
+		return err
+	}
+	s.segMut.Lock()
+	s.segments = append(s.segments, &Segment{Start: start, Data: data})
+	s.segMut.Unlock()
+	return nil
+}
+
+// reserve reserves n bytes and returns their offset - the caller must hold writeMut
+func (s *SegmentStore) reserve(n int64) (int64, error) {
+	// Does the record fit into the segment of the end or a segment behind it? The end is not in the last segment
+	// if the file was opened with the end of its metadata.
+	s.segMut.RLock()
+	for _, segment := range s.segments {
+		offset := max(s.end, segment.Start)
+		if offset+n <= segment.Start+int64(len(segment.Data)) {
+			s.segMut.RUnlock()
+			s.end = offset + n
+			return offset, nil
+		}
+	}
+	s.segMut.RUnlock()
+
+	// Grow the file by a new segment - the rest of the current segment stays unused
+	length := s.roundUp(n)
+	if length == 0 {
+		length = s.SegmentSize
+	}
+	err := s.file.Truncate(s.capacity + length)
+	if err != nil {
+		Logger.Log.Log("Error growing file: "+err.Error(), "ERROR")
+		return 0, err
+	}
+	err = s.mapSegment(s.capacity, length)
+	if err != nil {
+		return 0, err
+	}
+	offset := s.capacity
+	s.capacity += length
+	s.end = offset + n
+	return offset, nil
+}
+
+// Append writes the records to the store and returns their offsets. The space for all records is reserved
+// at once, the records are copied into the mapped segments afterwards, so concurrent writers only
+// serialize on the reservation.
+func (s *SegmentStore) Append(records [][]byte) ([]int64, error) {
+	offsets := make([]int64, len(records))
+
+	// Reserve the space
+	s.writeMut.Lock()
+	for i, record := range records {
+		offset, err := s.reserve(int64(len(record)))
+		if err != nil {
+			s.writeMut.Unlock()
+			return nil, err
+		}
+		offsets[i] = offset
+	}
+	s.writeMut.Unlock()
+
+	// Copy the records into the segments
+	for i, record := range records {
+		data, err := s.Slice(offsets[i])
+		if err != nil {
+			return nil, err
+		}
+		copy(data, record)
+	}
+	return offsets, nil
+}
+
+// Slice returns the mapped data from offset to the end of its segment
+func (s *SegmentStore) Slice(offset int64) ([]byte, error) {
+	s.segMut.RLock()
+	defer s.segMut.RUnlock()
+	// Find the last segment that starts before offset
+	i := sort.Search(len(s.segments), func(i int) bool {
+		return s.segments[i].Start > offset
+	}) - 1
+	if i < 0 || offset < 0 || offset >= s.segments[i].Start+int64(len(s.segments[i].Data)) {
+		return nil, fmt.Errorf("offset %d out of range in %s", offset, s.Path)
+	}
+	return s.segments[i].Data[offset-s.segments[i].Start:], nil
+}
+
+// End returns the logical end of the data
+func (s *SegmentStore) End() int64 {
+	s.writeMut.Lock()
+	defer s.writeMut.Unlock()
+	return s.end
+}
+
+// Sync flushes the mapped segments to disk
+func (s *SegmentStore) Sync() error {
+	return s.file.Sync()
+}
+
+// Close unmaps all segments and closes the file
+func (s *SegmentStore) Close() error {
+	s.segMut.Lock()
+	defer s.segMut.Unlock()
+	for _, segment := range s.segments {
+		err := syscall.Munmap(segment.Data)
+		if err != nil {
+			Logger.Log.Log("Error unmapping file: "+err.Error(), "ERROR")
+			return err
+		}
+	}
+	s.segments = nil
+	return s.file.Close()
+}
@@ -5,15 +5,23 @@ import (
 	"VreeDB/Logger"
 	"VreeDB/Vector"
 	"fmt"
-	"unsafe"
+	"strings"
 )
 
 type Operator string
 
+// Filter is a condition on a payload field or a group of filters. A group has no field and operator, it passes if
+// all filters of Must, at least one filter of Should (if there are any) and no filter of MustNot pass. Groups can be
+// nested, a list of filters is passed like a Must group.
 type Filter struct {
-	Field string      `json:"field"`
-	Op    Operator    `json:"operator"`
-	Value interface{} `json:"value"`
+	Field   string      `json:"field"`
+	Op      Operator    `json:"operator"`
+	Value   interface{} `json:"value"`
+	Must    []Filter    `json:"must,omitempty"`
+	Should  []Filter    `json:"should,omitempty"`
+	MustNot []Filter    `json:"must_not,omitempty"`
+	// candidates are the only vectors that can pass, they are checked before the payload is read
+	candidates map[*Vector.Vector]bool
 }
 
 // Operators
@@ -34,208 +42,286 @@ const (
 	InAnd Operator = "inand"
 	// inor operator
 	In Operator = "in"
+	// NotIn operator - the field value is none of the values
+	NotIn Operator = "not_in"
+	// Between operator - the value is [low, high], both are included
+	Between Operator = "between"
+	// Prefix operator - the field is a string that starts with the value
+	Prefix Operator = "prefix"
+	// Contains operator - the field is a string that contains the value or a slice that contains the value
+	Contains Operator = "contains"
+	// Regex operator - the field is a string that matches the regular expression of the value
+	Regex Operator = "regex"
+	// Exists operator - the field is in the payload and not null, the value is ignored
+	Exists Operator = "exists"
+	// IsNull operator - the field is not in the payload or null, the value is ignored
+	IsNull Operator = "is_null"
+	// GeoRadius operator - the field is a {"lat": .., "lon": ..} point within the radius in meters of the value
+	// {"lat": .., "lon": .., "radius": ..}
+	GeoRadius Operator = "geo_radius"
+	// GeoBBox operator - the field is a point within the box {"top_left": point, "bottom_right": point} of the value
+	GeoBBox Operator = "geo_bbox"
 )
 
 // IsValid checks if the operator is valid
 func (o Operator) IsValid() error {
 	switch o {
-	case Equal, NotEqual, GreaterThan, GreaterThanOrEqual, LessThan, LessThanOrEqual, InAnd, In:
+	case Equal, NotEqual, GreaterThan, GreaterThanOrEqual, LessThan, LessThanOrEqual, InAnd, In, NotIn, Between, Prefix,
+		Contains, Regex, Exists, IsNull, GeoRadius, GeoBBox:
 		return nil
 	}
 	return fmt.Errorf("Invalid operator: %s", o)
 }
 
-// ValidateFilter validates the filter against the given vector's payload.
-// It performs the following steps:
-// - Loads the payload from the hdd
-// - Checks if the field exists in the payload
-// - Checks if the field value and the filter value have the same type
-// - Compares the field value with the filter value using the operator
-// - Returns true if the filter condition is met, otherwise returns false
-// - Returns an error if an error occurs during file reading or type comparison, otherwise returns nil
+// Candidates returns a filter that only passes the given vectors. Evaluate checks it before the payload is read, so
+// a list of filters with candidates selected by a payload Index skips the payloads of all other vectors.
+func Candidates(vectors map[*Vector.Vector]bool) Filter {
+	return Filter{candidates: vectors}
+}
+
+// IsGroup returns true if the filter is a group of filters
+func (f *Filter) IsGroup() bool {
+	return f.Must != nil || f.Should != nil || f.MustNot != nil
+}
+
+// Check validates the operators and the groups of the filters
+func Check(filters *[]Filter) error {
+	if filters == nil {
+		return nil
+	}
+	for i := range *filters {
+		if err := (*filters)[i].check(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// check validates the operator of a filter or the filters of a group
+func (f *Filter) check() error {
+	if !f.IsGroup() {
+		if err := f.Op.IsValid(); err != nil {
+			return err
+		}
+		return f.checkValue()
+	}
+	if f.Field != "" || f.Op != "" {
+		return fmt.Errorf("A filter group must not have a field or an operator")
+	}
+	for _, group := range [][]Filter{f.Must, f.Should, f.MustNot} {
+		if err := Check(&group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateFilter validates the filter against the given vector's payload - see match
 func (f *Filter) ValidateFilter(vector *Vector.Vector) (bool, error) {
 	// Load the Payload from the hdd
 	payload, err := FileMapper.Mapper.ReadPayload(vector.PayloadStart, vector.Collection)
 	if err != nil {
 		return false, err
 	}
-	// Check if the field exists in the payload
-	if _, ok := (*payload)[f.Field]; !ok {
-		return false, nil
+	return f.match(payload)
+}
+
+// match validates the filter against the payload. Groups are matched by their filters, otherwise the value of the
+// field path is matched. If the path selects many values one of them must match, for ne, not_in and is_null all of
+// them must match. Operators on single values match the elements of array fields the same way.
+func (f *Filter) match(payload *map[string]interface{}) (bool, error) {
+	if f.candidates != nil {
+		// Evaluate checked them already
+		return true, nil
+	}
+	if f.IsGroup() {
+		return f.matchGroup(payload)
+	}
+
+	field, many, ok := Lookup(*payload, f.Field)
+	var values []interface{}
+	switch f.Op {
+	case Equal, NotEqual, GreaterThan, GreaterThanOrEqual, LessThan, LessThanOrEqual, Between, Prefix, Regex, GeoRadius,
+		GeoBBox:
+		_, isArray := field.([]interface{})
+		if !many && !isArray {
+			return f.matchValue(field, ok)
+		}
+		values = Values(*payload, f.Field)
+	default:
+		if !many {
+			return f.matchValue(field, ok)
+		}
+		values = field.([]interface{})
 	}
+	all := f.Op == NotEqual || f.Op == NotIn || f.Op == IsNull
+	for _, value := range values {
+		if ok, err := f.matchValue(value, true); err != nil || ok != all {
+			return ok, err
+		}
+	}
+	return all, nil
+}
 
-	// Check if they are of the same type
-	if !f.checkSameType((*payload)[f.Field], f.Value) {
+// matchValue validates the filter against the value of the field, ok is false if the field is not in the payload.
+// It performs the following steps:
+// - Checks if the field exists in the payload - only is_null matches missing fields
+// - Compares the field value with the filter value using the operator, numbers are compared as float64
+// - Returns true if the filter condition is met, otherwise returns false
+// - Fields of another type than the filter value do not match, an invalid filter value returns an error
+func (f *Filter) matchValue(field interface{}, ok bool) (bool, error) {
+	// Check if the field exists in the payload
+	switch {
+	case f.Op == IsNull:
+		return field == nil, nil
+	case !ok:
 		return false, nil
+	case f.Op == Exists:
+		return field != nil, nil
 	}
 
-	// If the operator is == or !=, we can compare the values with all variable types, otherwise we need to check if
-	// the value is of type float64, float32 or int
 	switch f.Op {
-	case Equal, NotEqual:
-		switch v := f.Value.(type) {
-		case int, int64, float32, float64:
-			switch f.Op {
-			case Equal:
-				if (*payload)[f.Field] == v {
-					return true, nil
-				}
-			case NotEqual:
-				if (*payload)[f.Field] != v {
-					return true, nil
-				}
-			}
-		case string:
-			switch f.Op {
-			case Equal:
-				if (*payload)[f.Field] == f.Value {
-					return true, nil
-				}
-			case NotEqual:
-				if (*payload)[f.Field] != f.Value {
-					return true, nil
-				}
-			}
-		default:
+	case Equal:
+		return equal(field, f.Value), nil
+	case NotEqual:
+		return !equal(field, f.Value), nil
+	case GreaterThan, GreaterThanOrEqual, LessThan, LessThanOrEqual:
+		c, ok := Compare(field, f.Value)
+		if !ok {
 			return false, nil
 		}
-	// TBD: GreaterThan, GreaterThanOrEqual, LessThan, LessThanOrEqual
-	case GreaterThan:
-		switch v := f.Value.(type) {
-		case int:
-			if (*payload)[f.Field].(int) > v {
-				return true, nil
-			}
-		case int64:
-			if (*payload)[f.Field].(int64) > v {
-				return true, nil
-			}
-		case float32:
-			if (*payload)[f.Field].(float32) > v {
-				return true, nil
-			}
-		case float64:
-			if (*payload)[f.Field].(float64) > v {
-				return true, nil
-			}
+		switch f.Op {
+		case GreaterThan:
+			return c > 0, nil
+		case GreaterThanOrEqual:
+			return c >= 0, nil
+		case LessThan:
+			return c < 0, nil
 		default:
-			return false, nil
+			return c <= 0, nil
 		}
-	case GreaterThanOrEqual:
-		switch v := f.Value.(type) {
-		case int:
-			if (*payload)[f.Field].(int) >= v {
-				return true, nil
-			}
-		case int64:
-			if (*payload)[f.Field].(int64) >= v {
-				return true, nil
-			}
-		case float32:
-			if (*payload)[f.Field].(float32) >= v {
-				return true, nil
-			}
-		case float64:
-			if (*payload)[f.Field].(float64) >= v {
-				return true, nil
-			}
-		default:
-			return false, nil
+	case Between:
+		bounds, err := f.bounds()
+		if err != nil {
+			return false, err
 		}
-	case LessThan:
-		switch v := f.Value.(type) {
-		case int:
-			if (*payload)[f.Field].(int) < v {
-				return true, nil
-			}
-		case int64:
-			if (*payload)[f.Field].(int64) < v {
-				return true, nil
-			}
-		case float32:
-			if (*payload)[f.Field].(float32) < v {
-				return true, nil
-			}
-		case float64:
-			if (*payload)[f.Field].(float64) < v {
-				return true, nil
-			}
-		default:
-			return false, nil
+		low, okLow := Compare(field, bounds[0])
+		high, okHigh := Compare(field, bounds[1])
+		return okLow && okHigh && low >= 0 && high <= 0, nil
+	// in is special - it takes a slice of values and checks if the field value (or a value of the field slice) is
+	// one of the values, inand checks if all values are in the field slice
+	case In, NotIn, InAnd:
+		values, ok := f.Value.([]interface{})
+		if !ok {
+			return false, fmt.Errorf("%s can only be used for slices of values", f.Op)
 		}
-	case LessThanOrEqual:
-		switch v := f.Value.(type) {
-		case int:
-			if (*payload)[f.Field].(int) <= v {
-				return true, nil
-			}
-		case int64:
-			if (*payload)[f.Field].(int64) <= v {
-				return true, nil
-			}
-		case float32:
-			if (*payload)[f.Field].(float32) <= v {
-				return true, nil
-			}
-		case float64:
-			if (*payload)[f.Field].(float64) <= v {
-				return true, nil
-			}
+		switch f.Op {
+		case In:
+			return containsAny(field, values), nil
+		case NotIn:
+			return !containsAny(field, values), nil
 		default:
-			return false, nil
-		}
-		// in is special - it takes a slice of values and checks if the field value (which is also a slice) contains any of the values
-		// it returns true if all values in the slice are in the field slice
-	case InAnd:
-		switch v := f.Value.(type) {
-		case []interface{}:
-		OUTER:
-			for _, value := range v {
-				// if the value is not in the slice, return false
-				for _, fieldValue := range (*payload)[f.Field].([]interface{}) {
-					if value == fieldValue {
-						continue OUTER
-					}
-				}
-				// if we came here it means that the value is not in the slice
+			fieldValues, ok := field.([]interface{})
+			if !ok {
 				return false, nil
 			}
-			// if we came here it means that all values are in the slice
+			for _, value := range values {
+				if !containsAny(value, fieldValues) {
+					return false, nil
+				}
+			}
 			return true, nil
-		default:
-			Logger.Log.Log("invalid value in filter - in can only be used for slices of values", "INFO")
-			return false, nil
 		}
-	case In:
-		// in is special too, there must be only one value in the slice that is in the field slice
-		switch v := f.Value.(type) {
+	case Prefix:
+		value, ok := f.Value.(string)
+		if !ok {
+			return false, fmt.Errorf("prefix can only be used with a string")
+		}
+		fieldValue, ok := field.(string)
+		return ok && strings.HasPrefix(fieldValue, value), nil
+	case Contains:
+		switch fieldValue := field.(type) {
+		case string:
+			value, ok := f.Value.(string)
+			return ok && strings.Contains(fieldValue, value), nil
 		case []interface{}:
-			for _, value := range v {
-				// if the value is in the slice, return true
-				for _, fieldValue := range (*payload)[f.Field].([]interface{}) {
-					if value == fieldValue {
-						return true, nil
-					}
-				}
-			}
-			return false, nil
-		default:
-			Logger.Log.Log("invalid value in filter - in can only be used for slices of values", "INFO")
-			return false, nil
+			return containsAny(f.Value, fieldValue), nil
+		}
+		return false, nil
+	case Regex:
+		re, err := compileRegex(f.Value)
+		if err != nil {
+			return false, err
 		}
+		fieldValue, ok := field.(string)
+		return ok && re.MatchString(fieldValue), nil
+	case GeoRadius:
+		lat, lon, radius, err := f.geoRadius()
+		if err != nil {
+			return false, err
+		}
+		fieldLat, fieldLon, ok := GeoPoint(field)
+		return ok && greatCircle(lat, lon, fieldLat, fieldLon) <= radius, nil
+	case GeoBBox:
+		box, err := f.geoBox()
+		if err != nil {
+			return false, err
+		}
+		fieldLat, fieldLon, ok := GeoPoint(field)
+		return ok && inBox(fieldLat, fieldLon, box), nil
 	default:
 		// May never happen
 		Logger.Log.Log("invalid operator in filter - this is a bug - please report", "ERROR")
 		return false, nil
 	}
+}
+
+// matchGroup returns true if the payload passes all filters of Must, one filter of Should and no filter of MustNot
+func (f *Filter) matchGroup(payload *map[string]interface{}) (bool, error) {
+	for i := range f.Must {
+		if ok, err := f.Must[i].match(payload); !ok {
+			return false, err
+		}
+	}
+	for i := range f.MustNot {
+		if ok, err := f.MustNot[i].match(payload); ok || err != nil {
+			return false, err
+		}
+	}
+	if len(f.Should) == 0 {
+		return true, nil
+	}
+	for i := range f.Should {
+		if ok, err := f.Should[i].match(payload); ok || err != nil {
+			return ok, err
+		}
+	}
 	return false, nil
 }
 
-// CheckSameType checks if the two values are of the same type
-func (f *Filter) checkSameType(a, b any) bool {
-	typeOfA := *(*uintptr)(unsafe.Pointer(&a))
-	typeOfB := *(*uintptr)(unsafe.Pointer(&b))
+// Evaluate returns true if the vector passes all filters. The payload is only read once.
+func Evaluate(filters *[]Filter, vector *Vector.Vector) (bool, error) {
+	if filters == nil || len(*filters) == 0 {
+		return true, nil
+	}
+	for i := range *filters {
+		if candidates := (*filters)[i].candidates; candidates != nil && !candidates[vector] {
+			return false, nil
+		}
+	}
+	payload, err := FileMapper.Mapper.ReadPayload(vector.PayloadStart, vector.Collection)
+	if err != nil {
+		return false, err
+	}
+	group := Filter{Must: *filters}
+	return group.matchGroup(payload)
+}
 
-	// Check if the types are the same
-	return typeOfA == typeOfB
+// Validate returns true if the vector passes all filters - errors are logged
+func Validate(filters *[]Filter, vector *Vector.Vector) bool {
+	ok, err := Evaluate(filters, vector)
+	if err != nil {
+		Logger.Log.Log("Error validating filters: "+err.Error(), "ERROR")
+	}
+	return ok
 }
@@ -0,0 +1,80 @@
+package Filter
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dayUnits are the units of relative times that time.ParseDuration does not know
+var dayUnits = map[string]time.Duration{"w": 7 * 24 * time.Hour, "d": 24 * time.Hour}
+
+// Time returns the time of a datetime stored in a payload. Stored datetimes are RFC3339 strings only, a payload
+// string like now-7d is a string.
+func Time(value string) (time.Time, bool) {
+	// Only strings that start with a date can be RFC3339
+	if len(value) < len("2006-01-02T15:04:05Z") || value[4] != '-' || value[7] != '-' {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	return t, err == nil
+}
+
+// OperandTime returns the time of a datetime value of a filter. Besides RFC3339 strings filter values can be times
+// relative to now like now, now-7d or now+1d12h30m - the offset is a sequence of numbers with the units w, d or
+// the units of time.ParseDuration.
+func OperandTime(value string) (time.Time, bool) {
+	rest, ok := strings.CutPrefix(value, "now")
+	if !ok {
+		return Time(value)
+	} else if rest == "" {
+		return time.Now(), true
+	}
+	offset, ok := relativeOffset(rest[1:])
+	switch {
+	case !ok:
+		return time.Time{}, false
+	case rest[0] == '-':
+		return time.Now().Add(-offset), true
+	case rest[0] == '+':
+		return time.Now().Add(offset), true
+	}
+	return time.Time{}, false
+}
+
+// relativeOffset returns the duration of the offset of a relative time
+func relativeOffset(offset string) (time.Duration, bool) {
+	if offset == "" {
+		return 0, false
+	}
+	isNumber := func(r rune) bool { return (r >= '0' && r <= '9') || r == '.' }
+	var total time.Duration
+	for offset != "" {
+		// Split the next number and its unit
+		n := strings.IndexFunc(offset, func(r rune) bool { return !isNumber(r) })
+		if n <= 0 {
+			return 0, false
+		}
+		u := strings.IndexFunc(offset[n:], isNumber)
+		if u < 0 {
+			u = len(offset) - n
+		}
+		count, unit := offset[:n], offset[n:n+u]
+		offset = offset[n+u:]
+
+		if length, ok := dayUnits[unit]; ok {
+			value, err := strconv.ParseFloat(count, 64)
+			if err != nil {
+				return 0, false
+			}
+			total += time.Duration(value * float64(length))
+			continue
+		}
+		d, err := time.ParseDuration(count + unit)
+		if err != nil {
+			return 0, false
+		}
+		total += d
+	}
+	return total, true
+}
@@ -0,0 +1,68 @@
+package Filter
+
+import (
+	"fmt"
+	"math"
+)
+
+// meanEarthRadius is the mean radius of the earth in meters used for great-circle distances
+const meanEarthRadius = 6371008.8
+
+// GeoPoint returns the latitude and longitude of a {"lat": .., "lon": ..} object
+func GeoPoint(value any) (float64, float64, bool) {
+	point, ok := value.(map[string]interface{})
+	if !ok {
+		return 0, 0, false
+	}
+	lat, okLat := number(point["lat"])
+	lon, okLon := number(point["lon"])
+	if !okLat || !okLon || math.Abs(lat) > 90 || math.Abs(lon) > 180 {
+		return 0, 0, false
+	}
+	return lat, lon, true
+}
+
+// geoRadius returns the center and the radius in meters of a geo_radius filter
+func (f *Filter) geoRadius() (float64, float64, float64, error) {
+	lat, lon, ok := GeoPoint(f.Value)
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("geo_radius needs a value with lat, lon and radius for field %s", f.Field)
+	}
+	radius, ok := number(f.Value.(map[string]interface{})["radius"])
+	if !ok || radius < 0 {
+		return 0, 0, 0, fmt.Errorf("geo_radius needs a radius in meters that is not negative for field %s", f.Field)
+	}
+	return lat, lon, radius, nil
+}
+
+// geoBox returns the top, left, bottom and right of a geo_bbox filter
+func (f *Filter) geoBox() ([4]float64, error) {
+	box, _ := f.Value.(map[string]interface{})
+	top, left, okTopLeft := GeoPoint(box["top_left"])
+	bottom, right, okBottomRight := GeoPoint(box["bottom_right"])
+	if !okTopLeft || !okBottomRight {
+		return [4]float64{}, fmt.Errorf("geo_bbox needs a value with a top_left and a bottom_right lat and lon for field %s", f.Field)
+	} else if top < bottom {
+		return [4]float64{}, fmt.Errorf("geo_bbox top_left must not be south of bottom_right for field %s", f.Field)
+	}
+	return [4]float64{top, left, bottom, right}, nil
+}
+
+// inBox returns true if the point is in the box - a box with left east of right crosses the antimeridian
+func inBox(lat, lon float64, box [4]float64) bool {
+	if lat > box[0] || lat < box[2] {
+		return false
+	}
+	if box[1] <= box[3] {
+		return lon >= box[1] && lon <= box[3]
+	}
+	return lon >= box[1] || lon <= box[3]
+}
+
+// greatCircle returns the distance between two points in meters
+func greatCircle(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1, phi2 := lat1*math.Pi/180, lat2*math.Pi/180
+	dPhi, dLambda := (lat2-lat1)*math.Pi/180, (lon2-lon1)*math.Pi/180
+	a := math.Sin(dPhi/2)*math.Sin(dPhi/2) + math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+	return 2 * meanEarthRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
@@ -0,0 +1,105 @@
+package Filter
+
+import (
+	"strconv"
+	"strings"
+)
+
+// step is a part of a field path - either a key of an object or an index of an array
+type step struct {
+	key   string
+	index int // -1 for keys
+}
+
+// Lookup returns the value of a field path in the payload. A path is a key or keys of nested objects separated by
+// dots, elements of arrays are selected with [n] - e.g. meta.lang or tags[0]. A key that exists in the payload as
+// it is wins over the path. If the path continues after an array without an index it is looked up in every element
+// and the values are returned as a slice with many set to true.
+func Lookup(payload map[string]interface{}, path string) (value interface{}, many bool, ok bool) {
+	if value, ok := payload[path]; ok {
+		return value, false, true
+	}
+	steps, ok := parsePath(path)
+	if !ok {
+		return nil, false, false
+	}
+	var values []interface{}
+	many = walk(payload, steps, &values)
+	if many {
+		return values, true, len(values) > 0
+	} else if len(values) == 1 {
+		return values[0], false, true
+	}
+	return nil, false, false
+}
+
+// parsePath splits a path into its steps
+func parsePath(path string) ([]step, bool) {
+	var steps []step
+	for _, part := range strings.Split(path, ".") {
+		key, rest, _ := strings.Cut(part, "[")
+		if key != "" {
+			steps = append(steps, step{key: key, index: -1})
+		}
+		for rest != "" {
+			number, after, found := strings.Cut(rest, "]")
+			index, err := strconv.Atoi(number)
+			if !found || err != nil || index < 0 {
+				return nil, false
+			}
+			steps = append(steps, step{index: index})
+			rest = strings.TrimPrefix(after, "[")
+			if rest == after && rest != "" {
+				return nil, false
+			}
+		}
+	}
+	return steps, len(steps) > 0
+}
+
+// walk appends the values at the end of the steps to values. It returns true if an array was walked element by
+// element.
+func walk(value interface{}, steps []step, values *[]interface{}) bool {
+	if len(steps) == 0 {
+		*values = append(*values, value)
+		return false
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if child, ok := v[steps[0].key]; ok && steps[0].index < 0 {
+			return walk(child, steps[1:], values)
+		}
+	case []interface{}:
+		if steps[0].index >= 0 {
+			if steps[0].index < len(v) {
+				return walk(v[steps[0].index], steps[1:], values)
+			}
+			return false
+		}
+		for _, element := range v {
+			walk(element, steps, values)
+		}
+		return true
+	}
+	return false
+}
+
+// Values returns the values of a field path in the payload - the elements of arrays are returned one by one
+func Values(payload map[string]interface{}, path string) []interface{} {
+	value, many, ok := Lookup(payload, path)
+	if !ok {
+		return nil
+	}
+	if !many {
+		value = []interface{}{value}
+	}
+	var values []interface{}
+	for _, v := range value.([]interface{}) {
+		if slice, ok := v.([]interface{}); ok {
+			values = append(values, slice...)
+		} else {
+			values = append(values, v)
+		}
+	}
+	return values
+}
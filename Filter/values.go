@@ -0,0 +1,161 @@
+package Filter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// regexCache holds the compiled regular expressions of the regex filters
+var regexCache sync.Map
+
+// checkValue validates the value of a filter for its operator
+func (f *Filter) checkValue() error {
+	switch f.Op {
+	case GreaterThan, GreaterThanOrEqual, LessThan, LessThanOrEqual:
+		if _, ok := number(f.Value); !ok {
+			if _, ok := f.Value.(string); !ok {
+				return fmt.Errorf("%s needs a number or a string value for field %s", f.Op, f.Field)
+			}
+		}
+	case Between:
+		_, err := f.bounds()
+		return err
+	case In, NotIn, InAnd:
+		if _, ok := f.Value.([]interface{}); !ok {
+			return fmt.Errorf("%s needs a list of values for field %s", f.Op, f.Field)
+		}
+	case Prefix:
+		if _, ok := f.Value.(string); !ok {
+			return fmt.Errorf("prefix needs a string value for field %s", f.Field)
+		}
+	case Regex:
+		_, err := compileRegex(f.Value)
+		return err
+	case GeoRadius:
+		_, _, _, err := f.geoRadius()
+		return err
+	case GeoBBox:
+		_, err := f.geoBox()
+		return err
+	}
+	return nil
+}
+
+// bounds returns the low and high value of a between filter
+func (f *Filter) bounds() ([]interface{}, error) {
+	bounds, ok := f.Value.([]interface{})
+	if !ok || len(bounds) != 2 {
+		return nil, fmt.Errorf("between needs a list of a low and a high value for field %s", f.Field)
+	}
+	if _, ok := compare(bounds[0], bounds[1], OperandTime); !ok {
+		return nil, fmt.Errorf("between needs two numbers or two strings for field %s", f.Field)
+	}
+	return bounds, nil
+}
+
+// compileRegex returns the compiled regular expression of the value
+func compileRegex(value any) (*regexp.Regexp, error) {
+	pattern, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("regex needs a string value")
+	}
+	if re, ok := regexCache.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid regex %s: %s", pattern, err.Error())
+	}
+	regexCache.Store(pattern, re)
+	return re, nil
+}
+
+// number returns the value as float64 if it is a number
+func number(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// equal returns true if both values are equal, numbers of different types are compared as float64
+func equal(a, b any) bool {
+	if x, ok := number(a); ok {
+		y, ok := number(b)
+		return ok && x == y
+	}
+	switch a.(type) {
+	case string:
+		c, ok := Compare(a, b)
+		return ok && c == 0
+	case bool, nil:
+		return a == b
+	}
+	return false
+}
+
+// Compare returns -1, 0 or 1 if the payload value a is smaller, equal or greater than the filter value b. It returns
+// false if a and b are not both numbers, both datetimes or both strings. Only b may be a time relative to now.
+func Compare(a, b any) (int, bool) {
+	return compare(a, b, Time)
+}
+
+// compare is Compare with the function that parses the datetimes of a
+func compare(a, b any, timeOfA func(string) (time.Time, bool)) (int, bool) {
+	if x, ok := number(a); ok {
+		y, ok := number(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	}
+	x, okA := a.(string)
+	y, okB := b.(string)
+	if !okA || !okB {
+		return 0, false
+	}
+	tx, okA := timeOfA(x)
+	ty, okB := OperandTime(y)
+	if okA && okB {
+		return tx.Compare(ty), true
+	} else if okA || okB {
+		return 0, false
+	}
+	return strings.Compare(x, y), true
+}
+
+// containsAny returns true if the value or one of the values of a slice is equal to one of the values
+func containsAny(value any, values []interface{}) bool {
+	if slice, ok := value.([]interface{}); ok {
+		for _, v := range slice {
+			if containsAny(v, values) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, v := range values {
+		if equal(value, v) {
+			return true
+		}
+	}
+	return false
+}
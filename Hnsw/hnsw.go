@@ -0,0 +1,489 @@
+package Hnsw
+
+import (
+	"VreeDB/Filter"
+	"VreeDB/Logger"
+	"VreeDB/Node"
+	"VreeDB/Utils"
+	"VreeDB/Vector"
+	"container/heap"
+	"encoding/gob"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+)
+
+const (
+	// defaultM is the number of links of a node per layer - the bottom layer has 2*M links
+	defaultM = 16
+	// defaultEfConstruction is the number of candidates searched when a node is inserted
+	defaultEfConstruction = 200
+	// defaultEfSearch is the number of candidates searched by a search
+	defaultEfSearch = 64
+)
+
+// HNSW is a hierarchical navigable small world graph. Every vector is a node that is linked to its nearest
+// neighbours on its layers, searches walk greedily from the top layer down. Deleted vectors stay in the graph
+// as tombstones until they are removed, so the graph stays connected.
+type HNSW struct {
+	M              int
+	EfConstruction int
+	EfSearch       int
+
+	path         string
+	distanceFunc func(*Vector.Vector, *Vector.Vector) (float64, error)
+	target       func(*Vector.Vector) *Vector.Vector
+	nodes        []*node
+	positions    map[string]int32
+	entry        int32
+	rand         *rand.Rand
+	mut          sync.RWMutex
+}
+
+// node is a vector in the graph. Nodes without a vector are removed (or not restored yet) and are not visited.
+type node struct {
+	id        string
+	dataStart int64
+	links     [][]int32
+	vector    *Vector.Vector
+}
+
+// graph is the saved form of the HNSW
+type graph struct {
+	M              int
+	EfConstruction int
+	EfSearch       int
+	Entry          int32
+	Ids            []string
+	DataStarts     []int64
+	Links          [][][]int32
+}
+
+// item is a node and its distance to the target
+type item struct {
+	node     int32
+	distance float64
+}
+
+// queue is a heap of items - the nearest item is on top unless it is a max heap
+type queue struct {
+	items []item
+	max   bool
+}
+
+func (q *queue) Len() int { return len(q.items) }
+func (q *queue) Less(i, j int) bool {
+	if q.max {
+		return q.items[i].distance > q.items[j].distance
+	}
+	return q.items[i].distance < q.items[j].distance
+}
+func (q *queue) Swap(i, j int)      { q.items[i], q.items[j] = q.items[j], q.items[i] }
+func (q *queue) Push(x interface{}) { q.items = append(q.items, x.(item)) }
+func (q *queue) Pop() interface{} {
+	x := q.items[len(q.items)-1]
+	q.items = q.items[:len(q.items)-1]
+	return x
+}
+
+// New returns a new empty HNSW - it will be saved to path. The distance function compares a vector of the
+// graph to a target, target converts a vector of the graph to a target.
+func New(path string, params map[string]int, distanceFunc func(*Vector.Vector, *Vector.Vector) (float64, error),
+	target func(*Vector.Vector) *Vector.Vector) (*HNSW, error) {
+	m, efConstruction, efSearch, err := CheckParams(params)
+	if err != nil {
+		return nil, err
+	}
+	return &HNSW{M: m, EfConstruction: efConstruction, EfSearch: efSearch, path: path, distanceFunc: distanceFunc,
+		target: target, positions: make(map[string]int32), entry: -1, rand: rand.New(rand.NewSource(1))}, nil
+}
+
+// CheckParams validates the parameters m, ef_construction and ef_search and returns them with their defaults
+func CheckParams(params map[string]int) (int, int, int, error) {
+	m, efConstruction, efSearch := defaultM, defaultEfConstruction, defaultEfSearch
+	for key, value := range params {
+		switch key {
+		case "m":
+			m = value
+		case "ef_construction":
+			efConstruction = value
+		case "ef_search":
+			efSearch = value
+		default:
+			return 0, 0, 0, fmt.Errorf("Unknown hnsw parameter %s - use m, ef_construction or ef_search", key)
+		}
+	}
+	if m < 2 {
+		return 0, 0, 0, fmt.Errorf("hnsw m must be at least 2")
+	} else if efConstruction < m {
+		return 0, 0, 0, fmt.Errorf("hnsw ef_construction must be at least m")
+	} else if efSearch < 1 {
+		return 0, 0, 0, fmt.Errorf("hnsw ef_search must be at least 1")
+	}
+	return m, efConstruction, efSearch, nil
+}
+
+// Load reads a saved HNSW from path. Its nodes are restored when their vectors are inserted again.
+func Load(path string, distanceFunc func(*Vector.Vector, *Vector.Vector) (float64, error),
+	target func(*Vector.Vector) *Vector.Vector) (*HNSW, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	g := &graph{}
+	err = gob.NewDecoder(file).Decode(g)
+	if err != nil {
+		return nil, err
+	}
+	h := &HNSW{M: g.M, EfConstruction: g.EfConstruction, EfSearch: g.EfSearch, path: path, distanceFunc: distanceFunc,
+		target: target, positions: make(map[string]int32, len(g.Ids)), entry: -1, rand: rand.New(rand.NewSource(1)),
+		nodes: make([]*node, len(g.Ids))}
+	for i, id := range g.Ids {
+		h.nodes[i] = &node{id: id, dataStart: g.DataStarts[i], links: g.Links[i]}
+		h.positions[id] = int32(i)
+	}
+	// The saved entry point becomes the entry point again when its vector is restored
+	h.entry = g.Entry
+	return h, nil
+}
+
+// InMemory returns true - the graph compares the vectors of its nodes
+func (h *HNSW) InMemory() bool {
+	return true
+}
+
+// Train builds the graph again from the vectors in it
+func (h *HNSW) Train(vectors []*Vector.Vector) error {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+	var alive []*Vector.Vector
+	for _, n := range h.nodes {
+		if n.vector != nil {
+			alive = append(alive, n.vector)
+		}
+	}
+	h.nodes, h.positions, h.entry = nil, make(map[string]int32, len(alive)), -1
+	for _, v := range alive {
+		h.insert(v)
+	}
+	Logger.Log.Log(fmt.Sprintf("hnsw rebuilt with %d nodes", len(alive)), "INFO")
+	return nil
+}
+
+// Insert adds a vector to the graph. A restored vector takes its saved node back if it was not moved in the file.
+func (h *HNSW) Insert(v *Vector.Vector) {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+	if pos, ok := h.positions[v.Id]; ok {
+		n := h.nodes[pos]
+		if n.vector == nil && n.dataStart == v.DataStart {
+			n.vector = v
+			h.setEntry(pos)
+			return
+		}
+		// The saved node is outdated
+		n.vector, n.links = nil, nil
+	}
+	h.insert(v)
+}
+
+// Remove removes a deleted vector from the graph and links its neighbours to each other
+func (h *HNSW) Remove(v *Vector.Vector) {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+	pos, ok := h.positions[v.Id]
+	if !ok || h.nodes[pos].vector != v {
+		return
+	}
+	removed := h.nodes[pos]
+	removed.vector = nil
+	delete(h.positions, v.Id)
+
+	for level, links := range removed.links {
+		for _, neighbour := range links {
+			n := h.nodes[neighbour]
+			if n.vector == nil || level >= len(n.links) {
+				continue
+			}
+			// Replace the link to the removed node with its links
+			candidates := make([]int32, 0, len(n.links[level])+len(links))
+			for _, l := range append(n.links[level], links...) {
+				if l != pos && l != neighbour && !contains(candidates, l) && h.nodes[l].vector != nil {
+					candidates = append(candidates, l)
+				}
+			}
+			n.links[level] = candidates
+			h.prune(neighbour, level)
+		}
+	}
+	removed.links = nil
+
+	// Find a new entry point
+	if h.entry == pos {
+		h.entry = h.entryPoint()
+	}
+}
+
+// Search returns the k nearest vectors to the target that pass the filter - deleted vectors are skipped.
+// The ef_search of the params overrides the one of the index.
+func (h *HNSW) Search(target *Vector.Vector, k int, filter *[]Filter.Filter, params Utils.SearchParams) []*Utils.HeapItem {
+	h.mut.RLock()
+	defer h.mut.RUnlock()
+	entry := h.entryPoint()
+	if entry < 0 {
+		return []*Utils.HeapItem{}
+	}
+
+	// Walk down to the bottom layer
+	current := item{node: entry, distance: h.distance(entry, target)}
+	for level := len(h.nodes[entry].links) - 1; level > 0; level-- {
+		current = h.greedy(current, target, level)
+	}
+
+	// Search the bottom layer
+	ef := h.EfSearch
+	if params.EfSearch > 0 {
+		ef = params.EfSearch
+	}
+	found := h.searchLayer(target, current, max(ef, k), 0, func(i int32) bool {
+		v := h.nodes[i].vector
+		return !v.IsDeleted() && Filter.Validate(filter, v)
+	})
+	if len(found) > k {
+		found = found[:k]
+	}
+	results := make([]*Utils.HeapItem, len(found))
+	for i, f := range found {
+		results[i] = &Utils.HeapItem{Node: &Node.Node{Vector: h.nodes[f.node].vector}, Distance: f.distance}
+	}
+	return results
+}
+
+// Save writes the graph to its file - removed nodes are left out
+func (h *HNSW) Save() error {
+	h.mut.RLock()
+	defer h.mut.RUnlock()
+
+	// The saved nodes are numbered without the removed nodes
+	numbers := make([]int32, len(h.nodes))
+	g := &graph{M: h.M, EfConstruction: h.EfConstruction, EfSearch: h.EfSearch, Entry: -1}
+	for i, n := range h.nodes {
+		numbers[i] = -1
+		if n.vector != nil {
+			numbers[i] = int32(len(g.Ids))
+			g.Ids = append(g.Ids, n.id)
+			g.DataStarts = append(g.DataStarts, n.vector.DataStart)
+		}
+	}
+	for i, n := range h.nodes {
+		if n.vector == nil {
+			continue
+		}
+		links := make([][]int32, len(n.links))
+		for level := range n.links {
+			links[level] = make([]int32, 0, len(n.links[level]))
+			for _, l := range n.links[level] {
+				if numbers[l] >= 0 {
+					links[level] = append(links[level], numbers[l])
+				}
+			}
+		}
+		g.Links = append(g.Links, links)
+		if int32(i) == h.entry {
+			g.Entry = numbers[i]
+		}
+	}
+
+	file, err := os.Create(h.path + ".tmp")
+	if err != nil {
+		return err
+	}
+	err = gob.NewEncoder(file).Encode(g)
+	if err == nil {
+		err = file.Sync()
+	}
+	file.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(h.path+".tmp", h.path)
+}
+
+// Delete removes the file of the graph
+func (h *HNSW) Delete() error {
+	err := os.Remove(h.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// insert adds a new node for the vector - the caller must hold the lock
+func (h *HNSW) insert(v *Vector.Vector) {
+	level := int(math.Floor(-math.Log(1-h.rand.Float64()) / math.Log(float64(h.M))))
+	h.entry = h.entryPoint()
+	pos := int32(len(h.nodes))
+	h.nodes = append(h.nodes, &node{id: v.Id, dataStart: v.DataStart, links: make([][]int32, level+1), vector: v})
+	h.positions[v.Id] = pos
+	if h.entry < 0 {
+		h.entry = pos
+		return
+	}
+	target := h.target(v)
+
+	// Walk down to the level of the new node
+	current := item{node: h.entry, distance: h.distance(h.entry, target)}
+	top := len(h.nodes[h.entry].links) - 1
+	for l := top; l > level; l-- {
+		current = h.greedy(current, target, l)
+	}
+
+	// Link the node on every level to its nearest neighbours
+	for l := min(level, top); l >= 0; l-- {
+		found := h.searchLayer(target, current, h.EfConstruction, l, nil)
+		links := make([]int32, 0, h.M)
+		for _, f := range found {
+			if f.node != pos && len(links) < h.M {
+				links = append(links, f.node)
+			}
+		}
+		h.nodes[pos].links[l] = links
+		for _, neighbour := range links {
+			if l < len(h.nodes[neighbour].links) {
+				h.nodes[neighbour].links[l] = append(h.nodes[neighbour].links[l], pos)
+				h.prune(neighbour, l)
+			}
+		}
+		current = found[0]
+	}
+	h.setEntry(pos)
+}
+
+// entryPoint returns the entry point - if its node is removed the node on the highest level
+func (h *HNSW) entryPoint() int32 {
+	if h.entry >= 0 && h.nodes[h.entry].vector != nil {
+		return h.entry
+	}
+	entry := int32(-1)
+	for i, n := range h.nodes {
+		if n.vector != nil && (entry < 0 || len(n.links) > len(h.nodes[entry].links)) {
+			entry = int32(i)
+		}
+	}
+	return entry
+}
+
+// setEntry makes the node the entry point if it is on a higher level than the current one
+func (h *HNSW) setEntry(pos int32) {
+	if h.entry < 0 || h.nodes[h.entry].vector == nil || len(h.nodes[pos].links) > len(h.nodes[h.entry].links) {
+		h.entry = pos
+	}
+}
+
+// prune keeps the nearest links of the node on the level if it has too many
+func (h *HNSW) prune(pos int32, level int) {
+	limit := h.M
+	if level == 0 {
+		limit = 2 * h.M
+	}
+	links := h.nodes[pos].links[level]
+	if len(links) <= limit {
+		return
+	}
+	target := h.target(h.nodes[pos].vector)
+	items := make([]item, 0, len(links))
+	for _, l := range links {
+		if h.nodes[l].vector != nil {
+			items = append(items, item{node: l, distance: h.distance(l, target)})
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].distance < items[j].distance
+	})
+	links = links[:0]
+	for _, it := range items[:min(limit, len(items))] {
+		links = append(links, it.node)
+	}
+	h.nodes[pos].links[level] = links
+}
+
+// greedy moves to the nearest neighbour on the level until there is no nearer one
+func (h *HNSW) greedy(current item, target *Vector.Vector, level int) item {
+	for changed := true; changed; {
+		changed = false
+		for _, l := range h.nodes[current.node].links[level] {
+			if h.nodes[l].vector == nil {
+				continue
+			}
+			if d := h.distance(l, target); d < current.distance {
+				current, changed = item{node: l, distance: d}, true
+			}
+		}
+	}
+	return current
+}
+
+// searchLayer returns up to ef nodes on the level nearest to the target that are accepted, the nearest first.
+// All nodes are walked through, so rejected nodes still connect the graph.
+func (h *HNSW) searchLayer(target *Vector.Vector, entry item, ef, level int, accept func(int32) bool) []item {
+	visited := map[int32]bool{entry.node: true}
+	candidates := &queue{items: []item{entry}}
+	results := &queue{max: true}
+	if accept == nil || accept(entry.node) {
+		results.items = append(results.items, entry)
+	}
+	for candidates.Len() > 0 {
+		c := heap.Pop(candidates).(item)
+		if results.Len() >= ef && c.distance > results.items[0].distance {
+			break
+		}
+		if level >= len(h.nodes[c.node].links) {
+			continue
+		}
+		for _, l := range h.nodes[c.node].links[level] {
+			if visited[l] || h.nodes[l].vector == nil {
+				continue
+			}
+			visited[l] = true
+			d := h.distance(l, target)
+			if results.Len() < ef || d < results.items[0].distance {
+				heap.Push(candidates, item{node: l, distance: d})
+				if accept == nil || accept(l) {
+					heap.Push(results, item{node: l, distance: d})
+					if results.Len() > ef {
+						heap.Pop(results)
+					}
+				}
+			}
+		}
+	}
+	sort.Slice(results.items, func(i, j int) bool {
+		return results.items[i].distance < results.items[j].distance
+	})
+	return results.items
+}
+
+// distance calculates the distance of the node to the target
+func (h *HNSW) distance(pos int32, target *Vector.Vector) float64 {
+	d, err := h.distanceFunc(h.nodes[pos].vector, target)
+	if err != nil {
+		Logger.Log.Log("Error calculating distance: "+err.Error(), "ERROR")
+		return math.Inf(1)
+	}
+	return d
+}
+
+// contains returns true if the slice contains the value
+func contains(slice []int32, value int32) bool {
+	for _, s := range slice {
+		if s == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,334 @@
+package Ivf
+
+import (
+	"VreeDB/Filter"
+	"VreeDB/Logger"
+	"VreeDB/Node"
+	"VreeDB/Utils"
+	"VreeDB/Vector"
+	"container/heap"
+	"encoding/gob"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+)
+
+const (
+	// defaultNProbe is the number of lists that are scanned by a search
+	defaultNProbe = 8
+	// trainSamplesPerList limits the number of vectors the coarse quantizer is trained on
+	trainSamplesPerList = 50
+	// trainIterations is the maximum number of k-means iterations
+	trainIterations = 20
+)
+
+// IVF is an inverted file index. A coarse quantizer clusters the vectors into lists, searches only scan the
+// lists of the nprobe centroids nearest to the target. Until the index is trained all vectors are in one list.
+type IVF struct {
+	Lists       int // 0 uses the square root of the number of vectors at training time
+	NProbe      int
+	Cosine      bool
+	Centroids   [][]float64
+	Assignments map[string]Assignment
+
+	path         string
+	distanceFunc func(*Vector.Vector, *Vector.Vector) (float64, error)
+	lists        [][]*Vector.Vector
+	positions    map[string]position
+	mut          sync.RWMutex
+}
+
+// Assignment is the persisted list of a vector - it is only reused if the vector was not moved in the file since
+type Assignment struct {
+	DataStart int64
+	List      int
+}
+
+// position is the place of a vector in the lists
+type position struct {
+	list  int
+	index int
+}
+
+// New returns a new untrained IVF index - it will be saved to path
+func New(path string, params map[string]int, cosine bool, distanceFunc func(*Vector.Vector, *Vector.Vector) (float64, error)) (*IVF, error) {
+	lists, nprobe, err := CheckParams(params)
+	if err != nil {
+		return nil, err
+	}
+	i := &IVF{Lists: lists, NProbe: nprobe, Cosine: cosine, Assignments: make(map[string]Assignment), path: path,
+		distanceFunc: distanceFunc}
+	i.reset(1)
+	return i, nil
+}
+
+// CheckParams validates the parameters lists and nprobe and returns them with their defaults
+func CheckParams(params map[string]int) (int, int, error) {
+	lists, nprobe := 0, defaultNProbe
+	for key, value := range params {
+		switch key {
+		case "lists":
+			lists = value
+		case "nprobe":
+			nprobe = value
+		default:
+			return 0, 0, fmt.Errorf("Unknown ivf parameter %s - use lists or nprobe", key)
+		}
+	}
+	if lists < 0 {
+		return 0, 0, fmt.Errorf("ivf lists must not be negative")
+	} else if nprobe < 1 {
+		return 0, 0, fmt.Errorf("ivf nprobe must be at least 1")
+	}
+	return lists, nprobe, nil
+}
+
+// Load reads a saved IVF index from path
+func Load(path string, distanceFunc func(*Vector.Vector, *Vector.Vector) (float64, error)) (*IVF, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	i := &IVF{}
+	err = gob.NewDecoder(file).Decode(i)
+	if err != nil {
+		return nil, err
+	}
+	if i.Assignments == nil {
+		i.Assignments = make(map[string]Assignment)
+	}
+	i.path, i.distanceFunc = path, distanceFunc
+	i.reset(max(1, len(i.Centroids)))
+	return i, nil
+}
+
+// Trained returns true if the coarse quantizer is trained
+func (i *IVF) Trained() bool {
+	i.mut.RLock()
+	defer i.mut.RUnlock()
+	return i.Centroids != nil
+}
+
+// InMemory returns true - the lists are scanned with the vectors kept in memory
+func (i *IVF) InMemory() bool {
+	return true
+}
+
+// Insert adds a vector to the list of its nearest centroid
+func (i *IVF) Insert(v *Vector.Vector) {
+	i.mut.Lock()
+	defer i.mut.Unlock()
+	list := 0
+	if i.Centroids != nil {
+		// Reuse the saved assignment if the vector is still at the same position
+		if a, ok := i.Assignments[v.Id]; ok && a.DataStart == v.DataStart && a.List < len(i.Centroids) {
+			list = a.List
+		} else {
+			list = i.assign(*v.GetData())
+		}
+		delete(i.Assignments, v.Id)
+	}
+	if pos, ok := i.positions[v.Id]; ok {
+		i.remove(pos)
+	}
+	i.positions[v.Id] = position{list: list, index: len(i.lists[list])}
+	i.lists[list] = append(i.lists[list], v)
+}
+
+// Remove removes a vector from the index
+func (i *IVF) Remove(v *Vector.Vector) {
+	i.mut.Lock()
+	defer i.mut.Unlock()
+	pos, ok := i.positions[v.Id]
+	if !ok || i.lists[pos.list][pos.index] != v {
+		return
+	}
+	i.remove(pos)
+	delete(i.positions, v.Id)
+}
+
+// Train clusters a sample of the vectors into the lists and assigns all vectors of the index again
+func (i *IVF) Train(vectors []*Vector.Vector) error {
+	lists := i.Lists
+	if lists == 0 {
+		lists = max(1, int(math.Sqrt(float64(len(vectors)))))
+	}
+	if len(vectors) < lists {
+		return fmt.Errorf("ivf needs at least %d vectors to train, got %d", lists, len(vectors))
+	}
+
+	// Read a sample of the vectors
+	r := rand.New(rand.NewSource(1))
+	samples := make([][]float64, 0, min(len(vectors), lists*trainSamplesPerList))
+	for _, p := range r.Perm(len(vectors))[:cap(samples)] {
+		samples = append(samples, i.point(*vectors[p].GetData()))
+	}
+	centroids := Utils.Utils.KMeans(samples, lists, trainIterations, 1)
+
+	// Assign the vectors to the new lists
+	i.mut.Lock()
+	defer i.mut.Unlock()
+	old := i.lists
+	i.Centroids = centroids
+	i.Assignments = make(map[string]Assignment)
+	i.reset(lists)
+	for _, list := range old {
+		for _, v := range list {
+			l := i.assign(*v.GetData())
+			i.positions[v.Id] = position{list: l, index: len(i.lists[l])}
+			i.lists[l] = append(i.lists[l], v)
+		}
+	}
+	Logger.Log.Log(fmt.Sprintf("ivf trained %d lists on %d vectors", lists, len(samples)), "INFO")
+	return nil
+}
+
+// Search returns the k nearest vectors to the target that pass the filter. Only the lists of the nprobe nearest
+// centroids are scanned - the nprobe of the params overrides the one of the index.
+func (i *IVF) Search(target *Vector.Vector, k int, filter *[]Filter.Filter, params Utils.SearchParams) []*Utils.HeapItem {
+	i.mut.RLock()
+	defer i.mut.RUnlock()
+
+	nprobe := i.NProbe
+	if params.NProbe > 0 {
+		nprobe = params.NProbe
+	}
+	probes := []int{0}
+	if i.Centroids != nil && nprobe < len(i.Centroids) {
+		probes = i.probes(target.Data, nprobe)
+	} else if i.Centroids != nil {
+		probes = make([]int, len(i.Centroids))
+		for l := range probes {
+			probes[l] = l
+		}
+	}
+
+	h := Utils.Heap{}
+	for _, l := range probes {
+		for _, v := range i.lists[l] {
+			if v.IsDeleted() {
+				continue
+			}
+			d, err := i.distanceFunc(v, target)
+			if err != nil {
+				Logger.Log.Log("Error calculating distance: "+err.Error(), "ERROR")
+				continue
+			}
+			if h.Len() >= k && d >= h[0].Distance {
+				continue
+			}
+			if !Filter.Validate(filter, v) {
+				continue
+			}
+			heap.Push(&h, &Utils.HeapItem{Node: &Node.Node{Vector: v}, Distance: d})
+			if h.Len() > k {
+				heap.Pop(&h)
+			}
+		}
+	}
+	return h
+}
+
+// Save writes the index to its file
+func (i *IVF) Save() error {
+	i.mut.RLock()
+	defer i.mut.RUnlock()
+
+	// Only the assignments of the vectors in the index are saved
+	assignments := make(map[string]Assignment)
+	if i.Centroids != nil {
+		for l, list := range i.lists {
+			for _, v := range list {
+				assignments[v.Id] = Assignment{DataStart: v.DataStart, List: l}
+			}
+		}
+	}
+	saved := &IVF{Lists: i.Lists, NProbe: i.NProbe, Cosine: i.Cosine, Centroids: i.Centroids, Assignments: assignments}
+
+	file, err := os.Create(i.path + ".tmp")
+	if err != nil {
+		return err
+	}
+	err = gob.NewEncoder(file).Encode(saved)
+	if err == nil {
+		err = file.Sync()
+	}
+	file.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(i.path+".tmp", i.path)
+}
+
+// Delete removes the file of the index
+func (i *IVF) Delete() error {
+	err := os.Remove(i.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// reset creates the given number of empty lists
+func (i *IVF) reset(lists int) {
+	i.lists = make([][]*Vector.Vector, lists)
+	i.positions = make(map[string]position)
+}
+
+// remove swaps the vector at pos with the last vector of its list and shortens the list
+func (i *IVF) remove(pos position) {
+	list := i.lists[pos.list]
+	last := len(list) - 1
+	list[pos.index] = list[last]
+	i.positions[list[pos.index].Id] = pos
+	i.lists[pos.list] = list[:last]
+}
+
+// point returns the data as it is clustered - cosine collections cluster the directions of the vectors
+func (i *IVF) point(data []float64) []float64 {
+	if !i.Cosine {
+		return data
+	}
+	var norm float64
+	for _, value := range data {
+		norm += value * value
+	}
+	if norm == 0 {
+		return data
+	}
+	norm = math.Sqrt(norm)
+	point := make([]float64, len(data))
+	for j, value := range data {
+		point[j] = value / norm
+	}
+	return point
+}
+
+// assign returns the list of the nearest centroid to the data
+func (i *IVF) assign(data []float64) int {
+	nearest, _ := Utils.Utils.NearestCentroid(i.Centroids, i.point(data))
+	return nearest
+}
+
+// probes returns the lists of the n nearest centroids to the target
+func (i *IVF) probes(target []float64, n int) []int {
+	point := i.point(target)
+	distances := make([]float64, len(i.Centroids))
+	lists := make([]int, len(i.Centroids))
+	for l, c := range i.Centroids {
+		for j, value := range point {
+			diff := value - c[j]
+			distances[l] += diff * diff
+		}
+		lists[l] = l
+	}
+	sort.Slice(lists, func(a, b int) bool {
+		return distances[lists[a]] < distances[lists[b]]
+	})
+	return lists[:n]
+}
@@ -238,7 +238,7 @@ func (n *Network) CreateTrainData(vectors []*Vector.Vector) ([][]float64, [][]fl
 		}
 
 		// Add the vector to the training data
-		x = append(x, v.Data)
+		x = append(x, *v.GetData())
 	}
 
 	// Check if the data is gt 0
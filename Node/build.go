@@ -0,0 +1,127 @@
+package Node
+
+import (
+	"VreeDB/Vector"
+	"sync"
+)
+
+const (
+	// parallelBuildSize is the number of vectors from which the subtrees are built in parallel
+	parallelBuildSize = 10000
+	// varianceSamples is the number of vectors the variance of the axes is calculated on
+	varianceSamples = 128
+)
+
+// Build returns a balanced tree of the vectors. Every node splits its vectors at the median of its axis - smaller
+// vectors are on the left, equal and greater vectors on the right like with Insert. With a spread the axis of a node is
+// the one with the highest variance of its vectors (axes without spread are never chosen), otherwise the axes rotate
+// with the depth. The order of the vectors is changed.
+func Build(vectors []*Vector.Vector, spread *Vector.Vector) *Node {
+	// Load the data of the vectors that are only in the file
+	for _, v := range vectors {
+		if v.Collection != "" && v.Indexed {
+			v.Unindex()
+		}
+	}
+	if len(vectors) == 0 {
+		return &Node{}
+	}
+	return build(vectors, spread, 0, vectors[0].Length)
+}
+
+// build builds the subtree of the vectors at the given depth
+func build(vectors []*Vector.Vector, spread *Vector.Vector, depth, length int) *Node {
+	if len(vectors) == 0 {
+		return nil
+	}
+	axis := depth % length
+	if spread != nil {
+		axis = widestAxis(vectors, spread, axis)
+	}
+	median := split(vectors, axis)
+	n := &Node{Vector: vectors[median], Depth: depth, Axis: axis}
+
+	// Build the subtrees
+	left, right := vectors[:median], vectors[median+1:]
+	if len(vectors) < parallelBuildSize {
+		n.Left, n.Right = build(left, spread, depth+1, length), build(right, spread, depth+1, length)
+		return n
+	}
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		n.Left = build(left, spread, depth+1, length)
+	}()
+	n.Right = build(right, spread, depth+1, length)
+	wg.Wait()
+	return n
+}
+
+// widestAxis returns the axis with the highest variance of a sample of the vectors - the fallback if no axis has any
+func widestAxis(vectors []*Vector.Vector, spread *Vector.Vector, fallback int) int {
+	step := max(1, len(vectors)/varianceSamples)
+	best, bestVariance := fallback, 0.0
+	for axis := 0; axis < spread.Length; axis++ {
+		if spread.Data[axis] == 0 {
+			continue
+		}
+		var sum, sq float64
+		count := 0
+		for i := 0; i < len(vectors); i += step {
+			value := vectors[i].Get(axis)
+			sum += value
+			sq += value * value
+			count++
+		}
+		mean := sum / float64(count)
+		variance := sq/float64(count) - mean*mean
+		if variance > bestVariance {
+			best, bestVariance = axis, variance
+		}
+	}
+	return best
+}
+
+// split reorders the vectors around the median of the axis and returns its position. All vectors before it are
+// smaller, all vectors after it are equal or greater.
+func split(vectors []*Vector.Vector, axis int) int {
+	// Select the median with quickselect
+	lo, hi, k := 0, len(vectors)-1, len(vectors)/2
+	for lo < hi {
+		pivot := vectors[(lo+hi)/2].Get(axis)
+		i, j := lo, hi
+		for i <= j {
+			for vectors[i].Get(axis) < pivot {
+				i++
+			}
+			for vectors[j].Get(axis) > pivot {
+				j--
+			}
+			if i <= j {
+				vectors[i], vectors[j] = vectors[j], vectors[i]
+				i++
+				j--
+			}
+		}
+		if k <= j {
+			hi = j
+		} else if k >= i {
+			lo = i
+		} else {
+			break
+		}
+	}
+
+	// Move the vectors equal to the median behind it
+	value := vectors[k].Get(axis)
+	i := 0
+	for j := 0; j < k; j++ {
+		if vectors[j].Get(axis) < value {
+			vectors[i], vectors[j] = vectors[j], vectors[i]
+			i++
+		}
+	}
+	vectors[i], vectors[k] = vectors[k], vectors[i]
+	return i
+}
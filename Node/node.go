@@ -11,6 +11,7 @@ type Node struct {
 	Left     *Node
 	Right    *Node
 	Depth    int
+	Axis     int // the axis the node splits its subtrees at
 	LastUsed time.Time
 	Used     int
 }
@@ -23,25 +24,37 @@ func (n *Node) Insert(newVector *Vector.Vector) {
 	}
 
 	// Get the current axis
-	axis := n.Depth % n.Vector.Length
+	axis := n.Axis
 
-	// Checking if the vector is new or will be readded bei recreation
-	if newVector.Collection != "" {
+	// Load the data of the vector if it is only in the file
+	if newVector.Collection != "" && newVector.Indexed {
 		newVector.Unindex()
 	}
 
 	// Compare the new vector to the current vector
-	if newVector.Data[axis] < n.Vector.Data[axis] {
+	if newVector.Get(axis) < n.Vector.Get(axis) {
 		if n.Left == nil {
-			n.Left = &Node{Depth: axis + 1}
+			n.Left = &Node{Depth: n.Depth + 1, Axis: (axis + 1) % n.Vector.Length}
 		}
 		n.Left.Insert(newVector)
 		return
 	} else {
 		if n.Right == nil {
-			n.Right = &Node{Depth: axis + 1}
+			n.Right = &Node{Depth: n.Depth + 1, Axis: (axis + 1) % n.Vector.Length}
 		}
 		n.Right.Insert(newVector)
 		return
 	}
 }
+
+// Walk calls fn for every vector in the tree
+func (n *Node) Walk(fn func(*Vector.Vector)) {
+	if n == nil {
+		return
+	}
+	if n.Vector != nil {
+		fn(n.Vector)
+	}
+	n.Left.Walk(fn)
+	n.Right.Walk(fn)
+}
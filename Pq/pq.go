@@ -0,0 +1,408 @@
+package Pq
+
+import (
+	"VreeDB/Filter"
+	"VreeDB/Logger"
+	"VreeDB/Node"
+	"VreeDB/Utils"
+	"VreeDB/Vector"
+	"container/heap"
+	"encoding/gob"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"runtime"
+	"sync"
+)
+
+const (
+	// defaultSubDimension is the number of dimensions of a subspace if the number of subspaces is not given
+	defaultSubDimension = 4
+	// defaultCentroids is the number of centroids of every subspace - the codes fit into one byte
+	defaultCentroids = 256
+	// defaultRerank is the factor of candidates that are reranked with their full vectors
+	defaultRerank = 10
+	// trainSamplesPerCentroid limits the number of vectors the codebooks are trained on
+	trainSamplesPerCentroid = 50
+	// trainIterations is the maximum number of k-means iterations per subspace
+	trainIterations = 20
+)
+
+// PQ is a product quantization index. Every vector is split into subspaces and every subspace is stored as the
+// index of its nearest centroid. Searches compare the target to the centroids (asymmetric distance computation)
+// and rerank the best candidates with their full vectors read from the collection file.
+type PQ struct {
+	Dimension int
+	Subspaces int
+	Centroids int
+	Rerank    int
+	Cosine    bool
+	Codebooks [][][]float64 // [subspace][centroid][dimensions of the subspace]
+	Codes     map[string]Code
+
+	path         string
+	distanceFunc func(*Vector.Vector, *Vector.Vector) (float64, error)
+	norms        [][]float64
+	entries      []*entry
+	positions    map[string]int
+	mut          sync.RWMutex
+}
+
+// Code is the persisted code of a vector - it is only reused if the vector was not moved in the file since
+type Code struct {
+	DataStart int64
+	Codes     []uint8
+}
+
+// entry is a vector in the index with its code
+type entry struct {
+	vector *Vector.Vector
+	codes  []uint8
+}
+
+// New returns a new untrained PQ index - it will be saved to path
+func New(path string, dimension int, params map[string]int, cosine bool, distanceFunc func(*Vector.Vector, *Vector.Vector) (float64, error)) (*PQ, error) {
+	subspaces, centroids, rerank, err := CheckParams(dimension, params)
+	if err != nil {
+		return nil, err
+	}
+	return &PQ{Dimension: dimension, Subspaces: subspaces, Centroids: centroids, Rerank: rerank, Cosine: cosine,
+		Codes: make(map[string]Code), path: path, distanceFunc: distanceFunc, positions: make(map[string]int)}, nil
+}
+
+// CheckParams validates the parameters subspaces, centroids and rerank and returns them with their defaults
+func CheckParams(dimension int, params map[string]int) (int, int, int, error) {
+	subspaces, centroids, rerank := max(1, dimension/defaultSubDimension), defaultCentroids, defaultRerank
+	for key, value := range params {
+		switch key {
+		case "subspaces":
+			subspaces = value
+		case "centroids":
+			centroids = value
+		case "rerank":
+			rerank = value
+		default:
+			return 0, 0, 0, fmt.Errorf("Unknown pq parameter %s - use subspaces, centroids or rerank", key)
+		}
+	}
+	if subspaces < 1 || subspaces > dimension {
+		return 0, 0, 0, fmt.Errorf("pq subspaces must be between 1 and the dimension %d", dimension)
+	} else if centroids < 2 || centroids > 256 {
+		return 0, 0, 0, fmt.Errorf("pq centroids must be between 2 and 256")
+	} else if rerank < 1 {
+		return 0, 0, 0, fmt.Errorf("pq rerank must be at least 1")
+	}
+	return subspaces, centroids, rerank, nil
+}
+
+// Load reads a saved PQ index from path
+func Load(path string, distanceFunc func(*Vector.Vector, *Vector.Vector) (float64, error)) (*PQ, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	p := &PQ{}
+	err = gob.NewDecoder(file).Decode(p)
+	if err != nil {
+		return nil, err
+	}
+	if p.Codes == nil {
+		p.Codes = make(map[string]Code)
+	}
+	p.path, p.distanceFunc, p.positions = path, distanceFunc, make(map[string]int)
+	p.setNorms()
+	return p, nil
+}
+
+// Trained returns true if the codebooks are trained
+func (p *PQ) Trained() bool {
+	p.mut.RLock()
+	defer p.mut.RUnlock()
+	return p.Codebooks != nil
+}
+
+// InMemory returns true as long as the index is not trained - until then it searches the full vectors
+func (p *PQ) InMemory() bool {
+	return !p.Trained()
+}
+
+// Insert adds a vector to the index. A trained index keeps only the code of the vector, its data is released.
+func (p *PQ) Insert(v *Vector.Vector) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	e := &entry{vector: v}
+	if p.Codebooks != nil {
+		// Reuse the saved code if the vector is still at the same position
+		if code, ok := p.Codes[v.Id]; ok && code.DataStart == v.DataStart {
+			e.codes = code.Codes
+		} else {
+			e.codes = p.encode(*v.GetData())
+		}
+		delete(p.Codes, v.Id)
+		v.Release()
+	}
+	if pos, ok := p.positions[v.Id]; ok {
+		p.entries[pos] = e
+		return
+	}
+	p.positions[v.Id] = len(p.entries)
+	p.entries = append(p.entries, e)
+}
+
+// Remove removes a vector from the index
+func (p *PQ) Remove(v *Vector.Vector) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	pos, ok := p.positions[v.Id]
+	if !ok || p.entries[pos].vector != v {
+		return
+	}
+	last := len(p.entries) - 1
+	p.entries[pos] = p.entries[last]
+	p.positions[p.entries[pos].vector.Id] = pos
+	p.entries = p.entries[:last]
+	delete(p.positions, v.Id)
+}
+
+// Train trains the codebooks on a sample of the vectors and encodes all vectors of the index again
+func (p *PQ) Train(vectors []*Vector.Vector) error {
+	if len(vectors) < p.Centroids {
+		return fmt.Errorf("pq needs at least %d vectors to train, got %d", p.Centroids, len(vectors))
+	}
+
+	// Read a sample of the vectors
+	r := rand.New(rand.NewSource(1))
+	samples := make([][]float64, 0, min(len(vectors), p.Centroids*trainSamplesPerCentroid))
+	for _, i := range r.Perm(len(vectors))[:cap(samples)] {
+		samples = append(samples, *vectors[i].GetData())
+	}
+
+	// Cluster every subspace - the subspaces are independent
+	codebooks := make([][][]float64, p.Subspaces)
+	wg := sync.WaitGroup{}
+	sem := make(chan struct{}, runtime.NumCPU())
+	for m := 0; m < p.Subspaces; m++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(m int) {
+			defer wg.Done()
+			start, end := p.subspace(m)
+			points := make([][]float64, len(samples))
+			for i, sample := range samples {
+				points[i] = sample[start:end]
+			}
+			codebooks[m] = Utils.Utils.KMeans(points, p.Centroids, trainIterations, int64(m))
+			<-sem
+		}(m)
+	}
+	wg.Wait()
+
+	// Encode the vectors with the new codebooks
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	p.Codebooks = codebooks
+	p.setNorms()
+	p.Codes = make(map[string]Code)
+	for _, e := range p.entries {
+		e.codes = p.encode(*e.vector.GetData())
+		e.vector.Release()
+	}
+	Logger.Log.Log(fmt.Sprintf("pq trained on %d vectors, %d vectors encoded", len(samples), len(p.entries)), "INFO")
+	return nil
+}
+
+// Search returns the k nearest vectors to the target that pass the filter
+func (p *PQ) Search(target *Vector.Vector, k int, filter *[]Filter.Filter, params Utils.SearchParams) []*Utils.HeapItem {
+	p.mut.RLock()
+	defer p.mut.RUnlock()
+
+	// Without codebooks the full vectors are searched
+	if p.Codebooks == nil {
+		return p.nearest(k, filter, func(e *entry) float64 {
+			return p.fullDistance(e.vector, target)
+		})
+	}
+
+	// Find the candidates with the distance tables of the target
+	var candidates []*Utils.HeapItem
+	if p.Cosine {
+		dots, norm := p.dotTable(target.Data)
+		candidates = p.nearest(k*p.Rerank, filter, func(e *entry) float64 {
+			var dot, sq float64
+			for m, c := range e.codes {
+				dot += dots[m][c]
+				sq += p.norms[m][c]
+			}
+			// A zero vector has no direction - it is not similar to any vector
+			if norm == 0 || sq == 0 {
+				return 1
+			}
+			return 1 - dot/(norm*math.Sqrt(sq))
+		})
+	} else {
+		table := p.distanceTable(target.Data)
+		candidates = p.nearest(k*p.Rerank, filter, func(e *entry) float64 {
+			var sum float64
+			for m, c := range e.codes {
+				sum += table[m][c]
+			}
+			return sum
+		})
+	}
+
+	// Rerank the candidates with their full vectors
+	for _, c := range candidates {
+		c.Distance = p.fullDistance(c.Node.Vector, target)
+	}
+	h := Utils.Heap{}
+	for _, c := range candidates {
+		heap.Push(&h, c)
+		if h.Len() > k {
+			heap.Pop(&h)
+		}
+	}
+	return h
+}
+
+// Save writes the index to its file
+func (p *PQ) Save() error {
+	p.mut.RLock()
+	defer p.mut.RUnlock()
+
+	// Only the codes of the vectors in the index are saved
+	codes := make(map[string]Code, len(p.entries))
+	for _, e := range p.entries {
+		if e.codes != nil {
+			codes[e.vector.Id] = Code{DataStart: e.vector.DataStart, Codes: e.codes}
+		}
+	}
+	saved := &PQ{Dimension: p.Dimension, Subspaces: p.Subspaces, Centroids: p.Centroids, Rerank: p.Rerank,
+		Cosine: p.Cosine, Codebooks: p.Codebooks, Codes: codes}
+
+	file, err := os.Create(p.path + ".tmp")
+	if err != nil {
+		return err
+	}
+	err = gob.NewEncoder(file).Encode(saved)
+	if err == nil {
+		err = file.Sync()
+	}
+	file.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(p.path+".tmp", p.path)
+}
+
+// Delete removes the file of the index
+func (p *PQ) Delete() error {
+	err := os.Remove(p.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// nearest returns the n entries with the smallest distance that pass the filter. The filter is only validated for
+// entries that would make it into the result.
+func (p *PQ) nearest(n int, filter *[]Filter.Filter, distance func(*entry) float64) []*Utils.HeapItem {
+	h := Utils.Heap{}
+	for _, e := range p.entries {
+		if e.vector.IsDeleted() {
+			continue
+		}
+		d := distance(e)
+		if h.Len() >= n && d >= h[0].Distance {
+			continue
+		}
+		if !Filter.Validate(filter, e.vector) {
+			continue
+		}
+		heap.Push(&h, &Utils.HeapItem{Node: &Node.Node{Vector: e.vector}, Distance: d})
+		if h.Len() > n {
+			heap.Pop(&h)
+		}
+	}
+	return h
+}
+
+// fullDistance calculates the distance of the target to the full vector
+func (p *PQ) fullDistance(v *Vector.Vector, target *Vector.Vector) float64 {
+	distance, err := p.distanceFunc(&Vector.Vector{Data: *v.GetData(), Length: p.Dimension}, target)
+	if err != nil {
+		Logger.Log.Log("Error calculating distance: "+err.Error(), "ERROR")
+		return math.Inf(1)
+	}
+	// The cosine distance of a zero vector is not defined
+	if p.Cosine && math.IsNaN(distance) {
+		return 1
+	}
+	return distance
+}
+
+// subspace returns the first and the last (exclusive) dimension of subspace m
+func (p *PQ) subspace(m int) (int, int) {
+	return m * p.Dimension / p.Subspaces, (m + 1) * p.Dimension / p.Subspaces
+}
+
+// encode returns the index of the nearest centroid of every subspace
+func (p *PQ) encode(data []float64) []uint8 {
+	codes := make([]uint8, p.Subspaces)
+	for m := range codes {
+		start, end := p.subspace(m)
+		nearest, _ := Utils.Utils.NearestCentroid(p.Codebooks[m], data[start:end])
+		codes[m] = uint8(nearest)
+	}
+	return codes
+}
+
+// distanceTable returns the squared euclidean distances of the target to all centroids
+func (p *PQ) distanceTable(target []float64) [][]float64 {
+	table := make([][]float64, p.Subspaces)
+	for m, codebook := range p.Codebooks {
+		start, end := p.subspace(m)
+		table[m] = make([]float64, len(codebook))
+		for c, centroid := range codebook {
+			for i, value := range target[start:end] {
+				diff := value - centroid[i]
+				table[m][c] += diff * diff
+			}
+		}
+	}
+	return table
+}
+
+// dotTable returns the dot products of the target with all centroids and the norm of the target
+func (p *PQ) dotTable(target []float64) ([][]float64, float64) {
+	table := make([][]float64, p.Subspaces)
+	for m, codebook := range p.Codebooks {
+		start, end := p.subspace(m)
+		table[m] = make([]float64, len(codebook))
+		for c, centroid := range codebook {
+			for i, value := range target[start:end] {
+				table[m][c] += value * centroid[i]
+			}
+		}
+	}
+	var norm float64
+	for _, value := range target {
+		norm += value * value
+	}
+	return table, math.Sqrt(norm)
+}
+
+// setNorms calculates the squared norms of all centroids - they are needed for the cosine distance
+func (p *PQ) setNorms() {
+	p.norms = make([][]float64, len(p.Codebooks))
+	for m, codebook := range p.Codebooks {
+		p.norms[m] = make([]float64, len(codebook))
+		for c, centroid := range codebook {
+			for _, value := range centroid {
+				p.norms[m][c] += value * value
+			}
+		}
+	}
+}
@@ -2,6 +2,9 @@ package Server
 
 import (
 	"VreeDB/ApiKeyHandler"
+	vdbcollection "VreeDB/Collection"
+	"VreeDB/FileMapper"
+	"VreeDB/Filter"
 	"VreeDB/Logger"
 	"VreeDB/Utils"
 	"VreeDB/Vdb"
@@ -253,13 +256,51 @@ func (r *Routes) CreateCollection(w http.ResponseWriter, req *http.Request) {
 				return
 			}
 
+			// Check the precision
+			precision, err := Vector.ParsePrecision(cc.Precision)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			// Check the index type
+			err = vdbcollection.ValidateVectorIndex(cc.IndexType, cc.IndexParams, cc.Dimensions, precision)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			// Check the search settings
+			err = vdbcollection.ValidateSearchSettings(cc.SearchAccuracy, cc.MaxChecks)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			// Check the payload schema
+			err = vdbcollection.ValidateSchema(cc.Schema)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
 			// There is a wait bool - if true the function will wait for the collection to be created
 			if cc.Wait {
 				// Choose distance function from Distancefunction string
 				if strings.ToLower(cc.DistanceFunction) != "euclid" {
 					cc.DistanceFunction = "cosine"
 				}
-				err = r.DB.AddCollection(cc.Name, cc.Dimensions, cc.DistanceFunction)
+				err = r.DB.AddCollection(cc.Name, cc.Dimensions, cc.DistanceFunction, precision, cc.IndexType, cc.IndexParams)
+				if err == nil {
+					err = r.DB.Collections[cc.Name].SetSearchSettings(cc.SearchAccuracy, cc.MaxChecks, cc.AxisRange)
+				}
+				if err == nil && cc.Schema != nil {
+					err = r.DB.Collections[cc.Name].SetSchema(cc.Schema)
+				}
 				if err != nil {
 					w.WriteHeader(http.StatusInternalServerError)
 					w.Write([]byte(err.Error()))
@@ -271,7 +312,18 @@ func (r *Routes) CreateCollection(w http.ResponseWriter, req *http.Request) {
 				return
 			} else {
 				// Create the Collection
-				go r.DB.AddCollection(cc.Name, cc.Dimensions, cc.DistanceFunction)
+				go func() {
+					err := r.DB.AddCollection(cc.Name, cc.Dimensions, cc.DistanceFunction, precision, cc.IndexType, cc.IndexParams)
+					if err == nil {
+						err = r.DB.Collections[cc.Name].SetSearchSettings(cc.SearchAccuracy, cc.MaxChecks, cc.AxisRange)
+					}
+					if err == nil && cc.Schema != nil {
+						err = r.DB.Collections[cc.Name].SetSchema(cc.Schema)
+					}
+					if err != nil {
+						Logger.Log.Log("Error creating collection: "+err.Error(), "ERROR")
+					}
+				}()
 				// Send the success or error message to the client
 				w.WriteHeader(http.StatusOK)
 				w.Write([]byte("Collection created"))
@@ -391,6 +443,210 @@ func (r *Routes) AddPoint(w http.ResponseWriter, req *http.Request) {
 	return
 }
 
+// UpsertPoint inserts a point or replaces the point with the same id - a missing vector or payload keeps the one of the point
+func (r *Routes) UpsertPoint(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	if req.Method == http.MethodPost && strings.ToLower(req.URL.String()) == "/upsertpoint" {
+		// Limit the size of the request
+		req.Body = http.MaxBytesReader(w, req.Body, 10000000)
+		// Parse the form
+		err := req.ParseForm()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Error parsing form"))
+			return
+		}
+		// load the request into the Point via json decode
+		p := &Point{}
+		err = json.NewDecoder(req.Body).Decode(p)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Error decoding json"))
+			return
+		}
+
+		// Check if Auth is valid
+		if r.ApiKeyHandler.CheckApiKey(p.ApiKey) || r.validateCookie(req) {
+
+			// Checks if the CollectionName and the Id are set
+			if p.CollectionName == "" || p.Id == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("Missing required fields"))
+				return
+			}
+
+			// Check if Collection exists
+			if _, ok := r.DB.Collections[p.CollectionName]; !ok {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("Collection does not exist"))
+				return
+			}
+
+			// A missing payload keeps the payload of the point
+			var payload *map[string]interface{}
+			if p.Payload != nil {
+				payload = &p.Payload
+			}
+			err = r.DB.Collections[p.CollectionName].Upsert(p.Id, p.Vector, payload)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			// Send the success or error message to the client
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("Point upserted"))
+			return
+		}
+
+		// Not authorized
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("Unauthorized"))
+		return
+	}
+
+	// Notice the user that the route is not found under given information
+	w.WriteHeader(http.StatusNotFound)
+	w.Write([]byte("Not Found"))
+	return
+}
+
+// UpdatePayload replaces the payload of a point
+func (r *Routes) UpdatePayload(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	if req.Method == http.MethodPost && strings.ToLower(req.URL.String()) == "/updatepayload" {
+		// Limit the size of the request
+		req.Body = http.MaxBytesReader(w, req.Body, 10000000)
+		// Parse the form
+		err := req.ParseForm()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Error parsing form"))
+			return
+		}
+		// load the request into the Point via json decode
+		p := &Point{}
+		err = json.NewDecoder(req.Body).Decode(p)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Error decoding json"))
+			return
+		}
+
+		// Check if Auth is valid
+		if r.ApiKeyHandler.CheckApiKey(p.ApiKey) || r.validateCookie(req) {
+
+			// Checks if the CollectionName, the Id and the Payload are set
+			if p.CollectionName == "" || p.Id == "" || p.Payload == nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("Missing required fields"))
+				return
+			}
+
+			// Check if Collection exists
+			if _, ok := r.DB.Collections[p.CollectionName]; !ok {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("Collection does not exist"))
+				return
+			}
+
+			err = r.DB.Collections[p.CollectionName].UpdatePayload(p.Id, &p.Payload)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			// Send the success or error message to the client
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("Payload updated"))
+			return
+		}
+
+		// Not authorized
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("Unauthorized"))
+		return
+	}
+
+	// Notice the user that the route is not found under given information
+	w.WriteHeader(http.StatusNotFound)
+	w.Write([]byte("Not Found"))
+	return
+}
+
+// PatchPayload patches the payloads of points selected by their ids or a filter
+func (r *Routes) PatchPayload(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	if req.Method == http.MethodPost && strings.ToLower(req.URL.String()) == "/patchpayload" {
+		// Limit the size of the request
+		req.Body = http.MaxBytesReader(w, req.Body, 10000000)
+		// Parse the form
+		err := req.ParseForm()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Error parsing form"))
+			return
+		}
+		// load the request into the PatchPayload via json decode
+		pp := &PatchPayload{}
+		err = json.NewDecoder(req.Body).Decode(pp)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Error decoding json"))
+			return
+		}
+
+		// Check if Auth is valid
+		if r.ApiKeyHandler.CheckApiKey(pp.ApiKey) || r.validateCookie(req) {
+			// Check if Collection exists
+			if _, ok := r.DB.Collections[pp.CollectionName]; !ok {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("Collection does not exist"))
+				return
+			}
+
+			// Check if possible Filter is valid
+			if err := Filter.Check(pp.Filter); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			// Patch the payloads
+			patch := &vdbcollection.PayloadPatch{Set: pp.Set, Unset: pp.Unset, Append: pp.Append, Increment: pp.Increment}
+			patched, err := r.DB.Collections[pp.CollectionName].PatchPayload(pp.Ids, pp.Filter, patch)
+			if err != nil && len(patched) > 0 {
+				// Writing a payload failed after the first payloads were patched
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(fmt.Sprintf("%d points patched before an error: %s - patched ids: %s", len(patched),
+					err.Error(), strings.Join(patched, ", "))))
+				return
+			} else if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			// Send the success or error message to the client
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(fmt.Sprintf("%d points patched", len(patched))))
+			return
+		}
+
+		// Not authorized
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("Unauthorized"))
+		return
+	}
+
+	// Notice the user that the route is not found under given information
+	w.WriteHeader(http.StatusNotFound)
+	w.Write([]byte("Not Found"))
+	return
+}
+
 // AddPointBatch adds a batch of points to a Collection
 func (r *Routes) AddPointBatch(w http.ResponseWriter, req *http.Request) {
 	defer req.Body.Close()
@@ -428,16 +684,25 @@ func (r *Routes) AddPointBatch(w http.ResponseWriter, req *http.Request) {
 				return
 			}
 
+			// Check the points - the invalid points are reported to the client
+			vectors := make([]*Vector.Vector, 0, len(pb.Points))
+			for _, p := range pb.Points {
+				d := p.Payload // This is no longer necessary from GO >= 1.22
+				vectors = append(vectors, Vector.NewVector(p.Id, p.Vector, &d, pb.CollectionName))
+			}
+			err = r.DB.Collections[pb.CollectionName].CheckBatch(vectors)
+			if batchErr, ok := err.(*vdbcollection.BatchError); ok {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(batchErr)
+				return
+			}
+
 			// Add the points to the Collection
 			go func() {
-				for _, p := range pb.Points {
-					d := p.Payload // This is no longer necessary from GO >= 1.22
-					v := Vector.NewVector(p.Id, p.Vector, &d, pb.CollectionName)
-					err = r.DB.Collections[pb.CollectionName].Insert(v)
-					if err != nil {
-						Logger.Log.Log("Error in BulkAdd: "+err.Error(), "ERROR")
-						return
-					}
+				err := r.DB.Collections[pb.CollectionName].InsertBatch(vectors)
+				if err != nil {
+					Logger.Log.Log("Error in BulkAdd: "+err.Error(), "ERROR")
 				}
 			}()
 
@@ -547,7 +812,7 @@ func (r *Routes) DeletePointById(w http.ResponseWriter, req *http.Request) {
 
 func (r *Routes) DeletePointWithFilter(w http.ResponseWriter, req *http.Request) {
 	defer req.Body.Close()
-	if req.Method == http.MethodPost && strings.ToLower(req.URL.String()) == "/deletepoint" {
+	if req.Method == http.MethodPost && strings.ToLower(req.URL.String()) == "/deletepointwithfilter" {
 		// Parse the form
 		err := req.ParseForm()
 		if err != nil {
@@ -579,6 +844,13 @@ func (r *Routes) DeletePointWithFilter(w http.ResponseWriter, req *http.Request)
 				return
 			}
 
+			// Check if the Filter is valid
+			if err := Filter.Check(dp.Filter); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
 			// Delete the point from the Collection
 			err = r.DB.DeleteWithFilter(dp.CollectionName, *dp.Filter)
 			if err != nil {
@@ -681,6 +953,13 @@ func (r *Routes) Search(w http.ResponseWriter, req *http.Request) {
 				return
 			}
 
+			// Check the search settings
+			if err := vdbcollection.ValidateSearchSettings(p.SearchAccuracy, p.MaxChecks); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
 			// Name, Vector are required
 			if p.CollectionName == "" || p.Vector == nil {
 				w.WriteHeader(http.StatusBadRequest)
@@ -710,10 +989,19 @@ func (r *Routes) Search(w http.ResponseWriter, req *http.Request) {
 			switch p.Index {
 			case nil:
 				results = r.DB.Search(p.CollectionName, Vector.NewVector(p.Id, p.Vector, &p.Payload, ""), queue,
-					p.MaxDistancePercent, p.Filter, &p.GetVectors, &p.GetId)
+					p.MaxDistancePercent, p.Filter, p.Rescore, Utils.SearchParams{EfSearch: p.EfSearch, NProbe: p.NProbe, Exact: p.Exact,
+						Accuracy: p.SearchAccuracy, MaxChecks: p.MaxChecks, AxisRange: p.AxisRange}, &p.GetVectors, &p.GetId)
 			default:
+				// Check if the Index exists
+				if !r.DB.Collections[p.CollectionName].HasIndex(p.Index.IndexName) {
+					w.WriteHeader(http.StatusBadRequest)
+					w.Write([]byte("Index does not exist"))
+					return
+				}
 				results = r.DB.IndexSearch(p.CollectionName, Vector.NewVector(p.Id, p.Vector, &p.Payload, ""),
-					queue, p.MaxDistancePercent, p.Filter, p.Index.IndexName, p.Index.IndexValue, &p.GetVectors, &p.GetId)
+					queue, p.MaxDistancePercent, p.Filter, p.Index.IndexName, p.Index.IndexValue, p.Rescore,
+					Utils.SearchParams{EfSearch: p.EfSearch, NProbe: p.NProbe, Exact: p.Exact,
+						Accuracy: p.SearchAccuracy, MaxChecks: p.MaxChecks, AxisRange: p.AxisRange}, &p.GetVectors, &p.GetId)
 			}
 
 			// Send the results to the client
@@ -736,10 +1024,10 @@ func (r *Routes) Search(w http.ResponseWriter, req *http.Request) {
 	return
 }
 
-// TrainClassifier trains a classifier
-func (r *Routes) TrainClassifier(w http.ResponseWriter, req *http.Request) {
+// GetPoints returns the points of a Collection with the given ids
+func (r *Routes) GetPoints(w http.ResponseWriter, req *http.Request) {
 	defer req.Body.Close()
-	if req.Method == http.MethodPost && strings.ToLower(req.URL.String()) == "/trainclassifier" {
+	if req.Method == http.MethodPost && strings.ToLower(req.URL.String()) == "/getpoints" {
 		// Parse the form
 		err := req.ParseForm()
 		if err != nil {
@@ -747,9 +1035,9 @@ func (r *Routes) TrainClassifier(w http.ResponseWriter, req *http.Request) {
 			w.Write([]byte("Error parsing form"))
 			return
 		}
-		// load the request into the TrainClassifier via json decode
-		tc := &Classifier{}
-		err = json.NewDecoder(req.Body).Decode(tc)
+		// load the request into the GetPoints via json decode
+		gp := &GetPoints{}
+		err = json.NewDecoder(req.Body).Decode(gp)
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			w.Write([]byte("Error decoding json"))
@@ -757,49 +1045,309 @@ func (r *Routes) TrainClassifier(w http.ResponseWriter, req *http.Request) {
 		}
 
 		// Check if Auth is valid
-		if r.ApiKeyHandler.CheckApiKey(tc.ApiKey) || r.validateCookie(req) {
-
+		if r.ApiKeyHandler.CheckApiKey(gp.ApiKey) || r.validateCookie(req) {
 			// Check if Collection exists
-			if _, ok := r.DB.Collections[tc.CollectionName]; !ok {
+			if _, ok := r.DB.Collections[gp.CollectionName]; !ok {
 				w.WriteHeader(http.StatusBadRequest)
 				w.Write([]byte("Collection does not exist"))
 				return
 			}
 
-			// Check if Collection is ClassifierReady
-			if !r.DB.Collections[tc.CollectionName].ClassifierReady {
-				w.WriteHeader(http.StatusBadRequest)
-				w.Write([]byte("Collection is not ready for classification"))
-				return
-			}
-
-			// Check if Type exists
-			if tc.Type == "" {
+			// Ids are required
+			if len(gp.Ids) == 0 {
 				w.WriteHeader(http.StatusBadRequest)
-				w.Write([]byte("Type is missing"))
+				w.Write([]byte("Missing required fields"))
 				return
 			}
 
-			// Create the classifier in the collection
-			err = r.DB.Collections[tc.CollectionName].AddClassifier(tc.ClassifierName, tc.Type, tc.Loss, tc.Architecture)
+			// Read the points
+			points, err := r.DB.Collections[gp.CollectionName].GetPoints(gp.Ids, gp.GetVectors)
 			if err != nil {
 				w.WriteHeader(http.StatusInternalServerError)
 				w.Write([]byte(err.Error()))
 				return
 			}
 
-			// if tc.c is not set - set it
-			if tc.C == 0 {
-				tc.C = 0.000001
-			}
+			// Send the points to the client
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(points)
+			return
+		}
 
-			// Train the classifier non blocking
-			go func() {
-				err := r.DB.Collections[tc.CollectionName].TrainClassifier(tc.ClassifierName, tc.Degree, tc.C,
-					tc.Epochs, tc.Batchsize)
-				if err != nil {
-					Logger.Log.Log(err.Error(), "ERROR")
-				}
+		// Not authorized
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("Unauthorized"))
+		return
+	}
+
+	// Notice the user that the route is not found under given information
+	w.WriteHeader(http.StatusNotFound)
+	w.Write([]byte("Not Found"))
+	return
+}
+
+// Scroll returns a page of the points of a Collection in id order
+func (r *Routes) Scroll(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	if req.Method == http.MethodPost && strings.ToLower(req.URL.String()) == "/scroll" {
+		// Parse the form
+		err := req.ParseForm()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Error parsing form"))
+			return
+		}
+		// load the request into the Scroll via json decode
+		s := &Scroll{}
+		err = json.NewDecoder(req.Body).Decode(s)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Error decoding json"))
+			return
+		}
+
+		// Check if Auth is valid
+		if r.ApiKeyHandler.CheckApiKey(s.ApiKey) || r.validateCookie(req) {
+			// Check if Collection exists
+			if _, ok := r.DB.Collections[s.CollectionName]; !ok {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("Collection does not exist"))
+				return
+			}
+
+			// Check if possible Filter is valid
+			if err := Filter.Check(s.Filter); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			// Set the default page size
+			if s.Limit == 0 {
+				s.Limit = 100
+			}
+
+			// Sort by the payload key if one is given
+			var orderBy *vdbcollection.OrderBy
+			if s.OrderBy != "" {
+				orderBy = &vdbcollection.OrderBy{Key: s.OrderBy, Descending: s.Descending}
+			}
+
+			// Read the page
+			points, next, err := r.DB.Collections[s.CollectionName].Scroll(s.Offset, s.Limit, s.Filter, orderBy, s.GetVectors)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			// Send the page to the client
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(ScrollResult{Points: points, NextOffset: next})
+			return
+		}
+
+		// Not authorized
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("Unauthorized"))
+		return
+	}
+
+	// Notice the user that the route is not found under given information
+	w.WriteHeader(http.StatusNotFound)
+	w.Write([]byte("Not Found"))
+	return
+}
+
+// Count returns the number of points of a Collection that pass a filter and the facets of payload keys
+func (r *Routes) Count(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	if req.Method == http.MethodPost && strings.ToLower(req.URL.String()) == "/count" {
+		// Parse the form
+		err := req.ParseForm()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Error parsing form"))
+			return
+		}
+		// load the request into the Count via json decode
+		c := &Count{}
+		err = json.NewDecoder(req.Body).Decode(c)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Error decoding json"))
+			return
+		}
+
+		// Check if Auth is valid
+		if r.ApiKeyHandler.CheckApiKey(c.ApiKey) || r.validateCookie(req) {
+			// Check if Collection exists
+			if _, ok := r.DB.Collections[c.CollectionName]; !ok {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("Collection does not exist"))
+				return
+			}
+
+			// Check if possible Filter is valid
+			if err := Filter.Check(c.Filter); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			// Count the points
+			result, err := r.DB.Collections[c.CollectionName].Count(c.Filter, c.Facets)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			// Send the result to the client
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(result)
+			return
+		}
+
+		// Not authorized
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("Unauthorized"))
+		return
+	}
+
+	// Notice the user that the route is not found under given information
+	w.WriteHeader(http.StatusNotFound)
+	w.Write([]byte("Not Found"))
+	return
+}
+
+// UpdateSchema replaces the payload schema of a Collection - all stored points must match the new schema
+func (r *Routes) UpdateSchema(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	if req.Method == http.MethodPost && strings.ToLower(req.URL.String()) == "/updateschema" {
+		// Parse the form
+		err := req.ParseForm()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Error parsing form"))
+			return
+		}
+		// load the request into the UpdateSchema via json decode
+		us := &UpdateSchema{}
+		err = json.NewDecoder(req.Body).Decode(us)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Error decoding json"))
+			return
+		}
+
+		// Check if Auth is valid
+		if r.ApiKeyHandler.CheckApiKey(us.ApiKey) || r.validateCookie(req) {
+			// Check if Collection exists
+			if _, ok := r.DB.Collections[us.CollectionName]; !ok {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("Collection does not exist"))
+				return
+			}
+
+			// Set the schema - the points that do not match it are reported to the client
+			err = r.DB.Collections[us.CollectionName].SetSchema(us.Schema)
+			if batchErr, ok := err.(*vdbcollection.BatchError); ok {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(batchErr)
+				return
+			} else if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			// Send the success or error message to the client
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("Schema updated"))
+			return
+		}
+
+		// Not authorized
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("Unauthorized"))
+		return
+	}
+
+	// Notice the user that the route is not found under given information
+	w.WriteHeader(http.StatusNotFound)
+	w.Write([]byte("Not Found"))
+	return
+}
+
+// TrainClassifier trains a classifier
+func (r *Routes) TrainClassifier(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	if req.Method == http.MethodPost && strings.ToLower(req.URL.String()) == "/trainclassifier" {
+		// Parse the form
+		err := req.ParseForm()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Error parsing form"))
+			return
+		}
+		// load the request into the TrainClassifier via json decode
+		tc := &Classifier{}
+		err = json.NewDecoder(req.Body).Decode(tc)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Error decoding json"))
+			return
+		}
+
+		// Check if Auth is valid
+		if r.ApiKeyHandler.CheckApiKey(tc.ApiKey) || r.validateCookie(req) {
+
+			// Check if Collection exists
+			if _, ok := r.DB.Collections[tc.CollectionName]; !ok {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("Collection does not exist"))
+				return
+			}
+
+			// Check if Collection is ClassifierReady
+			if !r.DB.Collections[tc.CollectionName].ClassifierReady {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("Collection is not ready for classification"))
+				return
+			}
+
+			// Check if Type exists
+			if tc.Type == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("Type is missing"))
+				return
+			}
+
+			// Create the classifier in the collection
+			err = r.DB.Collections[tc.CollectionName].AddClassifier(tc.ClassifierName, tc.Type, tc.Loss, tc.Architecture)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			// if tc.c is not set - set it
+			if tc.C == 0 {
+				tc.C = 0.000001
+			}
+
+			// Train the classifier non blocking
+			go func() {
+				err := r.DB.Collections[tc.CollectionName].TrainClassifier(tc.ClassifierName, tc.Degree, tc.C,
+					tc.Epochs, tc.Batchsize)
+				if err != nil {
+					Logger.Log.Log(err.Error(), "ERROR")
+				}
 			}()
 
 			// Send the success or error message to the client
@@ -1154,11 +1702,19 @@ func (r *Routes) CreateIndex(w http.ResponseWriter, req *http.Request) {
 			return
 		}
 
+		// Check the type of the Index
+		err = vdbcollection.CheckIndexType(ic.IndexType)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
 		// Check if Auth is valid
 		if r.ApiKeyHandler.CheckApiKey(ic.ApiKey) || r.validateCookie(req) {
 			// Create the Index
 			go func() {
-				err = r.DB.Collections[ic.CollectionName].CreateIndex(ic.IndexName, ic.IndexName)
+				err = r.DB.Collections[ic.CollectionName].CreateIndex(ic.IndexName, ic.IndexName, ic.IndexType)
 				if err != nil {
 					Logger.Log.Log("Error creating index: "+err.Error(), "ERROR")
 					return
@@ -1189,6 +1745,565 @@ func (r *Routes) CreateIndex(w http.ResponseWriter, req *http.Request) {
 
 }
 
+// ListIndexes will list the payload indexes of a Collection with their keys and types
+func (r *Routes) ListIndexes(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	if req.Method == http.MethodPost && strings.ToLower(req.URL.String()) == "/listindexes" {
+		// Parse the form
+		err := req.ParseForm()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Error parsing form"))
+			return
+		}
+
+		// load the request into the ListIndexes via json decode
+		li := &ListIndexes{}
+		err = json.NewDecoder(req.Body).Decode(li)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Error decoding json"))
+			return
+		}
+
+		// Check if Auth is valid
+		if r.ApiKeyHandler.CheckApiKey(li.ApiKey) || r.validateCookie(req) {
+			// Check if Collection exists
+			if _, ok := r.DB.Collections[li.CollectionName]; !ok {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("Collection does not exist"))
+				return
+			}
+
+			// Send the indexes to the client
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(r.DB.Collections[li.CollectionName].ListIndexes())
+			return
+		}
+
+		// not authorized
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("Unauthorized"))
+		return
+	}
+	// Notice the user that the route is not found under given information
+	w.WriteHeader(http.StatusNotFound)
+	w.Write([]byte("Not Found"))
+	return
+}
+
+// DropIndex will delete a payload index of a Collection
+func (r *Routes) DropIndex(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	if req.Method == http.MethodPost && strings.ToLower(req.URL.String()) == "/dropindex" {
+		// Parse the form
+		err := req.ParseForm()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Error parsing form"))
+			return
+		}
+
+		// load the request into the DropIndex via json decode
+		di := &DropIndex{}
+		err = json.NewDecoder(req.Body).Decode(di)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Error decoding json"))
+			return
+		}
+
+		// Check if Auth is valid
+		if r.ApiKeyHandler.CheckApiKey(di.ApiKey) || r.validateCookie(req) {
+			// Check if Collection exists
+			if _, ok := r.DB.Collections[di.CollectionName]; !ok {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("Collection does not exist"))
+				return
+			}
+
+			// Drop the Index
+			err = r.DB.Collections[di.CollectionName].DropIndex(di.IndexName)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			// Log the deletion
+			Logger.Log.Log("Index "+di.IndexName+" in Collection "+di.CollectionName+" dropped", "INFO")
+
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("Index dropped"))
+			return
+		}
+
+		// not authorized
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("Unauthorized"))
+		return
+	}
+	// Notice the user that the route is not found under given information
+	w.WriteHeader(http.StatusNotFound)
+	w.Write([]byte("Not Found"))
+	return
+}
+
+// RebuildIndex will build a payload index of a Collection again from the payloads of its alive vectors
+func (r *Routes) RebuildIndex(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	if req.Method == http.MethodPost && strings.ToLower(req.URL.String()) == "/rebuildindex" {
+		// Parse the form
+		err := req.ParseForm()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Error parsing form"))
+			return
+		}
+
+		// load the request into the RebuildIndex via json decode
+		ri := &RebuildIndex{}
+		err = json.NewDecoder(req.Body).Decode(ri)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Error decoding json"))
+			return
+		}
+
+		// Check if Auth is valid
+		if r.ApiKeyHandler.CheckApiKey(ri.ApiKey) || r.validateCookie(req) {
+			// Check if Collection exists
+			if _, ok := r.DB.Collections[ri.CollectionName]; !ok {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("Collection does not exist"))
+				return
+			}
+
+			// Check if the Index exists
+			if !r.DB.Collections[ri.CollectionName].HasIndex(ri.IndexName) {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("Index does not exist"))
+				return
+			}
+
+			// There is a wait bool - if true the function will wait for the rebuild to finish
+			if ri.Wait {
+				err = r.DB.Collections[ri.CollectionName].RecreateIndex(ri.IndexName)
+				if err != nil {
+					w.WriteHeader(http.StatusInternalServerError)
+					w.Write([]byte(err.Error()))
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte("Index rebuilt"))
+				return
+			}
+
+			// Rebuild non blocking
+			go func() {
+				err := r.DB.Collections[ri.CollectionName].RecreateIndex(ri.IndexName)
+				if err != nil {
+					Logger.Log.Log("Error rebuilding index: "+err.Error(), "ERROR")
+				}
+			}()
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("index in rebuild"))
+			return
+		}
+
+		// not authorized
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("Unauthorized"))
+		return
+	}
+	// Notice the user that the route is not found under given information
+	w.WriteHeader(http.StatusNotFound)
+	w.Write([]byte("Not Found"))
+	return
+}
+
+// IndexStats will return the number of values and vectors, the vectors per value and the memory of a payload index
+func (r *Routes) IndexStats(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	if req.Method == http.MethodPost && strings.ToLower(req.URL.String()) == "/indexstats" {
+		// Parse the form
+		err := req.ParseForm()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Error parsing form"))
+			return
+		}
+
+		// load the request into the IndexStats via json decode
+		is := &IndexStats{}
+		err = json.NewDecoder(req.Body).Decode(is)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Error decoding json"))
+			return
+		}
+
+		// Check if Auth is valid
+		if r.ApiKeyHandler.CheckApiKey(is.ApiKey) || r.validateCookie(req) {
+			// Check if Collection exists
+			if _, ok := r.DB.Collections[is.CollectionName]; !ok {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("Collection does not exist"))
+				return
+			}
+
+			// Get the statistics of the Index
+			stats, err := r.DB.Collections[is.CollectionName].IndexStats(is.IndexName)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			// Send the statistics to the client
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(stats)
+			return
+		}
+
+		// not authorized
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("Unauthorized"))
+		return
+	}
+	// Notice the user that the route is not found under given information
+	w.WriteHeader(http.StatusNotFound)
+	w.Write([]byte("Not Found"))
+	return
+}
+
+// CompactCollection rewrites the files of a Collection without the data of deleted vectors
+func (r *Routes) CompactCollection(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	if req.Method == http.MethodPost && strings.ToLower(req.URL.String()) == "/compactcollection" {
+		// Limit the size of the request
+		req.Body = http.MaxBytesReader(w, req.Body, 5000)
+		// Parse the form
+		err := req.ParseForm()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Error parsing form"))
+			return
+		}
+
+		// load the request into the CompactCollection via json decode
+		cc := &CompactCollection{}
+		err = json.NewDecoder(req.Body).Decode(cc)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Error decoding json"))
+			return
+		}
+
+		// Check if Auth is valid
+		if r.ApiKeyHandler.CheckApiKey(cc.ApiKey) || r.validateCookie(req) {
+			// Check if Collection exists
+			if _, ok := r.DB.Collections[cc.CollectionName]; !ok {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("Collection does not exist"))
+				return
+			}
+
+			// There is a wait bool - if true the function will wait for the compaction to finish
+			if cc.Wait {
+				err = r.DB.Collections[cc.CollectionName].Compact()
+				if err != nil {
+					w.WriteHeader(http.StatusInternalServerError)
+					w.Write([]byte(err.Error()))
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte("Collection compacted"))
+				return
+			}
+
+			// Compact non blocking
+			go func() {
+				err := r.DB.Collections[cc.CollectionName].Compact()
+				if err != nil {
+					Logger.Log.Log("Error compacting collection: "+err.Error(), "ERROR")
+				}
+			}()
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("Compaction started"))
+			return
+		}
+
+		// not authorized
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("Unauthorized"))
+		return
+	}
+	// Notice the user that the route is not found under given information
+	w.WriteHeader(http.StatusNotFound)
+	w.Write([]byte("Not Found"))
+	return
+}
+
+// TrainIndex trains the vector index of a Collection on its vectors
+func (r *Routes) TrainIndex(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	if req.Method == http.MethodPost && strings.ToLower(req.URL.String()) == "/trainindex" {
+		// Limit the size of the request
+		req.Body = http.MaxBytesReader(w, req.Body, 5000)
+		// Parse the form
+		err := req.ParseForm()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Error parsing form"))
+			return
+		}
+
+		// load the request into the TrainIndex via json decode
+		ti := &TrainIndex{}
+		err = json.NewDecoder(req.Body).Decode(ti)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Error decoding json"))
+			return
+		}
+
+		// Check if Auth is valid
+		if r.ApiKeyHandler.CheckApiKey(ti.ApiKey) || r.validateCookie(req) {
+			// Check if Collection exists
+			if _, ok := r.DB.Collections[ti.CollectionName]; !ok {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("Collection does not exist"))
+				return
+			}
+
+			// Only collections with a vector index can be trained
+			if r.DB.Collections[ti.CollectionName].VectorIndex == nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("Collection has no vector index to train"))
+				return
+			}
+
+			// There is a wait bool - if true the function will wait for the training to finish
+			if ti.Wait {
+				err = r.DB.Collections[ti.CollectionName].TrainIndex()
+				if err != nil {
+					w.WriteHeader(http.StatusInternalServerError)
+					w.Write([]byte(err.Error()))
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte("Index trained"))
+				return
+			}
+
+			// Train non blocking
+			go func() {
+				err := r.DB.Collections[ti.CollectionName].TrainIndex()
+				if err != nil {
+					Logger.Log.Log("Error training index: "+err.Error(), "ERROR")
+				}
+			}()
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("Training started"))
+			return
+		}
+
+		// not authorized
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("Unauthorized"))
+		return
+	}
+	// Notice the user that the route is not found under given information
+	w.WriteHeader(http.StatusNotFound)
+	w.Write([]byte("Not Found"))
+	return
+}
+
+// SetSearchSettings sets the default search accuracy and the maximum number of distance calculations of a collection
+func (r *Routes) SetSearchSettings(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	if req.Method == http.MethodPost && strings.ToLower(req.URL.String()) == "/setsearchsettings" {
+		// Limit the size of the request
+		req.Body = http.MaxBytesReader(w, req.Body, 5000)
+		// Parse the form
+		err := req.ParseForm()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Error parsing form"))
+			return
+		}
+
+		// load the request into the SearchSettings via json decode
+		ss := &SearchSettings{}
+		err = json.NewDecoder(req.Body).Decode(ss)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Error decoding json"))
+			return
+		}
+
+		// Check if Auth is valid
+		if r.ApiKeyHandler.CheckApiKey(ss.ApiKey) || r.validateCookie(req) {
+			// Check if Collection exists
+			if _, ok := r.DB.Collections[ss.CollectionName]; !ok {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("Collection does not exist"))
+				return
+			}
+
+			// Check the search settings
+			err = vdbcollection.ValidateSearchSettings(ss.SearchAccuracy, ss.MaxChecks)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			err = r.DB.Collections[ss.CollectionName].SetSearchSettings(ss.SearchAccuracy, ss.MaxChecks, ss.AxisRange)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(err.Error()))
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("Search settings set"))
+			return
+		}
+
+		// not authorized
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("Unauthorized"))
+		return
+	}
+	// Notice the user that the route is not found under given information
+	w.WriteHeader(http.StatusNotFound)
+	w.Write([]byte("Not Found"))
+	return
+}
+
+// Recall measures the recall@k of the approximate search of a collection against the exact search
+func (r *Routes) Recall(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	if req.Method == http.MethodPost && strings.ToLower(req.URL.String()) == "/recall" {
+		// Parse the form
+		err := req.ParseForm()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Error parsing form"))
+			return
+		}
+
+		// load the request into the Recall via json decode
+		rc := &Recall{}
+		err = json.NewDecoder(req.Body).Decode(rc)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Error decoding json"))
+			return
+		}
+
+		// Check if Auth is valid
+		if r.ApiKeyHandler.CheckApiKey(rc.ApiKey) || r.validateCookie(req) {
+			// Check if Collection exists
+			if _, ok := r.DB.Collections[rc.CollectionName]; !ok {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("Collection does not exist"))
+				return
+			}
+
+			// Check if possible Filter is valid
+			if err := Filter.Check(rc.Filter); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			// Check the search settings
+			if err := vdbcollection.ValidateSearchSettings(rc.SearchAccuracy, rc.MaxChecks); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			// Set the defaults
+			if rc.K == 0 {
+				rc.K = 10
+			}
+			if rc.Samples == 0 {
+				rc.Samples = 100
+			}
+
+			report, err := r.DB.Recall(rc.CollectionName, rc.K, rc.Samples, rc.Filter, rc.Rescore,
+				Utils.SearchParams{EfSearch: rc.EfSearch, NProbe: rc.NProbe, Accuracy: rc.SearchAccuracy, MaxChecks: rc.MaxChecks,
+					AxisRange: rc.AxisRange})
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			// Send the report to the client
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(report)
+			return
+		}
+
+		// not authorized
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("Unauthorized"))
+		return
+	}
+	// Notice the user that the route is not found under given information
+	w.WriteHeader(http.StatusNotFound)
+	w.Write([]byte("Not Found"))
+	return
+}
+
+// PayloadCacheStats returns the hits, misses, evictions and the size of the payload cache
+func (r *Routes) PayloadCacheStats(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	if req.Method == http.MethodPost && strings.ToLower(req.URL.String()) == "/payloadcachestats" {
+		// Parse the form
+		err := req.ParseForm()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Error parsing form"))
+			return
+		}
+
+		// load the request into the PayloadCacheStats via json decode
+		ps := &PayloadCacheStats{}
+		err = json.NewDecoder(req.Body).Decode(ps)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Error decoding json"))
+			return
+		}
+
+		// Check if Auth is valid
+		if r.ApiKeyHandler.CheckApiKey(ps.ApiKey) || r.validateCookie(req) {
+			// Send the stats to the client
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(FileMapper.Mapper.Cache.Stats())
+			return
+		}
+
+		// not authorized
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("Unauthorized"))
+		return
+	}
+	// Notice the user that the route is not found under given information
+	w.WriteHeader(http.StatusNotFound)
+	w.Write([]byte("Not Found"))
+	return
+}
+
 // showapikey will show the apikey
 func (r *Routes) ShowApiKey(w http.ResponseWriter, req *http.Request) {
 	defer req.Body.Close()
@@ -2,6 +2,7 @@ package Server
 
 import (
 	"VreeDB/ApiKeyHandler"
+	vdbcollection "VreeDB/Collection"
 	"VreeDB/Filter"
 	"VreeDB/NN"
 	"VreeDB/Utils"
@@ -13,11 +14,18 @@ import (
 
 // CollectionCreator is the struct that creates a Collection in the VDB, when send by REST
 type CollectionCreator struct {
-	ApiKey           string `json:"api_key"` // Must not be present in the request
-	Name             string `json:"name"`
-	DistanceFunction string `json:"distance_function"`
-	Dimensions       int    `json:"dimensions"`
-	Wait             bool   `json:"wait"`
+	ApiKey           string              `json:"api_key"` // Must not be present in the request
+	Name             string              `json:"name"`
+	DistanceFunction string              `json:"distance_function"`
+	Dimensions       int                 `json:"dimensions"`
+	Precision        string              `json:"precision"`       // Must not be present in the request default float64
+	IndexType        string              `json:"index_type"`      // Must not be present in the request default kdtree
+	IndexParams      map[string]int      `json:"index_params"`    // Must not be present in the request
+	SearchAccuracy   float64             `json:"search_accuracy"` // Must not be present in the request
+	MaxChecks        int                 `json:"max_checks"`      // Must not be present in the request default unlimited
+	AxisRange        bool                `json:"axis_range"`      // Must not be present in the request default false
+	Schema           []Utils.SchemaField `json:"schema"`          // Must not be present in the request default no schema
+	Wait             bool                `json:"wait"`
 }
 
 // Used to delete a Collection, when send by REST
@@ -51,6 +59,13 @@ type Point struct {
 	Filter             *[]Filter.Filter       `json:"filter"`               // Must not be present in the request default nil
 	GetVectors         bool                   `json:"get_vectors"`          // Must not be present in the request default false
 	GetId              bool                   `json:"get_id"`               // Must not be present in the request default false
+	Rescore            bool                   `json:"rescore"`              // Must not be present in the request default false
+	EfSearch           int                    `json:"ef_search"`            // Must not be present in the request default of the hnsw index
+	NProbe             int                    `json:"nprobe"`               // Must not be present in the request default of the ivf index
+	Exact              bool                   `json:"exact"`                // Must not be present in the request default false
+	SearchAccuracy     float64                `json:"search_accuracy"`      // Must not be present in the request default of the collection
+	MaxChecks          int                    `json:"max_checks"`           // Must not be present in the request default of the collection
+	AxisRange          bool                   `json:"axis_range"`           // Must not be present in the request default of the collection
 }
 
 type PointItem struct {
@@ -176,6 +191,131 @@ type IndexCreator struct {
 	ApiKey         string `json:"api_key"`
 	CollectionName string `json:"collection_name"`
 	IndexName      string `json:"index_name"`
+	IndexType      string `json:"index_type"` // Must not be present in the request default value - range indexes only keep the sorted values
+}
+
+// ListIndexes is the struct that lists the payload indexes of a Collection, when send by REST
+type ListIndexes struct {
+	ApiKey         string `json:"api_key"`
+	CollectionName string `json:"collection_name"`
+}
+
+// DropIndex is the struct that deletes a payload index of a Collection, when send by REST
+type DropIndex struct {
+	ApiKey         string `json:"api_key"`
+	CollectionName string `json:"collection_name"`
+	IndexName      string `json:"index_name"`
+}
+
+// RebuildIndex is the struct that builds a payload index of a Collection again, when send by REST
+type RebuildIndex struct {
+	ApiKey         string `json:"api_key"`
+	CollectionName string `json:"collection_name"`
+	IndexName      string `json:"index_name"`
+	Wait           bool   `json:"wait"` // Must not be present in the request default false
+}
+
+// IndexStats is the struct that requests the statistics of a payload index of a Collection, when send by REST
+type IndexStats struct {
+	ApiKey         string `json:"api_key"`
+	CollectionName string `json:"collection_name"`
+	IndexName      string `json:"index_name"`
+}
+
+// CompactCollection is the struct that triggers the compaction of a Collection, when send by REST
+type CompactCollection struct {
+	ApiKey         string `json:"api_key"`
+	CollectionName string `json:"collection_name"`
+	Wait           bool   `json:"wait"` // Must not be present in the request default false
+}
+
+// TrainIndex is the struct that trains the vector index of a Collection, when send by REST
+type TrainIndex struct {
+	ApiKey         string `json:"api_key"`
+	CollectionName string `json:"collection_name"`
+	Wait           bool   `json:"wait"` // Must not be present in the request default false
+}
+
+// PayloadCacheStats is the struct that requests the metrics of the payload cache, when send by REST
+type PayloadCacheStats struct {
+	ApiKey string `json:"api_key"`
+}
+
+// Recall is the struct that measures the recall of the approximate search of a Collection, when send by REST
+type Recall struct {
+	ApiKey         string           `json:"api_key"`
+	CollectionName string           `json:"collection_name"`
+	K              int              `json:"k"`               // Must not be present in the request default 10
+	Samples        int              `json:"samples"`         // Must not be present in the request default 100
+	Filter         *[]Filter.Filter `json:"filter"`          // Must not be present in the request
+	Rescore        bool             `json:"rescore"`         // Must not be present in the request default false
+	EfSearch       int              `json:"ef_search"`       // Must not be present in the request default of the hnsw index
+	NProbe         int              `json:"nprobe"`          // Must not be present in the request default of the ivf index
+	SearchAccuracy float64          `json:"search_accuracy"` // Must not be present in the request default of the collection
+	MaxChecks      int              `json:"max_checks"`      // Must not be present in the request default of the collection
+	AxisRange      bool             `json:"axis_range"`      // Must not be present in the request default of the collection
+}
+
+// PatchPayload is the struct that patches the payloads of points of a Collection, when send by REST
+type PatchPayload struct {
+	ApiKey         string                 `json:"api_key"`
+	CollectionName string                 `json:"collection_name"`
+	Ids            []string               `json:"ids"`       // Must not be present in the request if a filter is present
+	Filter         *[]Filter.Filter       `json:"filter"`    // Must not be present in the request if ids are present
+	Set            map[string]interface{} `json:"set"`       // Must not be present in the request
+	Unset          []string               `json:"unset"`     // Must not be present in the request
+	Append         map[string]interface{} `json:"append"`    // Must not be present in the request
+	Increment      map[string]float64     `json:"increment"` // Must not be present in the request
+}
+
+// GetPoints is the struct that reads points of a Collection by their ids, when send by REST
+type GetPoints struct {
+	ApiKey         string   `json:"api_key"`
+	CollectionName string   `json:"collection_name"`
+	Ids            []string `json:"ids"`
+	GetVectors     bool     `json:"get_vectors"` // Must not be present in the request default false
+}
+
+// Scroll is the struct that pages through the points of a Collection in id order, when send by REST
+type Scroll struct {
+	ApiKey         string           `json:"api_key"`
+	CollectionName string           `json:"collection_name"`
+	Offset         string           `json:"offset"`      // Must not be present in the request default first id - the next_offset of the previous page with order_by
+	Limit          int              `json:"limit"`       // Must not be present in the request default 100
+	Filter         *[]Filter.Filter `json:"filter"`      // Must not be present in the request default nil
+	OrderBy        string           `json:"order_by"`    // Must not be present in the request default id order
+	Descending     bool             `json:"descending"`  // Must not be present in the request default false
+	GetVectors     bool             `json:"get_vectors"` // Must not be present in the request default false
+}
+
+// ScrollResult is the struct that contains a page of points and the offset of the next page
+type ScrollResult struct {
+	Points     []*vdbcollection.Point `json:"points"`
+	NextOffset string                 `json:"next_offset"` // Empty if there are no more points
+}
+
+// Count is the struct that counts the points of a Collection that pass a filter, when send by REST
+type Count struct {
+	ApiKey         string           `json:"api_key"`
+	CollectionName string           `json:"collection_name"`
+	Filter         *[]Filter.Filter `json:"filter"` // Must not be present in the request default nil
+	Facets         []string         `json:"facets"` // Must not be present in the request
+}
+
+// UpdateSchema is the struct that replaces the payload schema of a Collection, when send by REST
+type UpdateSchema struct {
+	ApiKey         string              `json:"api_key"`
+	CollectionName string              `json:"collection_name"`
+	Schema         []Utils.SchemaField `json:"schema"` // An empty schema removes the schema
+}
+
+// SearchSettings is the struct that sets the default search accuracy of a Collection, when send by REST
+type SearchSettings struct {
+	ApiKey         string  `json:"api_key"`
+	CollectionName string  `json:"collection_name"`
+	SearchAccuracy float64 `json:"search_accuracy"`
+	MaxChecks      int     `json:"max_checks"`
+	AxisRange      bool    `json:"axis_range"` // Must not be present in the request default false
 }
 
 type TSNE struct {
@@ -193,14 +333,7 @@ type TSNEData struct {
 
 // ValidateFilter will validate the filters in Point
 func (p *Point) ValidateFilter() error {
-	if p.Filter != nil {
-		for _, filter := range *p.Filter {
-			if err := filter.Op.IsValid(); err != nil {
-				return err
-			}
-		}
-	}
-	return nil
+	return Filter.Check(p.Filter)
 }
 
 // NewData creates new Data Structure for the web page
@@ -142,9 +142,9 @@ func (mcs *MultiClassSVM) Train(data []*Vector.Vector, epochs int, C float64, de
 		modifiedData := make([]*Vector.Vector, len(data))
 		for i, point := range data {
 			if int((*point.Payload)["Label"].(float64)) == class {
-				modifiedData[i] = &Vector.Vector{Data: point.Data, Payload: &map[string]interface{}{"Label": 1}, PayloadStart: point.PayloadStart}
+				modifiedData[i] = &Vector.Vector{Data: *point.GetData(), Payload: &map[string]interface{}{"Label": 1}, PayloadStart: point.PayloadStart}
 			} else {
-				modifiedData[i] = &Vector.Vector{Data: point.Data, Payload: &map[string]interface{}{"Label": -1}, PayloadStart: point.PayloadStart}
+				modifiedData[i] = &Vector.Vector{Data: *point.GetData(), Payload: &map[string]interface{}{"Label": -1}, PayloadStart: point.PayloadStart}
 			}
 		}
 		Logger.Log.Log("Training SVM for class "+fmt.Sprint(class), "INFO")
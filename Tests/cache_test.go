@@ -0,0 +1,98 @@
+// cache_test.go
+package Collection
+
+import (
+	"VreeDB/FileMapper"
+	"VreeDB/Vector"
+	"strings"
+	"testing"
+)
+
+func TestPayloadCache(t *testing.T) {
+	entry := &map[string]interface{}{"n": 1.0}
+	measure := FileMapper.NewPayloadCache(1 << 20)
+	measure.Add("c", 0, entry)
+	size := measure.Stats().Size
+
+	// The cache holds three payloads
+	cache := FileMapper.NewPayloadCache(3*size + size/2)
+	for offset := int64(0); offset < 3; offset++ {
+		cache.Add("c", offset*100, &map[string]interface{}{"n": float64(offset)})
+	}
+	if payload, ok := cache.Get("c", 0); !ok || (*payload)["n"] != 0.0 {
+		t.Fatalf("Expected the payload at offset 0 to be cached")
+	}
+	// The least recently used payload is evicted
+	cache.Add("c", 300, entry)
+	if _, ok := cache.Get("c", 100); ok {
+		t.Errorf("Expected the payload at offset 100 to be evicted")
+	}
+	for _, offset := range []int64{0, 200, 300} {
+		if _, ok := cache.Get("c", offset); !ok {
+			t.Errorf("Expected the payload at offset %d to be cached", offset)
+		}
+	}
+	stats := cache.Stats()
+	if stats.Hits != 4 || stats.Misses != 1 || stats.Evictions != 1 || stats.Entries != 3 || stats.Size != 3*size {
+		t.Errorf("Expected 4 hits, 1 miss, 1 eviction and 3 entries of %d bytes, got %+v", size, stats)
+	}
+
+	// Payloads larger than the cache are not cached
+	cache.Add("c", 400, &map[string]interface{}{"text": strings.Repeat("x", int(4*size))})
+	if _, ok := cache.Get("c", 400); ok || cache.Stats().Entries != 3 {
+		t.Errorf("Expected a payload larger than the cache not to be cached")
+	}
+
+	// Dropping a collection keeps the payloads of the others
+	cache.Add("other", 0, entry)
+	cache.Drop("c")
+	if stats := cache.Stats(); stats.Entries != 1 || stats.Size > size+size/2 {
+		t.Errorf("Expected only the payload of the other collection, got %+v", stats)
+	}
+
+	disabled := FileMapper.NewPayloadCache(0)
+	disabled.Add("c", 0, entry)
+	if _, ok := disabled.Get("c", 0); ok || disabled.Stats() != (FileMapper.CacheStats{}) {
+		t.Errorf("Expected a cache with a limit of 0 to be disabled, got %+v", disabled.Stats())
+	}
+}
+
+func TestPayloadCacheCompaction(t *testing.T) {
+	useTempStore(t)
+	cache := FileMapper.Mapper.Cache
+	FileMapper.Mapper.Cache = FileMapper.NewPayloadCache(1 << 20)
+	t.Cleanup(func() {
+		FileMapper.Mapper.Cache = cache
+	})
+	collection := newCollection(t, "cached", 2, "euclid", Vector.Float64)
+	for i, name := range []string{"a", "b", "c"} {
+		insert(t, collection, name, []float64{float64(i), 0}, map[string]interface{}{"name": name})
+	}
+	for range 2 {
+		for _, name := range []string{"a", "b", "c"} {
+			if got := payload(t, collection, name)["name"]; got != name {
+				t.Fatalf("Expected the payload of %s, got %v", name, got)
+			}
+		}
+	}
+	if stats := FileMapper.Mapper.Cache.Stats(); stats.Hits != 3 || stats.Misses != 3 || stats.Entries != 3 {
+		t.Errorf("Expected 3 hits and 3 misses, got %+v", stats)
+	}
+
+	// The compaction moves the payloads - new payloads are written to the old offsets
+	err := collection.DeleteVectorByID([]string{"a", "b"})
+	if err != nil {
+		t.Fatalf("Deleting failed: %s", err)
+	}
+	err = collection.Compact()
+	if err != nil {
+		t.Fatalf("Compacting failed: %s", err)
+	}
+	insert(t, collection, "d", []float64{3, 0}, map[string]interface{}{"name": "d"})
+	insert(t, collection, "e", []float64{4, 0}, map[string]interface{}{"name": "e"})
+	for _, name := range []string{"c", "d", "e"} {
+		if got := payload(t, collection, name)["name"]; got != name {
+			t.Errorf("Expected the payload of %s after the compaction, got %v", name, got)
+		}
+	}
+}
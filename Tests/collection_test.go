@@ -8,8 +8,14 @@ import (
 )
 
 func TestNewCollection(t *testing.T) {
+	useTempStore(t)
+
 	// Creating a new collection
-	collection := Collection.NewCollection("test_collection", 3, "euclid")
+	collection, err := Collection.NewCollection("test_collection", 3, "euclid", Vector.Float64)
+	if err != nil {
+		t.Fatalf("Creating collection failed: %s", err)
+	}
+	defer collection.Wal.Close()
 
 	// Check if the collection was created successfully
 	if collection.Name != "test_collection" {
@@ -27,11 +33,13 @@ func TestNewCollection(t *testing.T) {
 }
 
 func TestInsert(t *testing.T) {
+	useTempStore(t)
+
 	// Creating a new collection
-	collection := Collection.NewCollection("test_collection", 3, "euclid")
+	collection := newCollection(t, "test_collection", 3, "euclid", Vector.Float64)
 
 	// Creating a vector to insert
-	vector := &Vector.Vector{Id: "v1", Data: []float64{1, 2, 3}, Length: 3}
+	vector := Vector.NewVector("v1", []float64{1, 2, 3}, &map[string]interface{}{}, collection.Name)
 
 	// Inserting the vector
 	err := collection.Insert(vector)
@@ -46,11 +54,13 @@ func TestInsert(t *testing.T) {
 }
 
 func TestInsertDifferentDimension(t *testing.T) {
+	useTempStore(t)
+
 	// Creating a new collection
-	collection := Collection.NewCollection("test_collection", 3, "euclid")
+	collection := newCollection(t, "test_collection", 3, "euclid", Vector.Float64)
 
 	// Creating a vector with a different dimension
-	vector := &Vector.Vector{Id: "v1", Data: []float64{1, 2}, Length: 2}
+	vector := Vector.NewVector("v1", []float64{1, 2}, &map[string]interface{}{}, collection.Name)
 
 	// Inserting the vector
 	err := collection.Insert(vector)
@@ -60,11 +70,13 @@ func TestInsertDifferentDimension(t *testing.T) {
 }
 
 func TestDeleteVectorByID(t *testing.T) {
+	useTempStore(t)
+
 	// Creating a new collection
-	collection := Collection.NewCollection("test_collection", 3, "euclid")
+	collection := newCollection(t, "test_collection", 3, "euclid", Vector.Float64)
 
 	// Creating a vector to insert
-	vector := &Vector.Vector{Id: "v1", Data: []float64{1, 2, 3}, Length: 3}
+	vector := Vector.NewVector("v1", []float64{1, 2, 3}, &map[string]interface{}{}, collection.Name)
 
 	// Inserting the vector
 	err := collection.Insert(vector)
@@ -78,6 +90,9 @@ func TestDeleteVectorByID(t *testing.T) {
 		t.Errorf("Deleting vector failed: %s", err)
 	}
 
+	// Remove the marked vector like the DeleteWatcher does
+	collection.DeleteMarkedVectors()
+
 	// Check if the vector was deleted
 	if _, ok := (*collection.Space)["v1"]; ok {
 		t.Errorf("Expected vector with ID 'v1' to be deleted")
@@ -0,0 +1,229 @@
+// compaction_test.go
+package Collection
+
+import (
+	"VreeDB/Collection"
+	"VreeDB/FileMapper"
+	"VreeDB/Vector"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// compactFiles returns the data, meta and config file of the collection in the order a compaction swaps them
+func compactFiles(name string) []string {
+	return []string{"collections/" + name + ".bin", "collections/" + name + "_meta.bin", "collections/" + name + ".json"}
+}
+
+// readFiles returns the contents of the files
+func readFiles(t *testing.T, names []string) map[string][]byte {
+	t.Helper()
+	files := make(map[string][]byte, len(names))
+	for _, name := range names {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("Reading %s failed: %s", name, err)
+		}
+		files[name] = data
+	}
+	return files
+}
+
+// crash writes the files as a compaction leaves them when it crashes after the first swapped files were replaced
+// by their new version: the other files are old and their new version is next to them
+func crash(t *testing.T, names []string, old, new map[string][]byte, swapped int) {
+	t.Helper()
+	for i, name := range names {
+		var err error
+		if i < swapped {
+			err = os.WriteFile(name, new[name], 0644)
+		} else {
+			err = os.WriteFile(name, old[name], 0644)
+			if err == nil {
+				err = os.WriteFile(name+".compact", new[name], 0644)
+			}
+		}
+		if err != nil {
+			t.Fatalf("Writing %s failed: %s", name, err)
+		}
+	}
+}
+
+// fillCollection inserts the vectors 0 to n-1 and deletes the first half of them
+func fillCollection(t *testing.T, collection *Collection.Collection, n int) {
+	t.Helper()
+	ids := make([]string, 0, n/2)
+	for i := 0; i < n; i++ {
+		insert(t, collection, strconv.Itoa(i), []float64{float64(i), float64(-i)}, map[string]interface{}{"n": float64(i)})
+		if i < n/2 {
+			ids = append(ids, strconv.Itoa(i))
+		}
+	}
+	err := collection.DeleteVectorByID(ids)
+	if err != nil {
+		t.Fatalf("Deleting vectors failed: %s", err)
+	}
+}
+
+// checkCollection checks that the collection holds the second half of the vectors of fillCollection and that no
+// files of a compaction are left
+func checkCollection(t *testing.T, collection *Collection.Collection, n int) {
+	t.Helper()
+	if collection == nil {
+		t.Fatalf("Expected the collection to be restored")
+	}
+	alive := 0
+	for _, vector := range *collection.Space {
+		if !vector.IsDeleted() {
+			alive++
+		}
+	}
+	if alive != n-n/2 {
+		t.Errorf("Expected %d vectors, got %d", n-n/2, alive)
+	}
+	for i := n / 2; i < n; i++ {
+		id := strconv.Itoa(i)
+		vector, ok := (*collection.Space)[id]
+		if !ok {
+			t.Errorf("Expected vector with ID %s to be restored", id)
+			continue
+		}
+		if data := *vector.GetData(); !slices.Equal(data, []float64{float64(i), float64(-i)}) {
+			t.Errorf("Expected data [%d %d] of vector %s, got %v", i, -i, id, data)
+		}
+		if value := payload(t, collection, id)["n"]; value != float64(i) {
+			t.Errorf("Expected payload n = %d of vector %s, got %v", i, id, value)
+		}
+	}
+	for _, name := range compactFiles(collection.Name) {
+		if _, err := os.Stat(name + ".compact"); err == nil {
+			t.Errorf("Expected %s.compact to be removed", name)
+		}
+	}
+}
+
+func TestCompact(t *testing.T) {
+	useTempStore(t)
+	collection := newCollection(t, "compact", 2, "euclid", Vector.Float64)
+	fillCollection(t, collection, 10)
+
+	err := collection.Compact()
+	if err != nil {
+		t.Fatalf("Compacting failed: %s", err)
+	}
+	if collection.DeadBytes != 0 {
+		t.Errorf("Expected no dead bytes after the compaction, got %d", collection.DeadBytes)
+	}
+	checkCollection(t, collection, 10)
+	checkCollection(t, reboot(t, collection)["compact"], 10)
+}
+
+func TestCompactionCrashRecovery(t *testing.T) {
+	// A compaction swaps the data file and then the meta file, the config is not changed
+	for swapped := 0; swapped < 2; swapped++ {
+		t.Run(fmt.Sprintf("%d files swapped", swapped), func(t *testing.T) {
+			useTempStore(t)
+			collection := newCollection(t, "compact", 2, "euclid", Vector.Float64)
+			fillCollection(t, collection, 10)
+			names := compactFiles(collection.Name)[:2]
+			old := readFiles(t, names)
+			err := collection.Compact()
+			if err != nil {
+				t.Fatalf("Compacting failed: %s", err)
+			}
+			new := readFiles(t, names)
+
+			crash(t, names, old, new, swapped)
+			checkCollection(t, reboot(t, collection)["compact"], 10)
+		})
+	}
+}
+
+// rewrite writes the live vectors of the collection in the vector format
+func rewrite(t *testing.T, collection *Collection.Collection, format int) {
+	t.Helper()
+	live := make([]*Vector.Vector, 0, len(*collection.Space))
+	for _, vector := range *collection.Space {
+		if !vector.IsDeleted() {
+			live = append(live, vector)
+		}
+	}
+	slices.SortFunc(live, func(a, b *Vector.Vector) int {
+		return int(a.SaveVectorPosition - b.SaveVectorPosition)
+	})
+	vectors := make([]FileMapper.SaveVector, len(live))
+	for i, vector := range live {
+		vectors[i] = FileMapper.SaveVector{VectorID: vector.Id, DataStart: vector.DataStart, PayloadStart: vector.PayloadStart}
+	}
+	_, err := FileMapper.Mapper.Compact(collection.Name, vectors, collection.VectorDimension, format, nil)
+	if err != nil {
+		t.Fatalf("Rewriting the collection failed: %s", err)
+	}
+	collection.FormatVersion = format
+	err = collection.WriteConfig()
+	if err != nil {
+		t.Fatalf("Writing config failed: %s", err)
+	}
+}
+
+func TestUpgradeCrashRecovery(t *testing.T) {
+	useTempStore(t)
+	collection := newCollection(t, "upgrade", 2, "euclid", Vector.Float64)
+	fillCollection(t, collection, 10)
+
+	// Rewrite the collection in the format of older versions
+	rewrite(t, collection, FileMapper.FormatGob)
+	names := compactFiles(collection.Name)
+	old := readFiles(t, names)
+
+	// The boot upgrades the collection
+	booted := reboot(t, collection)["upgrade"]
+	checkCollection(t, booted, 10)
+	if booted.FormatVersion != FileMapper.FormatRaw {
+		t.Fatalf("Expected format %d after the upgrade, got %d", FileMapper.FormatRaw, booted.FormatVersion)
+	}
+	new := readFiles(t, names)
+
+	// An upgrade swaps the data file, the meta file and then the config
+	for swapped := 0; swapped < 3; swapped++ {
+		crash(t, names, old, new, swapped)
+		booted = reboot(t, booted)["upgrade"]
+		checkCollection(t, booted, 10)
+		if booted.FormatVersion != FileMapper.FormatRaw {
+			t.Errorf("Expected format %d after a crash with %d files swapped, got %d", FileMapper.FormatRaw,
+				swapped, booted.FormatVersion)
+		}
+	}
+}
+
+func TestFloat32Format(t *testing.T) {
+	useTempStore(t)
+	collection := newCollection(t, "float32", 3, "euclid", Vector.Float32)
+	if collection.FormatVersion != FileMapper.FormatRaw32 {
+		t.Fatalf("Expected format %d for a float32 collection, got %d", FileMapper.FormatRaw32, collection.FormatVersion)
+	}
+	data := []float64{1.5, -2.25, 0.1}
+	insert(t, collection, "a", data, map[string]interface{}{"n": 1.0})
+	if length := (*collection.Space)["a"].CLength; length != 4*len(data) {
+		t.Errorf("Expected a record of %d bytes, got %d", 4*len(data), length)
+	}
+	stored := []float64{1.5, -2.25, float64(float32(0.1))}
+	if got := *(*collection.Space)["a"].GetData(); !slices.Equal(got, stored) {
+		t.Errorf("Expected the data %v, got %v", stored, got)
+	}
+
+	// Float32 collections of older versions are stored as float64 and upgraded during boot
+	rewrite(t, collection, FileMapper.FormatRaw)
+	booted := reboot(t, collection)["float32"]
+	if booted.FormatVersion != FileMapper.FormatRaw32 {
+		t.Fatalf("Expected format %d after the upgrade, got %d", FileMapper.FormatRaw32, booted.FormatVersion)
+	}
+	if got := *(*booted.Space)["a"].GetData(); !slices.Equal(got, stored) {
+		t.Errorf("Expected the data %v after the upgrade, got %v", stored, got)
+	}
+	if n := payload(t, booted, "a")["n"]; n != 1.0 {
+		t.Errorf("Expected the payload n = 1 after the upgrade, got %v", n)
+	}
+}
@@ -0,0 +1,106 @@
+// count_test.go
+package Collection
+
+import (
+	"VreeDB/Collection"
+	"VreeDB/Filter"
+	"VreeDB/Vector"
+	"maps"
+	"math"
+	"testing"
+)
+
+// countCollection returns a collection of products with a category, a price and tags
+func countCollection(t *testing.T) *Collection.Collection {
+	t.Helper()
+	useTempStore(t)
+	collection := newCollection(t, "products", 2, "euclid", Vector.Float64)
+	products := []map[string]interface{}{
+		{"category": "tool", "price": 12.0, "tags": []interface{}{"steel", "red"}},
+		{"category": "tool", "price": 30.0, "tags": []interface{}{"steel"}},
+		{"category": "toy", "price": 5.5, "tags": []interface{}{"red", "wood"}},
+		{"category": "toy", "price": 8.5},
+		{"category": "book", "price": "free"},
+		{"tags": []interface{}{}},
+	}
+	for i, product := range products {
+		insert(t, collection, string(rune('a'+i)), []float64{float64(i), 1}, product)
+	}
+	return collection
+}
+
+// checkCount checks the count and the facets of the filter
+func checkCount(t *testing.T, collection *Collection.Collection, filter *[]Filter.Filter, count int, facets map[string]map[string]int) *Collection.CountResult {
+	t.Helper()
+	keys := make([]string, 0, len(facets))
+	for key := range facets {
+		keys = append(keys, key)
+	}
+	result, err := collection.Count(filter, keys)
+	if err != nil {
+		t.Fatalf("Counting failed: %s", err)
+	}
+	if result.Count != count {
+		t.Errorf("Expected a count of %d, got %d", count, result.Count)
+	}
+	for key, counts := range facets {
+		if !maps.Equal(result.Facets[key].Counts, counts) {
+			t.Errorf("Expected the facet %s %v, got %v", key, counts, result.Facets[key].Counts)
+		}
+	}
+	return result
+}
+
+func TestCount(t *testing.T) {
+	collection := countCollection(t)
+	result := checkCount(t, collection, nil, 6, map[string]map[string]int{
+		"category": {"tool": 2, "toy": 2, "book": 1},
+		"tags":     {"steel": 2, "red": 2, "wood": 1},
+	})
+	if len(result.Facets) != 2 {
+		t.Errorf("Expected 2 facets, got %d", len(result.Facets))
+	}
+
+	// Only numbers are aggregated
+	result = checkCount(t, collection, nil, 6, map[string]map[string]int{
+		"price": {"12": 1, "30": 1, "5.5": 1, "8.5": 1, "free": 1},
+	})
+	price := result.Facets["price"]
+	if price.Numeric != 4 || price.Min != 5.5 || price.Max != 30 || math.Abs(price.Avg-14) > 1e-9 {
+		t.Errorf("Expected 4 prices from 5.5 to 30 with an average of 14, got %+v", *price)
+	}
+
+	toys := []Filter.Filter{{Field: "category", Op: Filter.Equal, Value: "toy"}}
+	result = checkCount(t, collection, &toys, 2, map[string]map[string]int{"tags": {"red": 1, "wood": 1}})
+	if result.Facets["price"] != nil {
+		t.Errorf("Expected no facet of price")
+	}
+	missing := []Filter.Filter{{Field: "category", Op: Filter.Equal, Value: "food"}}
+	checkCount(t, collection, &missing, 0, map[string]map[string]int{"category": {}})
+}
+
+func TestCountIndexed(t *testing.T) {
+	collection := countCollection(t)
+	err := collection.CreateIndex("category", "category", "")
+	if err != nil {
+		t.Fatalf("Creating the index failed: %s", err)
+	}
+	err = collection.DeleteVectorByID([]string{"b"})
+	if err != nil {
+		t.Fatalf("Deleting failed: %s", err)
+	}
+	err = collection.Upsert("c", nil, &map[string]interface{}{"category": "tool", "price": 6.0})
+	if err != nil {
+		t.Fatalf("Upserting failed: %s", err)
+	}
+
+	// The facets of the index and the scan of the entry skip deleted and replaced vectors
+	checkCount(t, collection, nil, 5, map[string]map[string]int{
+		"category": {"tool": 2, "toy": 1, "book": 1},
+	})
+	tools := []Filter.Filter{{Field: "category", Op: Filter.Equal, Value: "tool"}}
+	checkCount(t, collection, &tools, 2, map[string]map[string]int{
+		"category": {"tool": 2},
+		"tags":     {"steel": 1, "red": 1},
+	})
+}
@@ -0,0 +1,244 @@
+// filter_test.go
+package Collection
+
+import (
+	"VreeDB/Collection"
+	"VreeDB/Filter"
+	"VreeDB/Vector"
+	"slices"
+	"testing"
+	"time"
+)
+
+// filterCollection returns a collection with payloads of all types for the filter tests
+func filterCollection(t *testing.T) *Collection.Collection {
+	t.Helper()
+	useTempStore(t)
+	collection := newCollection(t, "filter", 2, "euclid", Vector.Float64)
+	insert(t, collection, "a", []float64{1, 1}, map[string]interface{}{
+		"name": "apple", "price": 1.5, "tags": []interface{}{"fruit", "red"},
+		"meta":    map[string]interface{}{"lang": "en", "score": 10.0},
+		"items":   []interface{}{map[string]interface{}{"sku": "x1", "qty": 2.0}, map[string]interface{}{"sku": "x2", "qty": 5.0}},
+		"loc":     map[string]interface{}{"lat": 52.52, "lon": 13.405},
+		"created": "2024-01-10T00:00:00Z", "note": nil,
+	})
+	insert(t, collection, "b", []float64{2, 2}, map[string]interface{}{
+		"name": "banana", "price": 0.5, "tags": []interface{}{"fruit", "yellow"},
+		"meta":    map[string]interface{}{"lang": "de", "score": 5.0},
+		"items":   []interface{}{map[string]interface{}{"sku": "y1", "qty": 1.0}},
+		"loc":     map[string]interface{}{"lat": 48.137, "lon": 11.575},
+		"created": "2024-03-01T12:00:00Z",
+	})
+	insert(t, collection, "c", []float64{3, 3}, map[string]interface{}{
+		"name": "carrot", "price": 2.0, "tags": []interface{}{"vegetable"},
+		"meta":    map[string]interface{}{"lang": "en"},
+		"loc":     map[string]interface{}{"lat": 40.7128, "lon": -74.006},
+		"created": time.Now().Add(-time.Hour).UTC().Format(time.RFC3339), "stored": "now-1d",
+	})
+	insert(t, collection, "d", []float64{4, 4}, map[string]interface{}{
+		"name": "date", "price": 3.0, "created": "not a date", "meta.lang": "fr",
+	})
+	return collection
+}
+
+// matches returns the sorted ids of the vectors that pass the filters
+func matches(t *testing.T, collection *Collection.Collection, filters []Filter.Filter) []string {
+	t.Helper()
+	err := Filter.Check(&filters)
+	if err != nil {
+		t.Fatalf("Checking filters %v failed: %s", filters, err)
+	}
+	ids := []string{}
+	for id, vector := range *collection.Space {
+		ok, err := Filter.Evaluate(&filters, vector)
+		if err != nil {
+			t.Fatalf("Evaluating filters %v failed: %s", filters, err)
+		}
+		if ok {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// filterCase is a list of filters and the ids of the vectors that pass them
+type filterCase struct {
+	name    string
+	filters []Filter.Filter
+	ids     []string
+}
+
+// runFilterCases checks the ids of the vectors that pass the filters of the cases
+func runFilterCases(t *testing.T, collection *Collection.Collection, cases []filterCase) {
+	t.Helper()
+	for _, c := range cases {
+		if ids := matches(t, collection, c.filters); !slices.Equal(ids, c.ids) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.ids, ids)
+		}
+	}
+}
+
+func TestFilterGroups(t *testing.T) {
+	collection := filterCollection(t)
+	fruit := Filter.Filter{Field: "tags", Op: Filter.Contains, Value: "fruit"}
+	cheap := Filter.Filter{Field: "price", Op: Filter.LessThan, Value: 1.0}
+	english := Filter.Filter{Field: "meta.lang", Op: Filter.Equal, Value: "en"}
+
+	runFilterCases(t, collection, []filterCase{
+		{"no filter", nil, []string{"a", "b", "c", "d"}},
+		{"list is and", []Filter.Filter{fruit, english}, []string{"a"}},
+		{"must", []Filter.Filter{{Must: []Filter.Filter{fruit, cheap}}}, []string{"b"}},
+		{"should", []Filter.Filter{{Should: []Filter.Filter{cheap, english}}}, []string{"a", "b", "c"}},
+		{"must not", []Filter.Filter{{MustNot: []Filter.Filter{fruit}}}, []string{"c", "d"}},
+		{"must and should", []Filter.Filter{{Must: []Filter.Filter{fruit}, Should: []Filter.Filter{cheap, english}}}, []string{"a", "b"}},
+		{"should and must not", []Filter.Filter{{Should: []Filter.Filter{fruit, english}, MustNot: []Filter.Filter{cheap}}}, []string{"a", "c"}},
+		{"nested", []Filter.Filter{{Should: []Filter.Filter{
+			{Must: []Filter.Filter{fruit, english}},
+			{MustNot: []Filter.Filter{fruit, english}},
+		}}}, []string{"a", "d"}},
+	})
+
+	// A group must not have a field or an operator
+	invalid := []Filter.Filter{{Field: "name", Must: []Filter.Filter{fruit}}}
+	if err := Filter.Check(&invalid); err == nil {
+		t.Errorf("Expected an error for a group with a field")
+	}
+	invalid = []Filter.Filter{{Must: []Filter.Filter{{Field: "name", Op: "like", Value: "a"}}}}
+	if err := Filter.Check(&invalid); err == nil {
+		t.Errorf("Expected an error for an invalid operator in a group")
+	}
+}
+
+func TestFilterOperators(t *testing.T) {
+	collection := filterCollection(t)
+	runFilterCases(t, collection, []filterCase{
+		{"eq", []Filter.Filter{{Field: "name", Op: Filter.Equal, Value: "apple"}}, []string{"a"}},
+		{"eq number types", []Filter.Filter{{Field: "price", Op: Filter.Equal, Value: 3}}, []string{"d"}},
+		{"ne", []Filter.Filter{{Field: "name", Op: Filter.NotEqual, Value: "apple"}}, []string{"b", "c", "d"}},
+		{"gt", []Filter.Filter{{Field: "price", Op: Filter.GreaterThan, Value: 1.5}}, []string{"c", "d"}},
+		{"ge", []Filter.Filter{{Field: "price", Op: Filter.GreaterThanOrEqual, Value: 1.5}}, []string{"a", "c", "d"}},
+		{"lt", []Filter.Filter{{Field: "price", Op: Filter.LessThan, Value: 1.5}}, []string{"b"}},
+		{"le", []Filter.Filter{{Field: "price", Op: Filter.LessThanOrEqual, Value: 1.5}}, []string{"a", "b"}},
+		{"gt string", []Filter.Filter{{Field: "name", Op: Filter.GreaterThan, Value: "banana"}}, []string{"c", "d"}},
+		{"between", []Filter.Filter{{Field: "price", Op: Filter.Between, Value: []interface{}{1.0, 2.0}}}, []string{"a", "c"}},
+		{"in", []Filter.Filter{{Field: "name", Op: Filter.In, Value: []interface{}{"apple", "date", "fig"}}}, []string{"a", "d"}},
+		{"in array", []Filter.Filter{{Field: "tags", Op: Filter.In, Value: []interface{}{"red", "vegetable"}}}, []string{"a", "c"}},
+		{"not_in", []Filter.Filter{{Field: "tags", Op: Filter.NotIn, Value: []interface{}{"fruit"}}}, []string{"c"}},
+		{"inand", []Filter.Filter{{Field: "tags", Op: Filter.InAnd, Value: []interface{}{"fruit", "red"}}}, []string{"a"}},
+		{"prefix", []Filter.Filter{{Field: "name", Op: Filter.Prefix, Value: "ba"}}, []string{"b"}},
+		{"contains string", []Filter.Filter{{Field: "name", Op: Filter.Contains, Value: "an"}}, []string{"b"}},
+		{"contains array", []Filter.Filter{{Field: "tags", Op: Filter.Contains, Value: "yellow"}}, []string{"b"}},
+		{"regex", []Filter.Filter{{Field: "name", Op: Filter.Regex, Value: "^[a-c].*t$"}}, []string{"c"}},
+		{"exists", []Filter.Filter{{Field: "tags", Op: Filter.Exists}}, []string{"a", "b", "c"}},
+		{"exists null", []Filter.Filter{{Field: "note", Op: Filter.Exists}}, []string{}},
+		{"is_null", []Filter.Filter{{Field: "note", Op: Filter.IsNull}}, []string{"a", "b", "c", "d"}},
+		{"is_null present", []Filter.Filter{{Field: "tags", Op: Filter.IsNull}}, []string{"d"}},
+		{"other type", []Filter.Filter{{Field: "name", Op: Filter.GreaterThan, Value: 1.0}}, []string{}},
+	})
+
+	// Invalid values are rejected by the check
+	for _, invalid := range []Filter.Filter{
+		{Field: "name", Op: Filter.Regex, Value: "("},
+		{Field: "price", Op: Filter.Between, Value: []interface{}{1.0}},
+		{Field: "price", Op: Filter.Between, Value: []interface{}{1.0, "b"}},
+		{Field: "name", Op: "like", Value: "a"},
+	} {
+		filters := []Filter.Filter{invalid}
+		if err := Filter.Check(&filters); err == nil {
+			t.Errorf("Expected an error for the filter %v", invalid)
+		}
+	}
+}
+
+func TestFilterPaths(t *testing.T) {
+	collection := filterCollection(t)
+	runFilterCases(t, collection, []filterCase{
+		// The literal key meta.lang of d wins over the path
+		{"nested key", []Filter.Filter{{Field: "meta.lang", Op: Filter.Equal, Value: "en"}}, []string{"a", "c"}},
+		{"literal key", []Filter.Filter{{Field: "meta.lang", Op: Filter.Equal, Value: "fr"}}, []string{"d"}},
+		{"nested number", []Filter.Filter{{Field: "meta.score", Op: Filter.GreaterThan, Value: 6}}, []string{"a"}},
+		{"nested exists", []Filter.Filter{{Field: "meta.score", Op: Filter.Exists}}, []string{"a", "b"}},
+		{"array of objects", []Filter.Filter{{Field: "items.sku", Op: Filter.Equal, Value: "x2"}}, []string{"a"}},
+		{"array of objects in", []Filter.Filter{{Field: "items.sku", Op: Filter.In, Value: []interface{}{"x1", "y1"}}}, []string{"a", "b"}},
+		{"array of objects range", []Filter.Filter{{Field: "items.qty", Op: Filter.GreaterThan, Value: 4}}, []string{"a"}},
+		{"array index", []Filter.Filter{{Field: "items[1].qty", Op: Filter.Equal, Value: 5}}, []string{"a"}},
+		{"array index missing", []Filter.Filter{{Field: "items[1].qty", Op: Filter.Exists}}, []string{"a"}},
+		{"array element", []Filter.Filter{{Field: "tags[0]", Op: Filter.Equal, Value: "vegetable"}}, []string{"c"}},
+		{"missing path", []Filter.Filter{{Field: "meta.lang.code", Op: Filter.Exists}}, []string{}},
+	})
+}
+
+func TestFilterGeo(t *testing.T) {
+	collection := filterCollection(t)
+	berlin := func(radius float64) map[string]interface{} {
+		return map[string]interface{}{"lat": 52.52, "lon": 13.405, "radius": radius}
+	}
+	germany := map[string]interface{}{
+		"top_left":     map[string]interface{}{"lat": 55.1, "lon": 5.9},
+		"bottom_right": map[string]interface{}{"lat": 47.3, "lon": 15.0},
+	}
+	runFilterCases(t, collection, []filterCase{
+		{"radius", []Filter.Filter{{Field: "loc", Op: Filter.GeoRadius, Value: berlin(10000)}}, []string{"a"}},
+		// Munich is about 504 km from Berlin
+		{"larger radius", []Filter.Filter{{Field: "loc", Op: Filter.GeoRadius, Value: berlin(600000)}}, []string{"a", "b"}},
+		{"smaller radius", []Filter.Filter{{Field: "loc", Op: Filter.GeoRadius, Value: berlin(450000)}}, []string{"a"}},
+		{"bbox", []Filter.Filter{{Field: "loc", Op: Filter.GeoBBox, Value: germany}}, []string{"a", "b"}},
+	})
+
+	for _, invalid := range []Filter.Filter{
+		{Field: "loc", Op: Filter.GeoRadius, Value: map[string]interface{}{"lat": 52.52, "lon": 13.405}},
+		{Field: "loc", Op: Filter.GeoRadius, Value: map[string]interface{}{"lat": 91.0, "lon": 13.405, "radius": 1.0}},
+		{Field: "loc", Op: Filter.GeoBBox, Value: map[string]interface{}{
+			"top_left":     map[string]interface{}{"lat": 47.3, "lon": 5.9},
+			"bottom_right": map[string]interface{}{"lat": 55.1, "lon": 15.0},
+		}},
+	} {
+		filters := []Filter.Filter{invalid}
+		if err := Filter.Check(&filters); err == nil {
+			t.Errorf("Expected an error for the filter %v", invalid)
+		}
+	}
+}
+
+func TestFilterDatetime(t *testing.T) {
+	collection := filterCollection(t)
+	january := []interface{}{"2024-01-01T00:00:00Z", "2024-01-31T23:59:59Z"}
+	runFilterCases(t, collection, []filterCase{
+		// The string "not a date" of d is not compared to datetimes
+		{"gt", []Filter.Filter{{Field: "created", Op: Filter.GreaterThan, Value: "2024-02-01T00:00:00Z"}}, []string{"b", "c"}},
+		{"le", []Filter.Filter{{Field: "created", Op: Filter.LessThanOrEqual, Value: "2024-03-01T12:00:00Z"}}, []string{"a", "b"}},
+		{"other time zone", []Filter.Filter{{Field: "created", Op: Filter.Equal, Value: "2024-03-01T13:00:00+01:00"}}, []string{"b"}},
+		{"between", []Filter.Filter{{Field: "created", Op: Filter.Between, Value: january}}, []string{"a"}},
+		{"now", []Filter.Filter{{Field: "created", Op: Filter.LessThan, Value: "now"}}, []string{"a", "b", "c"}},
+		{"relative", []Filter.Filter{{Field: "created", Op: Filter.GreaterThanOrEqual, Value: "now-1d"}}, []string{"c"}},
+		{"compound relative", []Filter.Filter{{Field: "created", Op: Filter.GreaterThan, Value: "now-1d12h"}}, []string{"c"}},
+		{"relative minutes", []Filter.Filter{{Field: "created", Op: Filter.GreaterThan, Value: "now-30m"}}, []string{}},
+		{"relative between", []Filter.Filter{{Field: "created", Op: Filter.Between, Value: []interface{}{"2024-02-01T00:00:00Z", "now+1w"}}}, []string{"b", "c"}},
+		// A stored string is a datetime only if it is RFC3339
+		{"stored relative", []Filter.Filter{{Field: "stored", Op: Filter.LessThan, Value: "now"}}, []string{}},
+		{"stored relative string", []Filter.Filter{{Field: "stored", Op: Filter.Equal, Value: "now-1d"}}, []string{}},
+		{"string", []Filter.Filter{{Field: "created", Op: Filter.Equal, Value: "not a date"}}, []string{"d"}},
+	})
+
+	// Relative times of filter values
+	for value, offset := range map[string]time.Duration{
+		"now":         0,
+		"now-7d":      -7 * 24 * time.Hour,
+		"now+1w":      7 * 24 * time.Hour,
+		"now-1d12h":   -36 * time.Hour,
+		"now+1.5d30m": 36*time.Hour + 30*time.Minute,
+	} {
+		at, ok := Filter.OperandTime(value)
+		if !ok {
+			t.Errorf("Expected %s to be a datetime", value)
+		} else if d := time.Until(at) - offset; d > time.Minute || d < -time.Minute {
+			t.Errorf("Expected %s to be %s from now, got %s", value, offset, time.Until(at))
+		}
+	}
+	for _, value := range []string{"now-", "now7d", "now-7", "now-7x", "now-d", "2024-01-01", "yesterday"} {
+		if _, ok := Filter.OperandTime(value); ok {
+			t.Errorf("Expected %s not to be a datetime", value)
+		}
+	}
+}
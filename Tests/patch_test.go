@@ -0,0 +1,65 @@
+// patch_test.go
+package Collection
+
+import (
+	"VreeDB/Collection"
+	"VreeDB/Wal"
+	"slices"
+	"testing"
+)
+
+func TestPatchPayloadUpdatesIndexes(t *testing.T) {
+	collection := colorCollection(t)
+	patched, err := collection.PatchPayload([]string{"a", "c"}, nil, &Collection.PayloadPatch{
+		Set:       map[string]interface{}{"color": "yellow"},
+		Increment: map[string]float64{"size": 5},
+	})
+	if err != nil {
+		t.Fatalf("Patching failed: %s", err)
+	}
+	if !slices.Equal(patched, []string{"a", "c"}) {
+		t.Errorf("Expected the patched ids [a c], got %v", patched)
+	}
+
+	// The next index search finds the patched values
+	if ids := searchIndex(t, collection, "color", "yellow", []float64{1, 1}); !slices.Equal(ids, []string{"a", "c"}) {
+		t.Errorf("Expected [a c] for yellow, got %v", ids)
+	}
+	if ids := searchIndex(t, collection, "color", "red", []float64{1, 1}); !slices.Equal(ids, []string{"b"}) {
+		t.Errorf("Expected [b] for red, got %v", ids)
+	}
+	if ids := searchIndex(t, collection, "color", "green", []float64{1, 1}); len(ids) != 0 {
+		t.Errorf("Expected no vector for the old color green, got %v", ids)
+	}
+	if ids := searchIndex(t, collection, "size", 8.0, []float64{1, 1}); !slices.Equal(ids, []string{"c"}) {
+		t.Errorf("Expected [c] for size 8, got %v", ids)
+	}
+	if ids := searchIndex(t, collection, "size", 3.0, []float64{1, 1}); len(ids) != 0 {
+		t.Errorf("Expected no vector for the old size 3, got %v", ids)
+	}
+}
+
+func TestPatchPayloadLogsPayloads(t *testing.T) {
+	collection := colorCollection(t)
+	_, err := collection.PatchPayload([]string{"b"}, nil, &Collection.PayloadPatch{Unset: []string{"size"}})
+	if err != nil {
+		t.Fatalf("Patching failed: %s", err)
+	}
+
+	// The data stays in place - only the payload is logged
+	var records []Wal.Record
+	err = collection.Wal.Replay(func(record *Wal.Record) error {
+		records = append(records, *record)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Reading the wal failed: %s", err)
+	}
+	last := records[len(records)-1]
+	if last.Op != Wal.Payload || last.Data != nil || !slices.Equal(last.IDs, []string{"b"}) {
+		t.Errorf("Expected a payload record of b without data, got %v", last)
+	}
+	if _, ok := last.Payload["size"]; ok || last.Payload["color"] != "red" {
+		t.Errorf("Expected the patched payload in the record, got %v", last.Payload)
+	}
+}
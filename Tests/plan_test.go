@@ -0,0 +1,173 @@
+// plan_test.go
+package Collection
+
+import (
+	"VreeDB/Collection"
+	"VreeDB/Filter"
+	"VreeDB/Node"
+	"VreeDB/Utils"
+	"VreeDB/Vdb"
+	"VreeDB/Vector"
+	"math/rand"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// planCollection returns a collection with the vectors 0 to n-1 at (i, i) and the payload n = i
+func planCollection(t *testing.T, n int) *Collection.Collection {
+	t.Helper()
+	useTempStore(t)
+	collection := newCollection(t, "plan", 2, "euclid", Vector.Float64)
+	for i := 0; i < n; i++ {
+		insert(t, collection, strconv.Itoa(i), []float64{float64(i), float64(i)}, map[string]interface{}{"n": float64(i)})
+	}
+	return collection
+}
+
+// resultIds returns the sorted ids of the result of a search
+func resultIds(items []*Utils.HeapItem) []string {
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.Node.Vector.Id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func TestPlanSearch(t *testing.T) {
+	collection := planCollection(t, 300)
+	collection.Mut.RLock()
+	defer collection.Mut.RUnlock()
+
+	selective := []Filter.Filter{{Field: "n", Op: Filter.LessThan, Value: 3}}
+	if plan := collection.PlanSearch(&selective); plan.Strategy != Collection.BruteForce {
+		t.Errorf("Expected a selective filter to be searched by brute force, got %s", plan.Strategy)
+	}
+	broad := []Filter.Filter{{Field: "n", Op: Filter.GreaterThanOrEqual, Value: 0}}
+	if plan := collection.PlanSearch(&broad); plan.Strategy != Collection.Traverse {
+		t.Errorf("Expected a broad filter to be searched by a traversal, got %s", plan.Strategy)
+	}
+	if plan := collection.PlanSearch(nil); plan.Strategy != Collection.Traverse {
+		t.Errorf("Expected a search without a filter to be searched by a traversal, got %s", plan.Strategy)
+	}
+}
+
+func TestPlanSearchSmallCollection(t *testing.T) {
+	collection := planCollection(t, 20)
+	collection.Mut.RLock()
+	defer collection.Mut.RUnlock()
+
+	// Small collections are always searched by brute force
+	filters := []Filter.Filter{{Field: "n", Op: Filter.GreaterThanOrEqual, Value: 10}}
+	plan := collection.PlanSearch(&filters)
+	if plan.Strategy != Collection.BruteForce {
+		t.Fatalf("Expected a small collection to be searched by brute force, got %s", plan.Strategy)
+	}
+	target := Vector.NewVector("target", []float64{0, 0}, nil, collection.Name)
+	items, exact := plan.Search(collection, target, 3, func(*[]Filter.Filter) []*Utils.HeapItem {
+		t.Fatalf("Expected a brute force plan not to traverse")
+		return nil
+	})
+	if !exact {
+		t.Errorf("Expected a brute force search to compare in full precision")
+	}
+	if ids := resultIds(items); !slices.Equal(ids, []string{"10", "11", "12"}) {
+		t.Errorf("Expected [10 11 12], got %v", ids)
+	}
+}
+
+func TestPlanSearchIndexed(t *testing.T) {
+	collection := planCollection(t, 20)
+	err := collection.CreateIndex("n", "n", Collection.RangeIndex)
+	if err != nil {
+		t.Fatalf("Creating index failed: %s", err)
+	}
+	collection.Mut.RLock()
+	defer collection.Mut.RUnlock()
+
+	// The candidates are pre-selected by the index
+	filters := []Filter.Filter{{Field: "n", Op: Filter.Between, Value: []interface{}{5.0, 7.0}}}
+	plan := collection.PlanSearch(&filters)
+	if plan.Strategy != Collection.BruteForce || len(plan.Candidates) != 3 {
+		t.Fatalf("Expected a brute force search over 3 candidates, got %s over %d", plan.Strategy, len(plan.Candidates))
+	}
+	target := Vector.NewVector("target", []float64{19, 19}, nil, collection.Name)
+	items, _ := plan.Search(collection, target, 2, nil)
+	if ids := resultIds(items); !slices.Equal(ids, []string{"6", "7"}) {
+		t.Errorf("Expected [6 7], got %v", ids)
+	}
+}
+
+func TestPlanSearchFallback(t *testing.T) {
+	collection := planCollection(t, 20)
+	collection.Mut.RLock()
+	defer collection.Mut.RUnlock()
+
+	filters := []Filter.Filter{{Field: "n", Op: Filter.LessThan, Value: 5}}
+	plan := &Collection.SearchPlan{Strategy: Collection.Traverse, Filter: &filters, Selectivity: 1}
+	target := Vector.NewVector("target", []float64{0, 0}, nil, collection.Name)
+	traversed := func(ids ...string) func(*[]Filter.Filter) []*Utils.HeapItem {
+		return func(*[]Filter.Filter) []*Utils.HeapItem {
+			items := make([]*Utils.HeapItem, 0, len(ids))
+			for _, id := range ids {
+				items = append(items, &Utils.HeapItem{Node: &Node.Node{Vector: (*collection.Space)[id]}})
+			}
+			return items
+		}
+	}
+
+	// A traversal that found less than k vectors is repeated by brute force
+	items, exact := plan.Search(collection, target, 3, traversed("0"))
+	if !exact {
+		t.Errorf("Expected the fallback to compare in full precision")
+	}
+	if ids := resultIds(items); !slices.Equal(ids, []string{"0", "1", "2"}) {
+		t.Errorf("Expected [0 1 2] after the fallback, got %v", ids)
+	}
+
+	// The result of a traversal that found k vectors is kept
+	items, exact = plan.Search(collection, target, 2, traversed("3", "4"))
+	if exact {
+		t.Errorf("Expected the result of the traversal")
+	}
+	if ids := resultIds(items); !slices.Equal(ids, []string{"3", "4"}) {
+		t.Errorf("Expected the traversed [3 4], got %v", ids)
+	}
+
+	// Without a filter there is nothing the traversal could have skipped
+	plan = &Collection.SearchPlan{Strategy: Collection.Traverse, Selectivity: 1}
+	if items, _ = plan.Search(collection, target, 3, traversed("0")); len(items) != 1 {
+		t.Errorf("Expected the result of the traversal without a filter, got %d vectors", len(items))
+	}
+}
+
+func TestRecallTraversesIndex(t *testing.T) {
+	// Small collections are searched by brute force by the planner - the recall must measure the traversal anyway
+	useTempStore(t)
+	collection := newCollection(t, "recall", 8, "euclid", Vector.Float64)
+	useDB(t, collection)
+	r := rand.New(rand.NewSource(11))
+	for i := 0; i < 200; i++ {
+		insert(t, collection, strconv.Itoa(i), randomData(r, 8), map[string]interface{}{"n": float64(i)})
+	}
+	all := []Filter.Filter{{Field: "n", Op: Filter.GreaterThanOrEqual, Value: 0}}
+	collection.Mut.RLock()
+	strategy := collection.PlanSearch(&all).Strategy
+	collection.Mut.RUnlock()
+	if strategy != Collection.BruteForce {
+		t.Fatalf("Expected the planner to search the collection by brute force, got %s", strategy)
+	}
+
+	// A traversal with few distance calculations misses neighbours
+	params := Utils.SearchParams{MaxChecks: 20}
+	for _, filter := range []*[]Filter.Filter{nil, &all} {
+		report, err := Vdb.DB.Recall(collection.Name, 10, 20, filter, false, params)
+		if err != nil {
+			t.Fatalf("Measuring the recall failed: %s", err)
+		}
+		if report.Recall <= 0 || report.Recall >= 1 {
+			t.Errorf("Expected a recall between 0 and 1 with %d checks, got %.2f", params.MaxChecks, report.Recall)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// points_test.go
+package Collection
+
+import (
+	"VreeDB/Collection"
+	"VreeDB/Filter"
+	"VreeDB/Vector"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// pointsCollection returns a collection with the vectors p00 to p24 - the score of a vector is its number modulo 7,
+// every fifth vector has no score and vector p03 has a text score
+func pointsCollection(t *testing.T) *Collection.Collection {
+	t.Helper()
+	useTempStore(t)
+	collection := newCollection(t, "points", 2, "euclid", Vector.Float64)
+	for i := 0; i < 25; i++ {
+		payload := map[string]interface{}{"even": i%2 == 0}
+		if i == 3 {
+			payload["score"] = "high"
+		} else if i%5 != 0 {
+			payload["score"] = float64(i % 7)
+		}
+		insert(t, collection, fmt.Sprintf("p%02d", i), []float64{float64(i), 0}, payload)
+	}
+	return collection
+}
+
+// scrollAll scrolls through all pages and returns the ids of the points
+func scrollAll(t *testing.T, collection *Collection.Collection, limit int, filter *[]Filter.Filter, orderBy *Collection.OrderBy) []string {
+	t.Helper()
+	ids := []string{}
+	offset := ""
+	for pages := 0; ; pages++ {
+		if pages > 100 {
+			t.Fatalf("Expected the scroll to end")
+		}
+		points, next, err := collection.Scroll(offset, limit, filter, orderBy, false)
+		if err != nil {
+			t.Fatalf("Scrolling failed: %s", err)
+		}
+		if len(points) > limit || (next != "" && len(points) != limit) {
+			t.Fatalf("Expected full pages of %d points before the last page, got %d", limit, len(points))
+		}
+		for _, point := range points {
+			ids = append(ids, point.Id)
+		}
+		if next == "" {
+			return ids
+		}
+		offset = next
+	}
+}
+
+func TestGetPoints(t *testing.T) {
+	collection := pointsCollection(t)
+	err := collection.DeleteVectorByID([]string{"p07"})
+	if err != nil {
+		t.Fatalf("Deleting failed: %s", err)
+	}
+	points, err := collection.GetPoints([]string{"p12", "missing", "p07", "p01"}, true)
+	if err != nil {
+		t.Fatalf("Getting points failed: %s", err)
+	}
+	if len(points) != 2 || points[0].Id != "p12" || points[1].Id != "p01" {
+		t.Fatalf("Expected the points p12 and p01, got %v", points)
+	}
+	if !slices.Equal(*points[0].Vector, []float64{12, 0}) || (*points[0].Payload)["score"] != 5.0 {
+		t.Errorf("Expected the vector and the payload of p12, got %v %v", *points[0].Vector, *points[0].Payload)
+	}
+	points, err = collection.GetPoints([]string{"p12"}, false)
+	if err != nil || len(points) != 1 || points[0].Vector != nil {
+		t.Errorf("Expected p12 without its vector, got %v %v", points, err)
+	}
+}
+
+func TestScroll(t *testing.T) {
+	collection := pointsCollection(t)
+	all := make([]string, 25)
+	for i := range all {
+		all[i] = fmt.Sprintf("p%02d", i)
+	}
+	for _, limit := range []int{1, 7, 25, 40} {
+		if ids := scrollAll(t, collection, limit, nil, nil); !slices.Equal(ids, all) {
+			t.Errorf("Expected all points in id order with a limit of %d, got %v", limit, ids)
+		}
+	}
+
+	even := []Filter.Filter{{Field: "even", Op: Filter.Equal, Value: true}}
+	ids := scrollAll(t, collection, 4, &even, nil)
+	if len(ids) != 13 || ids[0] != "p00" || ids[12] != "p24" {
+		t.Errorf("Expected the 13 even points, got %v", ids)
+	}
+
+	// The offset of the next page is its first id
+	points, next, err := collection.Scroll("p20", 3, nil, nil, false)
+	if err != nil || len(points) != 3 || points[0].Id != "p20" || next != "p23" {
+		t.Errorf("Expected the page p20 to p22 and the next offset p23, got %d points and %q", len(points), next)
+	}
+	if _, _, err := collection.Scroll("", 0, nil, nil, false); err == nil {
+		t.Errorf("Expected an error for a limit of 0")
+	}
+}
+
+func TestScrollOrdered(t *testing.T) {
+	collection := pointsCollection(t)
+
+	// Numbers are sorted before strings, ties by id - points without a score are left out
+	var expected []string
+	for score := 0; score < 7; score++ {
+		for i := 0; i < 25; i++ {
+			if i%7 == score && i%5 != 0 && i != 3 {
+				expected = append(expected, fmt.Sprintf("p%02d", i))
+			}
+		}
+	}
+	expected = append(expected, "p03")
+	for _, limit := range []int{1, 3, 6, 100} {
+		ids := scrollAll(t, collection, limit, nil, &Collection.OrderBy{Key: "score"})
+		if !slices.Equal(ids, expected) {
+			t.Errorf("Expected the points by score with a limit of %d, got %v", limit, ids)
+		}
+	}
+
+	descending := scrollAll(t, collection, 4, nil, &Collection.OrderBy{Key: "score", Descending: true})
+	// Ties stay in id order
+	if !slices.Equal(descending[:3], []string{"p03", "p06", "p13"}) || descending[len(descending)-1] != "p21" {
+		t.Errorf("Expected the points by descending score, got %v", descending)
+	}
+
+	// A point inserted behind the cursor is found on a later page
+	points, next, err := collection.Scroll("", 5, nil, &Collection.OrderBy{Key: "score"}, false)
+	if err != nil || len(points) != 5 || next == "" {
+		t.Fatalf("Expected a page of 5 points, got %d and %v", len(points), err)
+	}
+	insert(t, collection, "late", []float64{0, 1}, map[string]interface{}{"score": 6.0})
+	ids := []string{}
+	for next != "" {
+		points, next, err = collection.Scroll(next, 5, nil, &Collection.OrderBy{Key: "score"}, false)
+		if err != nil {
+			t.Fatalf("Scrolling failed: %s", err)
+		}
+		for _, point := range points {
+			ids = append(ids, point.Id)
+		}
+	}
+	if !slices.Contains(ids, "late") || slices.Contains(ids, expected[0]) {
+		t.Errorf("Expected the later pages to hold the new point and not the first page, got %v", ids)
+	}
+
+	if _, _, err := collection.Scroll("p05", 5, nil, &Collection.OrderBy{Key: "score"}, false); err == nil {
+		t.Errorf("Expected an error for an id as the offset of an ordered scroll")
+	}
+}
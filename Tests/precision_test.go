@@ -0,0 +1,99 @@
+// precision_test.go
+package Collection
+
+import (
+	"VreeDB/Utils"
+	"VreeDB/Vdb"
+	"VreeDB/Vector"
+	"math"
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPrecisionSearch(t *testing.T) {
+	for _, precision := range []Vector.Precision{Vector.Float32, Vector.Int8} {
+		t.Run(precision.String(), func(t *testing.T) {
+			useTempStore(t)
+			collection := newCollection(t, "precision", 8, "euclid", precision)
+			useDB(t, collection)
+			r := rand.New(rand.NewSource(3))
+			for i := 0; i < 300; i++ {
+				insert(t, collection, strconv.Itoa(i), randomData(r, 8), nil)
+			}
+
+			// The vectors are kept in the precision of the collection
+			vector := (*collection.Space)["0"]
+			if precision == Vector.Float32 && (vector.Data32 == nil || vector.Data != nil) {
+				t.Errorf("Expected the data as float32 only")
+			} else if precision == Vector.Int8 && (vector.Quant == nil || vector.Data != nil) {
+				t.Errorf("Expected the data as int8 codes only")
+			}
+
+			// The rescored search returns the exact neighbours with their full precision distances
+			novector, getid := false, true
+			for i := 0; i < 10; i++ {
+				data := randomData(r, 8)
+				exact := exactSearch(collection, data, 5)
+				results := Vdb.DB.Search(collection.Name, Vector.NewVector("", data, nil, ""), Utils.NewHeapControl(5), 0, nil, true,
+					Utils.SearchParams{}, &novector, &getid)
+				for _, result := range results {
+					if !exact[result.Id] {
+						t.Errorf("Expected only exact neighbours, got vector %s", result.Id)
+					}
+					full := *(*collection.Space)[result.Id].GetData()
+					distance := 0.0
+					for j := range full {
+						distance += (full[j] - data[j]) * (full[j] - data[j])
+					}
+					if math.Abs(result.Distance-math.Sqrt(distance)) > 1e-6 {
+						t.Errorf("Expected the full precision distance %f of vector %s, got %f", math.Sqrt(distance), result.Id, result.Distance)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestQuantizerRetrain(t *testing.T) {
+	useTempStore(t)
+	collection := newCollection(t, "quantized", 2, "euclid", Vector.Int8)
+	for i := 0; i <= 10; i++ {
+		insert(t, collection, strconv.Itoa(i), []float64{float64(i) / 10, 1 - float64(i)/10}, nil)
+	}
+	if !collection.Quantizer.Covers([]float64{0, 1}) || collection.Quantizer.Covers([]float64{8, -8}) {
+		t.Fatalf("Expected the quantizer to cover the range of the vectors, got %v to %v", collection.Quantizer.Min, collection.Quantizer.Max)
+	}
+
+	// A vector outside of the range retrains the quantizer in the background
+	insert(t, collection, "far", []float64{8, -8}, nil)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		collection.Mut.RLock()
+		covered := collection.Quantizer.Covers([]float64{8, -8})
+		far := (*collection.Space)["far"]
+		x, y := far.Get(0), far.Get(1)
+		collection.Mut.RUnlock()
+		if covered {
+			// The codes of all vectors are in the new range
+			if math.Abs(x-8) > 0.1 || math.Abs(y+8) > 0.1 {
+				t.Errorf("Expected the vector (8, -8) after the retrain, got (%f, %f)", x, y)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the quantizer to be retrained")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if x := (*collection.Space)["3"].Get(0); math.Abs(x-0.3) > 0.1 {
+		t.Errorf("Expected the value 0.3 of vector 3 after the retrain, got %f", x)
+	}
+
+	// The new range is saved
+	booted := reboot(t, collection)["quantized"]
+	if !booted.Quantizer.Covers([]float64{8, -8}) {
+		t.Errorf("Expected the retrained range after a restart, got %v to %v", booted.Quantizer.Min, booted.Quantizer.Max)
+	}
+}
@@ -0,0 +1,130 @@
+// rangeindex_test.go
+package Collection
+
+import (
+	"VreeDB/Collection"
+	"VreeDB/Filter"
+	"VreeDB/Vector"
+	"fmt"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// rangeCollection returns a collection of 60 orders with range indexes on price, day and sku. Every seventh order
+// has its price as a text.
+func rangeCollection(t *testing.T) *Collection.Collection {
+	t.Helper()
+	useTempStore(t)
+	collection := newCollection(t, "orders", 2, "euclid", Vector.Float64)
+	for i := 0; i < 60; i++ {
+		payload := map[string]interface{}{
+			"price": float64(i) / 2,
+			"day":   fmt.Sprintf("2024-03-%02dT12:00:00Z", 1+i%30),
+			"sku":   fmt.Sprintf("%c-%d", 'A'+rune(i%3), i),
+		}
+		if i%7 == 0 {
+			payload["price"] = strconv.Itoa(i)
+		}
+		insert(t, collection, strconv.Itoa(i), []float64{float64(i), 0}, payload)
+	}
+	for _, key := range []string{"price", "day", "sku"} {
+		err := collection.CreateIndex(key, key, Collection.RangeIndex)
+		if err != nil {
+			t.Fatalf("Creating the index of %s failed: %s", key, err)
+		}
+	}
+	return collection
+}
+
+// checkPreselect checks that the candidates pre-selected for the filter are the vectors that pass it
+func checkPreselect(t *testing.T, collection *Collection.Collection, filter []Filter.Filter) {
+	t.Helper()
+	collection.Mut.RLock()
+	defer collection.Mut.RUnlock()
+	plan := collection.PlanSearch(&filter)
+	if plan.Candidates == nil {
+		t.Errorf("Expected candidates for %v", filter)
+		return
+	}
+	candidates := []string{}
+	for _, vector := range plan.Candidates {
+		if Filter.Validate(&filter, vector) {
+			candidates = append(candidates, vector.Id)
+		}
+	}
+	passing := []string{}
+	for id, vector := range *collection.Space {
+		if !vector.IsDeleted() && Filter.Validate(&filter, vector) {
+			passing = append(passing, id)
+		}
+	}
+	slices.Sort(candidates)
+	slices.Sort(passing)
+	if len(passing) == 0 || !slices.Equal(candidates, passing) {
+		t.Errorf("Expected the candidates of %v to hold %v, got %v", filter, passing, candidates)
+	}
+}
+
+func TestRangePreselect(t *testing.T) {
+	collection := rangeCollection(t)
+	filters := [][]Filter.Filter{
+		{{Field: "price", Op: Filter.Equal, Value: 4.5}},
+		{{Field: "price", Op: Filter.GreaterThan, Value: 24.5}},
+		{{Field: "price", Op: Filter.GreaterThanOrEqual, Value: 25.0}},
+		{{Field: "price", Op: Filter.LessThan, Value: 3.0}},
+		{{Field: "price", Op: Filter.LessThanOrEqual, Value: 3.0}},
+		{{Field: "price", Op: Filter.Between, Value: []interface{}{10.0, 12.5}}},
+		{{Field: "price", Op: Filter.Equal, Value: "14"}},
+		{{Field: "day", Op: Filter.Between, Value: []interface{}{"2024-03-02T00:00:00Z", "2024-03-05T00:00:00Z"}}},
+		{{Field: "day", Op: Filter.GreaterThan, Value: "2024-03-28T12:00:00Z"}},
+		{{Field: "sku", Op: Filter.Prefix, Value: "B-1"}},
+		// Conditions on many indexed keys are intersected
+		{{Field: "price", Op: Filter.LessThan, Value: 20.0}, {Field: "sku", Op: Filter.Prefix, Value: "C-"}},
+	}
+	for _, filter := range filters {
+		checkPreselect(t, collection, filter)
+	}
+
+	// Operators without a range and selections larger than half of the collection are not pre-selected
+	collection.Mut.RLock()
+	defer collection.Mut.RUnlock()
+	for _, filter := range [][]Filter.Filter{
+		{{Field: "price", Op: Filter.NotEqual, Value: 4.5}},
+		{{Field: "price", Op: Filter.GreaterThan, Value: 1.0}},
+		{{Field: "price", Op: Filter.Between, Value: []interface{}{1.0, "9"}}},
+		{{Field: "day", Op: Filter.Prefix, Value: "2024-03"}},
+	} {
+		if plan := collection.PlanSearch(&filter); plan.Candidates != nil {
+			t.Errorf("Expected no candidates for %v, got %d", filter, len(plan.Candidates))
+		}
+	}
+}
+
+func TestRangePreselectChanges(t *testing.T) {
+	collection := rangeCollection(t)
+	cheap := []Filter.Filter{{Field: "price", Op: Filter.LessThan, Value: 5.0}}
+
+	// Deleted vectors, replaced vectors and new vectors
+	err := collection.DeleteVectorByID([]string{"1", "2"})
+	if err != nil {
+		t.Fatalf("Deleting failed: %s", err)
+	}
+	err = collection.UpdatePayload("3", &map[string]interface{}{"price": 99.0})
+	if err != nil {
+		t.Fatalf("Updating the payload failed: %s", err)
+	}
+	insert(t, collection, "new", []float64{0, 1}, map[string]interface{}{"price": 0.25})
+	checkPreselect(t, collection, cheap)
+
+	collection.Mut.RLock()
+	ids := []string{}
+	for _, vector := range collection.PlanSearch(&cheap).Candidates {
+		ids = append(ids, vector.Id)
+	}
+	collection.Mut.RUnlock()
+	slices.Sort(ids)
+	if !slices.Equal(ids, []string{"4", "5", "6", "8", "9", "new"}) {
+		t.Errorf("Expected the candidates [4 5 6 8 9 new], got %v", ids)
+	}
+}
@@ -0,0 +1,90 @@
+// routes_test.go
+package Collection
+
+import (
+	"VreeDB/ApiKeyHandler"
+	"VreeDB/Collection"
+	"VreeDB/Server"
+	"VreeDB/Vdb"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// post sends the body as json to the route and returns the response - without api keys every request is authorized
+func post(t *testing.T, route http.HandlerFunc, path string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("Encoding the request failed: %s", err)
+	}
+	response := httptest.NewRecorder()
+	route(response, httptest.NewRequest(http.MethodPost, path, bytes.NewReader(data)))
+	return response
+}
+
+func TestIndexRoutes(t *testing.T) {
+	if len(ApiKeyHandler.ApiHandler.ApiKeyHashes) != 0 {
+		t.Skip("The routes need a store without api keys")
+	}
+	collection := colorCollection(t)
+	routes := &Server.Routes{DB: Vdb.DB, ApiKeyHandler: ApiKeyHandler.ApiHandler, SessionKeys: make(map[string]time.Time)}
+	request := map[string]any{"collection_name": collection.Name}
+
+	// List
+	response := post(t, routes.ListIndexes, "/listindexes", request)
+	var indexes []Collection.IndexInfo
+	if response.Code != http.StatusOK || json.Unmarshal(response.Body.Bytes(), &indexes) != nil {
+		t.Fatalf("Expected the indexes, got %d %s", response.Code, response.Body)
+	}
+	if len(indexes) != 2 || indexes[0] != (Collection.IndexInfo{Name: "color", Key: "color", Type: Collection.ValueIndex}) {
+		t.Errorf("Expected the indexes color and size, got %v", indexes)
+	}
+
+	// Stats of an index with stale entries and after its rebuild
+	request["index_name"] = "color"
+	err := collection.DeleteVectorByID([]string{"c"})
+	if err != nil {
+		t.Fatalf("Deleting failed: %s", err)
+	}
+	response = post(t, routes.IndexStats, "/indexstats", request)
+	var stats Collection.IndexStats
+	if response.Code != http.StatusOK || json.Unmarshal(response.Body.Bytes(), &stats) != nil {
+		t.Fatalf("Expected the index stats, got %d %s", response.Code, response.Body)
+	}
+	if stats.Cardinality != 1 || stats.Vectors != 2 || stats.Counts["red"] != 2 || stats.Memory <= 0 {
+		t.Errorf("Expected 2 red vectors, got %+v", stats)
+	}
+	request["wait"] = true
+	response = post(t, routes.RebuildIndex, "/rebuildindex", request)
+	if response.Code != http.StatusOK || response.Body.String() != "Index rebuilt" {
+		t.Errorf("Expected the index to be rebuilt, got %d %s", response.Code, response.Body)
+	}
+	if _, ok := collection.Indexes["color"].Entries["green"]; ok {
+		t.Errorf("Expected the rebuilt index to have no entry of the deleted vector")
+	}
+
+	// Drop
+	response = post(t, routes.DropIndex, "/dropindex", request)
+	if response.Code != http.StatusOK || collection.HasIndex("color") {
+		t.Errorf("Expected the index to be dropped, got %d %s", response.Code, response.Body)
+	}
+	for path, route := range map[string]http.HandlerFunc{"/dropindex": routes.DropIndex, "/rebuildindex": routes.RebuildIndex, "/indexstats": routes.IndexStats} {
+		if response := post(t, route, path, request); response.Code != http.StatusBadRequest || !strings.Contains(response.Body.String(), "does not exist") {
+			t.Errorf("Expected %s of a dropped index to fail, got %d %s", path, response.Code, response.Body)
+		}
+	}
+	if response := post(t, routes.ListIndexes, "/listindexes", map[string]any{"collection_name": "missing"}); response.Code != http.StatusBadRequest {
+		t.Errorf("Expected an error for a missing collection, got %d", response.Code)
+	}
+
+	// The dropped index stays dropped
+	booted := reboot(t, collection)["colors"]
+	if indexes := booted.ListIndexes(); len(indexes) != 1 || indexes[0].Name != "size" {
+		t.Errorf("Expected only the index size after a restart, got %v", indexes)
+	}
+}
@@ -0,0 +1,145 @@
+// schema_test.go
+package Collection
+
+import (
+	"VreeDB/Collection"
+	"VreeDB/Utils"
+	"VreeDB/Vector"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// articleSchema is the schema of the tests
+var articleSchema = []Utils.SchemaField{
+	{Name: "title", Type: Collection.FieldString, Required: true},
+	{Name: "views", Type: Collection.FieldNumber, Indexed: true},
+	{Name: "published", Type: Collection.FieldDatetime},
+	{Name: "meta.lang", Type: Collection.FieldString, Indexed: true},
+	{Name: "place", Type: Collection.FieldGeo},
+	{Name: "draft", Type: Collection.FieldBool},
+}
+
+func TestValidateSchema(t *testing.T) {
+	invalid := map[string][]Utils.SchemaField{
+		"no name":        {{Type: Collection.FieldString}},
+		"twice":          {{Name: "a", Type: Collection.FieldString}, {Name: "a", Type: Collection.FieldNumber}},
+		"unknown type":   {{Name: "a", Type: "text"}},
+		"indexed bool":   {{Name: "a", Type: Collection.FieldBool, Indexed: true}},
+		"indexed object": {{Name: "a", Type: Collection.FieldObject, Indexed: true}},
+	}
+	for name, schema := range invalid {
+		if err := Collection.ValidateSchema(schema); err == nil {
+			t.Errorf("Expected an error for the schema with %s", name)
+		}
+	}
+	if err := Collection.ValidateSchema(articleSchema); err != nil {
+		t.Errorf("Expected the schema to be valid, got %s", err)
+	}
+}
+
+func TestSchemaChecksPayloads(t *testing.T) {
+	useTempStore(t)
+	collection := newCollection(t, "articles", 2, "euclid", Vector.Float64)
+	err := collection.SetSchema(articleSchema)
+	if err != nil {
+		t.Fatalf("Setting the schema failed: %s", err)
+	}
+
+	valid := []map[string]interface{}{
+		{"title": "a"},
+		{"title": "b", "views": 3.0, "published": "2024-05-01T10:00:00Z", "meta": map[string]interface{}{"lang": "en"}},
+		{"title": "c", "place": map[string]interface{}{"lat": 52.5, "lon": 13.4}, "draft": false, "extra": []interface{}{1.0}},
+		{"title": "d", "views": nil},
+	}
+	for i, payload := range valid {
+		err := collection.Insert(Vector.NewVector(string(rune('a'+i)), []float64{float64(i), 0}, &payload, collection.Name))
+		if err != nil {
+			t.Errorf("Expected payload %v to be valid, got %s", payload, err)
+		}
+	}
+	invalid := []map[string]interface{}{
+		{"views": 1.0},
+		{"title": nil},
+		{"title": 7.0},
+		{"title": "x", "views": "many"},
+		{"title": "x", "published": "yesterday"},
+		{"title": "x", "meta": map[string]interface{}{"lang": 1.0}},
+		{"title": "x", "place": map[string]interface{}{"lat": "north"}},
+		{"title": "x", "draft": "no"},
+	}
+	for i, payload := range invalid {
+		err := collection.Insert(Vector.NewVector(string(rune('p'+i)), []float64{float64(i), 1}, &payload, collection.Name))
+		if err == nil {
+			t.Errorf("Expected payload %v to be invalid", payload)
+		}
+	}
+
+	// A batch with invalid points is rejected as a whole
+	vectors := []*Vector.Vector{
+		Vector.NewVector("e", []float64{5, 0}, &map[string]interface{}{"title": "e"}, collection.Name),
+		Vector.NewVector("f", []float64{6, 0}, &map[string]interface{}{"views": 2.0}, collection.Name),
+		Vector.NewVector("g", []float64{7, 0}, &map[string]interface{}{"title": false}, collection.Name),
+	}
+	err = collection.InsertBatch(vectors)
+	var batchErr *Collection.BatchError
+	if !errors.As(err, &batchErr) || len(batchErr.Points) != 2 || batchErr.Points[0].Index != 1 || batchErr.Points[1].Id != "g" {
+		t.Errorf("Expected the points 1 and 2 of the batch to be invalid, got %v", err)
+	}
+	if _, ok := (*collection.Space)["e"]; ok {
+		t.Errorf("Expected no vector of the invalid batch to be inserted")
+	}
+
+	// Replaced payloads are checked too
+	if err := collection.UpdatePayload("a", &map[string]interface{}{"views": 1.0}); err == nil {
+		t.Errorf("Expected an error for a payload without a title")
+	}
+	if n := payload(t, collection, "a")["title"]; n != "a" {
+		t.Errorf("Expected the payload of a to be unchanged, got %v", n)
+	}
+}
+
+func TestSetSchema(t *testing.T) {
+	useTempStore(t)
+	collection := newCollection(t, "articles", 2, "euclid", Vector.Float64)
+	insert(t, collection, "a", []float64{0, 0}, map[string]interface{}{"title": "a", "views": 10.0})
+	insert(t, collection, "b", []float64{1, 0}, map[string]interface{}{"views": 20.0})
+	insert(t, collection, "c", []float64{2, 0}, map[string]interface{}{"title": "c", "views": "a lot"})
+
+	// The stored payloads must match
+	err := collection.SetSchema(articleSchema)
+	var batchErr *Collection.BatchError
+	if !errors.As(err, &batchErr) || len(batchErr.Points) != 2 {
+		t.Fatalf("Expected the points b and c not to match the schema, got %v", err)
+	}
+	ids := []string{batchErr.Points[0].Id, batchErr.Points[1].Id}
+	slices.Sort(ids)
+	if !slices.Equal(ids, []string{"b", "c"}) || batchErr.Points[0].Index != -1 {
+		t.Errorf("Expected the stored points b and c, got %v", batchErr.Points)
+	}
+	if len(collection.Schema) != 0 || len(collection.Indexes) != 0 {
+		t.Errorf("Expected the schema not to be set")
+	}
+
+	err = collection.DeleteVectorByID([]string{"b", "c"})
+	if err != nil {
+		t.Fatalf("Deleting failed: %s", err)
+	}
+	err = collection.SetSchema(articleSchema)
+	if err != nil {
+		t.Fatalf("Setting the schema failed: %s", err)
+	}
+	// The indexed fields get an index - numbers are queried by ranges
+	indexes := collection.ListIndexes()
+	if len(indexes) != 2 || indexes[0].Name != "meta.lang" || indexes[1].Type != Collection.RangeIndex {
+		t.Errorf("Expected a value index of meta.lang and a range index of views, got %v", indexes)
+	}
+
+	booted := reboot(t, collection)["articles"]
+	if !slices.Equal(booted.Schema, articleSchema) || len(booted.Indexes) != 2 {
+		t.Errorf("Expected the schema and its indexes after a restart, got %v", booted.Schema)
+	}
+	if err := booted.CheckPayload(&map[string]interface{}{"views": 1.0}); err == nil {
+		t.Errorf("Expected the restored schema to require a title")
+	}
+}
@@ -0,0 +1,117 @@
+// segments_test.go
+package Collection
+
+import (
+	"VreeDB/FileMapper"
+	"bytes"
+	"testing"
+)
+
+// segmentSize is the size of the segments of the stores of the tests
+const segmentSize = 4096
+
+// record returns n bytes with the value b
+func record(n int, b byte) []byte {
+	return bytes.Repeat([]byte{b}, n)
+}
+
+// openStore opens the store of the data file and closes it when the test ends
+func openStore(t *testing.T, path string, metaEnd int64) *FileMapper.SegmentStore {
+	t.Helper()
+	store, err := FileMapper.OpenSegmentStore(path, segmentSize, metaEnd)
+	if err != nil {
+		t.Fatalf("Opening segment store failed: %s", err)
+	}
+	t.Cleanup(func() {
+		store.Close()
+	})
+	return store
+}
+
+// appendRecord appends the record to the store and returns its offset
+func appendRecord(t *testing.T, store *FileMapper.SegmentStore, data []byte) int64 {
+	t.Helper()
+	offsets, err := store.Append([][]byte{data})
+	if err != nil {
+		t.Fatalf("Appending %d bytes failed: %s", len(data), err)
+	}
+	return offsets[0]
+}
+
+// checkRecord checks that the record can be read in full at the offset
+func checkRecord(t *testing.T, store *FileMapper.SegmentStore, offset int64, data []byte) {
+	t.Helper()
+	mapped, err := store.Slice(offset)
+	if err != nil {
+		t.Fatalf("Reading offset %d failed: %s", offset, err)
+	}
+	if len(mapped) < len(data) {
+		t.Fatalf("Expected %d bytes at offset %d, only %d are mapped", len(data), offset, len(mapped))
+	}
+	if !bytes.Equal(mapped[:len(data)], data) {
+		t.Errorf("Expected the record at offset %d to be read back", offset)
+	}
+}
+
+func TestSegmentStoreAppend(t *testing.T) {
+	path := t.TempDir() + "/data.bin"
+	store := openStore(t, path, -1)
+
+	// Records that do not fit into the rest of a segment start a new one
+	a := appendRecord(t, store, record(3000, 'a'))
+	b := appendRecord(t, store, record(3000, 'b'))
+	if a != 0 || b != segmentSize {
+		t.Errorf("Expected the offsets 0 and %d, got %d and %d", segmentSize, a, b)
+	}
+	// Records larger than a segment get a segment of their own
+	c := appendRecord(t, store, record(6000, 'c'))
+	if c != 2*segmentSize {
+		t.Errorf("Expected the offset %d, got %d", 2*segmentSize, c)
+	}
+	checkRecord(t, store, a, record(3000, 'a'))
+	checkRecord(t, store, b, record(3000, 'b'))
+	checkRecord(t, store, c, record(6000, 'c'))
+	if end := store.End(); end != c+6000 {
+		t.Errorf("Expected the end %d, got %d", c+6000, end)
+	}
+}
+
+func TestSegmentStoreReopenAppend(t *testing.T) {
+	path := t.TempDir() + "/data.bin"
+	store := openStore(t, path, -1)
+	appendRecord(t, store, record(3600, 'a'))
+	appendRecord(t, store, record(3000, 'b'))
+	store.Close()
+
+	// The metadata ends in the first segment, e.g. because the last vectors were deleted
+	store = openStore(t, path, 3600)
+	offset := appendRecord(t, store, record(1000, 'c'))
+	if offset != 3600 {
+		t.Errorf("Expected the record at the end 3600 of the metadata, got offset %d", offset)
+	}
+	checkRecord(t, store, 0, record(3600, 'a'))
+	checkRecord(t, store, offset, record(1000, 'c'))
+	store.Close()
+
+	store = openStore(t, path, offset+1000)
+	checkRecord(t, store, 0, record(3600, 'a'))
+	checkRecord(t, store, offset, record(1000, 'c'))
+	next := appendRecord(t, store, record(1000, 'd'))
+	checkRecord(t, store, next, record(1000, 'd'))
+}
+
+func TestSegmentStoreReopenOversizedRecord(t *testing.T) {
+	path := t.TempDir() + "/data.bin"
+	store := openStore(t, path, -1)
+	small := appendRecord(t, store, record(100, 'a'))
+	large := appendRecord(t, store, record(6000, 'b'))
+	checkRecord(t, store, large, record(6000, 'b'))
+	store.Close()
+
+	store = openStore(t, path, large+6000)
+	checkRecord(t, store, small, record(100, 'a'))
+	checkRecord(t, store, large, record(6000, 'b'))
+	next := appendRecord(t, store, record(6000, 'c'))
+	checkRecord(t, store, next, record(6000, 'c'))
+	checkRecord(t, store, large, record(6000, 'b'))
+}
@@ -0,0 +1,113 @@
+// setup_test.go
+//
+// The flags of VreeDB are parsed when the ArgsParser package is initialized, before the testing package knows its
+// own flags. The tests therefore run as a compiled test binary that only gets flags of VreeDB, e.g.
+//
+//	go test -c -o Tests/vreedb.test ./Tests && cd Tests && ./vreedb.test -searchthreads 1
+package Collection
+
+import (
+	"VreeDB/ArgsParser"
+	"VreeDB/Boot"
+	"VreeDB/Collection"
+	"VreeDB/FileMapper"
+	"VreeDB/Vector"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// Small segments keep the data files small, a synced wal is only needed for a crash of the machine
+	*ArgsParser.Ap.SegmentSize = 1
+	*ArgsParser.Ap.WalSync = false
+	os.Exit(m.Run())
+}
+
+// useTempStore runs the test with an empty file store in a temporary directory - the boot reads the collections
+// directory of the working directory, so the test runs in the temporary directory
+func useTempStore(t *testing.T) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	err = os.Mkdir(dir+"/collections", 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Chdir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileStore := *ArgsParser.Ap.FileStore
+	*ArgsParser.Ap.FileStore = dir + "/collections/"
+	t.Cleanup(func() {
+		*ArgsParser.Ap.FileStore = fileStore
+		os.Chdir(wd)
+	})
+}
+
+// newCollection creates a collection in the file store like the create route does
+func newCollection(t *testing.T, name string, dimension int, distanceFunc string, precision Vector.Precision) *Collection.Collection {
+	t.Helper()
+	collection, err := Collection.NewCollection(name, dimension, distanceFunc, precision)
+	if err != nil {
+		t.Fatalf("Creating collection failed: %s", err)
+	}
+	FileMapper.Mapper.AddCollection(name, collection.FormatVersion)
+	err = collection.WriteConfig()
+	if err != nil {
+		t.Fatalf("Writing config failed: %s", err)
+	}
+	t.Cleanup(func() {
+		release(collection)
+	})
+	return collection
+}
+
+// insert inserts a new vector with the payload into the collection
+func insert(t *testing.T, collection *Collection.Collection, id string, data []float64, payload map[string]interface{}) {
+	t.Helper()
+	err := collection.Insert(Vector.NewVector(id, data, &payload, collection.Name))
+	if err != nil {
+		t.Fatalf("Inserting vector %s failed: %s", id, err)
+	}
+}
+
+// payload returns the stored payload of the vector
+func payload(t *testing.T, collection *Collection.Collection, id string) map[string]interface{} {
+	t.Helper()
+	vector, ok := (*collection.Space)[id]
+	if !ok {
+		t.Fatalf("Expected vector with ID %s to be in the collection", id)
+	}
+	p, err := FileMapper.Mapper.ReadPayload(vector.PayloadStart, collection.Name)
+	if err != nil {
+		t.Fatalf("Reading payload of vector %s failed: %s", id, err)
+	}
+	return *p
+}
+
+// release closes the wal and the data file of the collection like the exit of the process does, nothing is synced
+// or written. The FileMapper forgets the store, so the next test opens the data file again.
+func release(collection *Collection.Collection) {
+	collection.Wal.Close()
+	FileMapper.Mapper.Unmap(collection.Name)
+	delete(FileMapper.Mapper.Stores, collection.Name)
+}
+
+// reboot simulates a crash: the collections are released and the file store is booted again
+func reboot(t *testing.T, collections ...*Collection.Collection) map[string]*Collection.Collection {
+	t.Helper()
+	for _, collection := range collections {
+		release(collection)
+	}
+	booted := Boot.NewBootUp().Boot()
+	t.Cleanup(func() {
+		for _, collection := range booted {
+			release(collection)
+		}
+	})
+	return booted
+}
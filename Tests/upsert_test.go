@@ -0,0 +1,169 @@
+// upsert_test.go
+package Collection
+
+import (
+	"VreeDB/Collection"
+	"VreeDB/Utils"
+	"VreeDB/Vdb"
+	"VreeDB/Vector"
+	"slices"
+	"testing"
+)
+
+// useDB adds the collection to the database for the searches of the test
+func useDB(t *testing.T, collection *Collection.Collection) {
+	t.Helper()
+	if Vdb.DB.Collections == nil {
+		Vdb.DB.Collections = make(map[string]*Collection.Collection)
+	}
+	Vdb.DB.Collections[collection.Name] = collection
+	t.Cleanup(func() {
+		delete(Vdb.DB.Collections, collection.Name)
+	})
+}
+
+// searchIndex returns the sorted ids of the vectors an index search finds for the index value
+func searchIndex(t *testing.T, collection *Collection.Collection, index string, value any, data []float64) []string {
+	t.Helper()
+	getVectors, getIds := false, true
+	results := Vdb.DB.IndexSearch(collection.Name, Vector.NewVector("target", data, nil, ""), Utils.NewHeapControl(10), 0,
+		nil, index, value, false, Utils.SearchParams{}, &getVectors, &getIds)
+	ids := make([]string, 0, len(results))
+	for _, result := range results {
+		ids = append(ids, result.Id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// indexedIds returns the sorted ids of the vectors in the sub kd tree of the value of a value index
+func indexedIds(collection *Collection.Collection, index string, value any) []string {
+	ids := []string{}
+	collection.Indexes[index].Entries[value].Walk(func(vector *Vector.Vector) {
+		if !vector.IsDeleted() {
+			ids = append(ids, vector.Id)
+		}
+	})
+	slices.Sort(ids)
+	return ids
+}
+
+// colorCollection returns a collection with the value index color and the range index size
+func colorCollection(t *testing.T) *Collection.Collection {
+	t.Helper()
+	useTempStore(t)
+	collection := newCollection(t, "colors", 2, "euclid", Vector.Float64)
+	useDB(t, collection)
+	insert(t, collection, "a", []float64{0, 0}, map[string]interface{}{"color": "red", "size": 1.0})
+	insert(t, collection, "b", []float64{1, 1}, map[string]interface{}{"color": "red", "size": 2.0})
+	insert(t, collection, "c", []float64{2, 2}, map[string]interface{}{"color": "green", "size": 3.0})
+	for key, indexType := range map[string]string{"color": Collection.ValueIndex, "size": Collection.RangeIndex} {
+		err := collection.CreateIndex(key, key, indexType)
+		if err != nil {
+			t.Fatalf("Creating index %s failed: %s", key, err)
+		}
+	}
+	return collection
+}
+
+func TestUpsert(t *testing.T) {
+	useTempStore(t)
+	collection := newCollection(t, "upsert", 2, "euclid", Vector.Float64)
+	insert(t, collection, "a", []float64{1, 1}, map[string]interface{}{"n": 1.0})
+
+	// A new id is inserted
+	err := collection.Upsert("b", []float64{2, 2}, &map[string]interface{}{"n": 2.0})
+	if err != nil {
+		t.Fatalf("Upserting a new vector failed: %s", err)
+	}
+	// The data and the payload of an existing id are replaced
+	err = collection.Upsert("a", []float64{3, 3}, &map[string]interface{}{"n": 3.0})
+	if err != nil {
+		t.Fatalf("Upserting an existing vector failed: %s", err)
+	}
+	if data := *(*collection.Space)["a"].GetData(); !slices.Equal(data, []float64{3, 3}) {
+		t.Errorf("Expected the data [3 3], got %v", data)
+	}
+	if n := payload(t, collection, "a")["n"]; n != 3.0 {
+		t.Errorf("Expected the payload n = 3, got %v", n)
+	}
+	// A nil payload keeps the payload, a nil data keeps the data
+	err = collection.Upsert("a", []float64{4, 4}, nil)
+	if err != nil {
+		t.Fatalf("Upserting the data failed: %s", err)
+	}
+	err = collection.UpdatePayload("a", &map[string]interface{}{"n": 4.0})
+	if err != nil {
+		t.Fatalf("Updating the payload failed: %s", err)
+	}
+	if data := *(*collection.Space)["a"].GetData(); !slices.Equal(data, []float64{4, 4}) {
+		t.Errorf("Expected the data [4 4], got %v", data)
+	}
+	if n := payload(t, collection, "a")["n"]; n != 4.0 {
+		t.Errorf("Expected the payload n = 4, got %v", n)
+	}
+
+	if err := collection.Upsert("c", nil, &map[string]interface{}{}); err == nil {
+		t.Errorf("Expected an error for the payload of a missing vector")
+	}
+	if err := collection.Upsert("a", []float64{1, 2, 3}, nil); err == nil {
+		t.Errorf("Expected an error for a vector of another dimension")
+	}
+
+	// The upserts survive a restart
+	booted := reboot(t, collection)["upsert"]
+	if data := *(*booted.Space)["a"].GetData(); !slices.Equal(data, []float64{4, 4}) {
+		t.Errorf("Expected the data [4 4] after a restart, got %v", data)
+	}
+	if n := payload(t, booted, "a")["n"]; n != 4.0 {
+		t.Errorf("Expected the payload n = 4 after a restart, got %v", n)
+	}
+	if _, ok := (*booted.Space)["b"]; !ok {
+		t.Errorf("Expected the upserted vector b after a restart")
+	}
+}
+
+func TestUpsertUpdatesIndexes(t *testing.T) {
+	collection := colorCollection(t)
+	err := collection.Upsert("a", []float64{5, 5}, &map[string]interface{}{"color": "blue", "size": 10.0})
+	if err != nil {
+		t.Fatalf("Upserting failed: %s", err)
+	}
+	err = collection.UpdatePayload("c", &map[string]interface{}{"color": "red"})
+	if err != nil {
+		t.Fatalf("Updating the payload failed: %s", err)
+	}
+
+	// The next search finds the new values and not the old ones
+	if ids := searchIndex(t, collection, "color", "blue", []float64{0, 0}); !slices.Equal(ids, []string{"a"}) {
+		t.Errorf("Expected [a] for blue, got %v", ids)
+	}
+	if ids := searchIndex(t, collection, "color", "red", []float64{0, 0}); !slices.Equal(ids, []string{"b", "c"}) {
+		t.Errorf("Expected [b c] for red, got %v", ids)
+	}
+	if ids := searchIndex(t, collection, "size", 10.0, []float64{0, 0}); !slices.Equal(ids, []string{"a"}) {
+		t.Errorf("Expected [a] for size 10, got %v", ids)
+	}
+	if ids := searchIndex(t, collection, "size", 1.0, []float64{0, 0}); len(ids) != 0 {
+		t.Errorf("Expected no vector for the old size 1, got %v", ids)
+	}
+
+	// The replaced vectors are removed from the indexes
+	if ids := indexedIds(collection, "color", "red"); !slices.Equal(ids, []string{"b", "c"}) {
+		t.Errorf("Expected [b c] in the sub kd tree of red, got %v", ids)
+	}
+	if _, ok := collection.Indexes["color"].Entries["green"]; ok {
+		t.Errorf("Expected the sub kd tree of green to be removed")
+	}
+	stats, err := collection.IndexStats("size")
+	if err != nil {
+		t.Fatalf("Getting the index stats failed: %s", err)
+	}
+	// The payload of c has no size anymore
+	if stats.Vectors != 2 || stats.Counts["1"] != 0 || stats.Counts["3"] != 0 || stats.Counts["10"] != 1 {
+		t.Errorf("Expected the sizes 2 and 10, got %v", stats.Counts)
+	}
+	if len(collection.Indexes["size"].Sorted) != 2 {
+		t.Errorf("Expected 2 sorted values, got %d", len(collection.Indexes["size"].Sorted))
+	}
+}
@@ -0,0 +1,251 @@
+// vectorindex_test.go
+package Collection
+
+import (
+	"VreeDB/Collection"
+	"VreeDB/Ivf"
+	"VreeDB/Utils"
+	"VreeDB/Vector"
+	"math"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+// indexedCollection returns a collection searched with the vector index that holds n random vectors
+func indexedCollection(t *testing.T, name, distanceFunc, indexType string, params map[string]int, n int) *Collection.Collection {
+	t.Helper()
+	useTempStore(t)
+	collection := newCollection(t, name, 8, distanceFunc, Vector.Float64)
+	err := collection.SetVectorIndex(indexType, params)
+	if err != nil {
+		t.Fatalf("Setting the %s index failed: %s", indexType, err)
+	}
+	err = collection.WriteConfig()
+	if err != nil {
+		t.Fatalf("Writing config failed: %s", err)
+	}
+	r := rand.New(rand.NewSource(7))
+	for i := 0; i < n; i++ {
+		insert(t, collection, strconv.Itoa(i), randomData(r, 8), map[string]interface{}{"n": float64(i)})
+	}
+	return collection
+}
+
+// randomData returns a vector with values in [-1, 1)
+func randomData(r *rand.Rand, dimension int) []float64 {
+	data := make([]float64, dimension)
+	for i := range data {
+		data[i] = 2*r.Float64() - 1
+	}
+	return data
+}
+
+// indexSearch returns the ids of the k nearest vectors the vector index finds and checks their distances
+func indexSearch(t *testing.T, collection *Collection.Collection, data []float64, k int, params Utils.SearchParams) map[string]bool {
+	t.Helper()
+	collection.Mut.RLock()
+	defer collection.Mut.RUnlock()
+	target := &Vector.Vector{Data: data, Length: len(data)}
+	ids := make(map[string]bool, k)
+	for _, item := range collection.VectorIndex.Search(target, k, nil, params) {
+		if math.IsNaN(item.Distance) {
+			t.Errorf("Expected a distance for vector %s, got NaN", item.Node.Vector.Id)
+		}
+		ids[item.Node.Vector.Id] = true
+	}
+	return ids
+}
+
+// exactSearch returns the ids of the k nearest vectors
+func exactSearch(collection *Collection.Collection, data []float64, k int) map[string]bool {
+	collection.Mut.RLock()
+	defer collection.Mut.RUnlock()
+	ids := make(map[string]bool, k)
+	for _, item := range collection.ExactSearch(data, k, nil) {
+		ids[item.Node.Vector.Id] = true
+	}
+	return ids
+}
+
+// checkRecall checks that the vector index finds at least the share of the exact k nearest vectors of random
+// targets and that a stored vector finds itself
+func checkRecall(t *testing.T, collection *Collection.Collection, minRecall float64, params Utils.SearchParams) {
+	t.Helper()
+	const k, samples = 10, 20
+	r := rand.New(rand.NewSource(11))
+	found := 0
+	for i := 0; i < samples; i++ {
+		data := randomData(r, collection.VectorDimension)
+		ids := indexSearch(t, collection, data, k, params)
+		if len(ids) != k {
+			t.Fatalf("Expected %d vectors, got %d", k, len(ids))
+		}
+		for id := range exactSearch(collection, data, k) {
+			if ids[id] {
+				found++
+			}
+		}
+	}
+	if recall := float64(found) / (k * samples); recall < minRecall {
+		t.Errorf("Expected a recall of at least %.2f, got %.2f", minRecall, recall)
+	}
+
+	self := *(*collection.Space)["42"].GetData()
+	if !indexSearch(t, collection, self, 1, params)["42"] {
+		t.Errorf("Expected vector 42 to be its own nearest vector")
+	}
+}
+
+// checkPersisted checks that the booted collection has the vector index and finds the same vectors
+func checkPersisted(t *testing.T, collection, booted *Collection.Collection, before []map[string]bool, params Utils.SearchParams) {
+	t.Helper()
+	if booted == nil || booted.VectorIndex == nil || booted.IndexType != collection.IndexType {
+		t.Fatalf("Expected the %s index to be restored", collection.IndexType)
+	}
+	r := rand.New(rand.NewSource(13))
+	for i := range before {
+		after := indexSearch(t, booted, randomData(r, booted.VectorDimension), 10, params)
+		for id := range before[i] {
+			if !after[id] {
+				t.Errorf("Expected the restored index to find the vectors %v, got %v", before[i], after)
+				break
+			}
+		}
+	}
+}
+
+// searchSamples returns the results of searches for random targets before a reboot - checkPersisted repeats them
+func searchSamples(t *testing.T, collection *Collection.Collection, params Utils.SearchParams) []map[string]bool {
+	t.Helper()
+	r := rand.New(rand.NewSource(13))
+	results := make([]map[string]bool, 5)
+	for i := range results {
+		results[i] = indexSearch(t, collection, randomData(r, collection.VectorDimension), 10, params)
+	}
+	return results
+}
+
+func TestPqSearch(t *testing.T) {
+	for _, distanceFunc := range []string{"euclid", "cosine"} {
+		t.Run(distanceFunc, func(t *testing.T) {
+			collection := indexedCollection(t, "pq", distanceFunc, "pq", map[string]int{"subspaces": 4, "centroids": 16}, 300)
+			// Untrained indexes search the full vectors
+			checkRecall(t, collection, 1, Utils.SearchParams{})
+
+			err := collection.TrainIndex()
+			if err != nil {
+				t.Fatalf("Training the index failed: %s", err)
+			}
+			checkRecall(t, collection, 0.8, Utils.SearchParams{})
+
+			before := searchSamples(t, collection, Utils.SearchParams{})
+			checkPersisted(t, collection, reboot(t, collection)["pq"], before, Utils.SearchParams{})
+		})
+	}
+}
+
+func TestPqCosineZeroVector(t *testing.T) {
+	collection := indexedCollection(t, "pq", "cosine", "pq", map[string]int{"subspaces": 4, "centroids": 16}, 100)
+	insert(t, collection, "zero", make([]float64, 8), map[string]interface{}{})
+	err := collection.TrainIndex()
+	if err != nil {
+		t.Fatalf("Training the index failed: %s", err)
+	}
+
+	// A zero vector is not similar to any vector - neither as target nor in the index
+	if ids := indexSearch(t, collection, make([]float64, 8), 5, Utils.SearchParams{}); len(ids) != 5 {
+		t.Errorf("Expected 5 vectors for a zero target, got %d", len(ids))
+	}
+	self := *(*collection.Space)["42"].GetData()
+	if ids := indexSearch(t, collection, self, 10, Utils.SearchParams{}); !ids["42"] || ids["zero"] {
+		t.Errorf("Expected vector 42 and not the zero vector in the nearest vectors, got %v", ids)
+	}
+}
+
+func TestHnswSearch(t *testing.T) {
+	for _, distanceFunc := range []string{"euclid", "cosine"} {
+		t.Run(distanceFunc, func(t *testing.T) {
+			collection := indexedCollection(t, "hnsw", distanceFunc, "hnsw", map[string]int{"m": 8, "ef_construction": 64}, 400)
+			checkRecall(t, collection, 0.9, Utils.SearchParams{})
+			// A wider search of the bottom layer finds more of the nearest vectors
+			checkRecall(t, collection, 0.98, Utils.SearchParams{EfSearch: 200})
+
+			before := searchSamples(t, collection, Utils.SearchParams{EfSearch: 200})
+			checkPersisted(t, collection, reboot(t, collection)["hnsw"], before, Utils.SearchParams{EfSearch: 200})
+		})
+	}
+}
+
+func TestHnswDeletedVectors(t *testing.T) {
+	collection := indexedCollection(t, "hnsw", "euclid", "hnsw", map[string]int{"m": 6, "ef_construction": 32}, 200)
+	deleted := []string{"3", "17", "99", "150"}
+	err := collection.DeleteVectorByID(deleted)
+	if err != nil {
+		t.Fatalf("Deleting vectors failed: %s", err)
+	}
+	// A replaced vector is found with its new data only
+	moved := []float64{5, 5, 5, 5, 5, 5, 5, 5}
+	err = collection.Upsert("7", moved, nil)
+	if err != nil {
+		t.Fatalf("Upserting failed: %s", err)
+	}
+
+	for _, id := range deleted {
+		data := *(*collection.DeletedVectors)[id].GetData()
+		if ids := indexSearch(t, collection, data, 5, Utils.SearchParams{}); ids[id] {
+			t.Errorf("Expected the deleted vector %s not to be found", id)
+		}
+	}
+	if ids := indexSearch(t, collection, moved, 1, Utils.SearchParams{}); !ids["7"] {
+		t.Errorf("Expected the upserted vector 7 at its new data, got %v", ids)
+	}
+	checkRecall(t, collection, 0.9, Utils.SearchParams{})
+}
+
+func TestIvfSearch(t *testing.T) {
+	for _, distanceFunc := range []string{"euclid", "cosine"} {
+		t.Run(distanceFunc, func(t *testing.T) {
+			collection := indexedCollection(t, "ivf", distanceFunc, "ivf", map[string]int{"lists": 16, "nprobe": 4}, 500)
+			// Untrained indexes scan all vectors
+			checkRecall(t, collection, 1, Utils.SearchParams{})
+
+			err := collection.TrainIndex()
+			if err != nil {
+				t.Fatalf("Training the index failed: %s", err)
+			}
+			if !collection.VectorIndex.(*Ivf.IVF).Trained() {
+				t.Fatalf("Expected the ivf index to be trained")
+			}
+			checkRecall(t, collection, 0.6, Utils.SearchParams{})
+			// Scanning every list is an exact search
+			checkRecall(t, collection, 1, Utils.SearchParams{NProbe: 16})
+
+			before := searchSamples(t, collection, Utils.SearchParams{NProbe: 16})
+			booted := reboot(t, collection)["ivf"]
+			checkPersisted(t, collection, booted, before, Utils.SearchParams{NProbe: 16})
+			if ivf := booted.VectorIndex.(*Ivf.IVF); !ivf.Trained() || ivf.NProbe != 4 {
+				t.Errorf("Expected the trained index with nprobe 4 after a restart")
+			}
+		})
+	}
+}
+
+func TestIvfTrainedOnBoot(t *testing.T) {
+	collection := indexedCollection(t, "ivf", "euclid", "ivf", map[string]int{"lists": 8}, 120)
+	booted := reboot(t, collection)["ivf"]
+	if !booted.VectorIndex.(*Ivf.IVF).Trained() {
+		t.Fatalf("Expected the ivf index to be trained on boot")
+	}
+	checkRecall(t, booted, 1, Utils.SearchParams{NProbe: 8})
+}
+
+func TestIvfParams(t *testing.T) {
+	useTempStore(t)
+	collection := newCollection(t, "ivf", 4, "euclid", Vector.Float64)
+	for _, params := range []map[string]int{{"lists": -1}, {"nprobe": 0}, {"probes": 2}} {
+		if err := collection.SetVectorIndex("ivf", params); err == nil {
+			t.Errorf("Expected an error for the ivf params %v", params)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// wal_test.go
+package Collection
+
+import (
+	"VreeDB/Vector"
+	"VreeDB/Wal"
+	"slices"
+	"testing"
+)
+
+func TestWalReplay(t *testing.T) {
+	useTempStore(t)
+	collection := newCollection(t, "wal", 2, "euclid", Vector.Float64)
+	insert(t, collection, "a", []float64{1, 1}, map[string]interface{}{"n": 1.0})
+	insert(t, collection, "b", []float64{2, 2}, map[string]interface{}{"n": 2.0})
+
+	// Mutations that were logged but did not reach the collection files before the crash
+	_, err := collection.Wal.Append(Wal.Insert, []string{"c"}, []float64{3, 3}, &map[string]interface{}{"n": 3.0})
+	if err != nil {
+		t.Fatalf("Appending to wal failed: %s", err)
+	}
+	_, err = collection.Wal.Append(Wal.Delete, []string{"a"}, nil, nil)
+	if err != nil {
+		t.Fatalf("Appending to wal failed: %s", err)
+	}
+	_, err = collection.Wal.Append(Wal.Upsert, []string{"b"}, []float64{4, 4}, &map[string]interface{}{"n": 4.0})
+	if err != nil {
+		t.Fatalf("Appending to wal failed: %s", err)
+	}
+	// An insert that is deleted later in the log is not applied
+	_, err = collection.Wal.Append(Wal.Insert, []string{"d"}, []float64{5, 5}, &map[string]interface{}{"n": 5.0})
+	if err != nil {
+		t.Fatalf("Appending to wal failed: %s", err)
+	}
+	_, err = collection.Wal.Append(Wal.Delete, []string{"d"}, nil, nil)
+	if err != nil {
+		t.Fatalf("Appending to wal failed: %s", err)
+	}
+
+	booted := reboot(t, collection)["wal"]
+	if booted == nil {
+		t.Fatalf("Expected collection 'wal' to be restored")
+	}
+
+	ids := make([]string, 0, len(*booted.Space))
+	for id := range *booted.Space {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	if !slices.Equal(ids, []string{"b", "c"}) {
+		t.Fatalf("Expected vectors [b c] after the replay, got %v", ids)
+	}
+	if data := *(*booted.Space)["b"].GetData(); !slices.Equal(data, []float64{4, 4}) {
+		t.Errorf("Expected the upserted data [4 4] of vector 'b', got %v", data)
+	}
+	if n := payload(t, booted, "b")["n"]; n != 4.0 {
+		t.Errorf("Expected the upserted payload of vector 'b', got n = %v", n)
+	}
+	if data := *(*booted.Space)["c"].GetData(); !slices.Equal(data, []float64{3, 3}) {
+		t.Errorf("Expected the logged data [3 3] of vector 'c', got %v", data)
+	}
+	if n := payload(t, booted, "c")["n"]; n != 3.0 {
+		t.Errorf("Expected the logged payload of vector 'c', got n = %v", n)
+	}
+
+	// The replayed log is checkpointed
+	if size := booted.Wal.Size(); size != 0 {
+		t.Errorf("Expected the wal to be truncated after the replay, got %d bytes", size)
+	}
+}
+
+func TestWalReplayIsIdempotent(t *testing.T) {
+	useTempStore(t)
+	collection := newCollection(t, "wal", 2, "euclid", Vector.Float64)
+	insert(t, collection, "a", []float64{1, 1}, map[string]interface{}{"n": 1.0})
+	insert(t, collection, "b", []float64{2, 2}, map[string]interface{}{"n": 2.0})
+	err := collection.DeleteVectorByID([]string{"a"})
+	if err != nil {
+		t.Fatalf("Deleting vector failed: %s", err)
+	}
+
+	// All logged mutations were applied - the replay must not apply them again
+	booted := reboot(t, collection)["wal"]
+	if _, ok := (*booted.Space)["a"]; ok {
+		t.Errorf("Expected the deleted vector 'a' not to be restored")
+	}
+	if len(*booted.Space) != 1 {
+		t.Errorf("Expected 1 vector after the replay, got %d", len(*booted.Space))
+	}
+	if data := *(*booted.Space)["b"].GetData(); !slices.Equal(data, []float64{2, 2}) {
+		t.Errorf("Expected the data [2 2] of vector 'b', got %v", data)
+	}
+}
+
+func TestWalReplayPayload(t *testing.T) {
+	useTempStore(t)
+	collection := newCollection(t, "wal", 2, "euclid", Vector.Float64)
+	insert(t, collection, "a", []float64{1, 1}, map[string]interface{}{"tag": "x"})
+	insert(t, collection, "b", []float64{2, 2}, map[string]interface{}{"tag": "y"})
+
+	// A payload on top of the data in the files and a payload on top of an upsert that was not applied
+	_, err := collection.Wal.Append(Wal.Payload, []string{"a"}, nil, &map[string]interface{}{"tag": "z"})
+	if err != nil {
+		t.Fatalf("Appending to wal failed: %s", err)
+	}
+	_, err = collection.Wal.Append(Wal.Upsert, []string{"b"}, []float64{7, 7}, &map[string]interface{}{"tag": "v"})
+	if err != nil {
+		t.Fatalf("Appending to wal failed: %s", err)
+	}
+	_, err = collection.Wal.Append(Wal.Payload, []string{"b"}, nil, &map[string]interface{}{"tag": "w"})
+	if err != nil {
+		t.Fatalf("Appending to wal failed: %s", err)
+	}
+
+	booted := reboot(t, collection)["wal"]
+	if len(*booted.Space) != 2 {
+		t.Fatalf("Expected 2 vectors after the replay, got %d", len(*booted.Space))
+	}
+	if data := *(*booted.Space)["a"].GetData(); !slices.Equal(data, []float64{1, 1}) {
+		t.Errorf("Expected the data [1 1] of vector 'a', got %v", data)
+	}
+	if tag := payload(t, booted, "a")["tag"]; tag != "z" {
+		t.Errorf("Expected the logged payload z of vector 'a', got %v", tag)
+	}
+	if data := *(*booted.Space)["b"].GetData(); !slices.Equal(data, []float64{7, 7}) {
+		t.Errorf("Expected the upserted data [7 7] of vector 'b', got %v", data)
+	}
+	if tag := payload(t, booted, "b")["tag"]; tag != "w" {
+		t.Errorf("Expected the logged payload w of vector 'b', got %v", tag)
+	}
+}
@@ -33,7 +33,7 @@ func (sw *SearchWorker) Start() {
 	for i := 0; i < *ArgsParser.Ap.SearchThreads; i++ {
 		go func() {
 			for data := range sw.schan {
-				data.SU.NearestNeighbors(data.Node, data.Target, data.Queue, data.DistanceFunc, data.DimensionDiff)
+				data.SU.NearestNeighbors(data.Node, data.Target, data.Queue, data.DistanceFunc, data.DimensionDiff, data.PlaneDistance)
 				data.SU.releaseWaitGroup()
 			}
 		}()
@@ -5,7 +5,9 @@ import (
 	"VreeDB/Logger"
 	"VreeDB/Node"
 	"container/heap"
+	"math"
 	"sync"
+	"sync/atomic"
 )
 
 // HeapChannelStruct is a struct that holds a channel and a heap
@@ -23,6 +25,7 @@ type HeapControl struct {
 	In         chan HeapChannelStruct
 	MaxDiff    float64
 	Wg         sync.WaitGroup
+	worst      atomic.Uint64 // bits of the largest distance in the heap once it is full
 }
 
 // The HeapItem struct is used to store a Node and its distance to the query vector
@@ -68,6 +71,7 @@ func (h *Heap) Pop() interface{} {
 func NewHeapControl(n int) *HeapControl {
 	h := &HeapControl{MaxResults: n, Heap: Heap{}, In: make(chan HeapChannelStruct, 100000), MaxDiff: 0}
 	heap.Init(&h.Heap)
+	h.worst.Store(math.Float64bits(math.Inf(1)))
 	return h
 }
 
@@ -80,14 +84,14 @@ func (hc *HeapControl) StartThreads() {
 func (hc *HeapControl) worker() {
 	defer hc.Wg.Done()
 	for item := range hc.In {
-		// Validate the filters
-		if ok, err := hc.validateFilters(&item); !ok {
+		// Deleted vectors are skipped before the filters - their payload may not exist anymore
+		if item.node.Vector.IsDeleted() {
+			continue
+		} else if ok, err := hc.validateFilters(&item); !ok {
 			// If the filters are not valid log the possible error
 			if err != nil {
 				Logger.Log.Log("Error validating filters: "+err.Error(), "ERROR")
 			}
-		} else if item.node.Vector.IsDeleted() {
-			continue
 		} else {
 			// Insert the item into the heap
 			hc.Insert(item.node, item.dist, item.diff)
@@ -102,12 +106,7 @@ func (hc *HeapControl) validateFilters(hcs *HeapChannelStruct) (bool, error) {
 		return true, nil
 	}
 	// Validate the filters
-	for _, f := range *hcs.Filter {
-		if ok, err := f.ValidateFilter(hcs.node.Vector); !ok {
-			return false, err
-		}
-	}
-	return true, nil
+	return Filter.Evaluate(hcs.Filter, hcs.node.Vector)
 }
 
 // Insert inserts a node into the heap
@@ -116,6 +115,14 @@ func (hc *HeapControl) Insert(node *Node.Node, distance, diff float64) {
 	if hc.Heap.Len() > hc.MaxResults {
 		heap.Pop(&hc.Heap)
 	}
+	if hc.Heap.Len() >= hc.MaxResults {
+		hc.worst.Store(math.Float64bits(hc.Heap[0].Distance))
+	}
+}
+
+// Worst returns the largest distance in the heap once it is full - until then it is infinite
+func (hc *HeapControl) Worst() float64 {
+	return math.Float64frombits(hc.worst.Load())
 }
 
 // AddToWaitGroup adds a new item to the waitgroup
@@ -0,0 +1,81 @@
+package Utils
+
+import (
+	"math/rand"
+)
+
+// KMeans clusters the points into k centroids with Lloyd's algorithm. The centroids start at random points,
+// empty clusters are moved to a random point again. The caller must pass at least k points.
+func (u *Util) KMeans(points [][]float64, k, iterations int, seed int64) [][]float64 {
+	r := rand.New(rand.NewSource(seed))
+	dimension := len(points[0])
+
+	// Start with k distinct random points
+	centroids := make([][]float64, k)
+	for i, p := range r.Perm(len(points))[:k] {
+		centroids[i] = append([]float64{}, points[p]...)
+	}
+
+	assignments := make([]int, len(points))
+	for i := range assignments {
+		assignments[i] = -1
+	}
+	sums := make([][]float64, k)
+	for i := range sums {
+		sums[i] = make([]float64, dimension)
+	}
+	counts := make([]int, k)
+
+	for it := 0; it < iterations; it++ {
+		// Assign every point to its nearest centroid
+		changed := false
+		for i, p := range points {
+			nearest, _ := u.NearestCentroid(centroids, p)
+			if nearest != assignments[i] {
+				assignments[i] = nearest
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+
+		// Move the centroids to the mean of their points
+		for i := range sums {
+			clear(sums[i])
+			counts[i] = 0
+		}
+		for i, p := range points {
+			for j, value := range p {
+				sums[assignments[i]][j] += value
+			}
+			counts[assignments[i]]++
+		}
+		for i := range centroids {
+			if counts[i] == 0 {
+				copy(centroids[i], points[r.Intn(len(points))])
+				continue
+			}
+			for j := range centroids[i] {
+				centroids[i][j] = sums[i][j] / float64(counts[i])
+			}
+		}
+	}
+	return centroids
+}
+
+// NearestCentroid returns the index of the nearest centroid to the point and the squared euclidean distance to it
+func (u *Util) NearestCentroid(centroids [][]float64, point []float64) (int, float64) {
+	nearest, best := 0, -1.0
+	for i, c := range centroids {
+		var sum float64
+		for j, value := range point {
+			diff := value - c[j]
+			sum += diff * diff
+		}
+		if best < 0 || sum < best {
+			nearest, best = i, sum
+		}
+	}
+	return nearest, best
+}
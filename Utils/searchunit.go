@@ -6,14 +6,22 @@ import (
 	"VreeDB/Vector"
 	"math"
 	"sync"
+	"sync/atomic"
 )
 
+// DefaultSearchAccuracy is the share of the range of an axis within which the other side of a split is searched
+// by the axis range test if no search accuracy is set
+const DefaultSearchAccuracy = 0.1
+
 // SearchUnit represents a unit used for searching.
 type SearchUnit struct {
-	dimensionMultiplier float64
-	Filter              *[]Filter.Filter
-	Chan                chan *SearchData
-	wg                  *sync.WaitGroup
+	accuracy  float64
+	euclid    bool
+	maxChecks int64
+	checks    atomic.Int64
+	Filter    *[]Filter.Filter
+	Chan      chan *SearchData
+	wg        *sync.WaitGroup
 }
 
 type SearchData struct {
@@ -23,6 +31,7 @@ type SearchData struct {
 	DistanceFunc  func(*Vector.Vector, *Vector.Vector) (float64, error)
 	DimensionDiff *Vector.Vector
 	SU            *SearchUnit
+	PlaneDistance float64 // distance of the target to the splitting plane of the parent - 0 for the primary side
 }
 
 // NearestNeighbors returns the results nearest neighbours to the given target vector.
@@ -32,20 +41,28 @@ type SearchData struct {
 // right child node based on the target vector values. Finally, it recursively calls
 // `NearestNeighbors` on the primary and secondary child nodes.
 func (s *SearchUnit) NearestNeighbors(node *Node.Node, target *Vector.Vector, queue *HeapControl,
-	distanceFunc func(*Vector.Vector, *Vector.Vector) (float64, error), dimensionDiff *Vector.Vector) {
+	distanceFunc func(*Vector.Vector, *Vector.Vector) (float64, error), dimensionDiff *Vector.Vector, planeDistance float64) {
 	if node == nil || node.Vector == nil {
 		return
 	}
-	axis := node.Depth % node.Vector.Length
+	// The results may have become closer than the splitting plane since the node was queued
+	if s.euclid && planeDistance > 0 && planeDistance >= queue.Worst()*s.accuracy {
+		return
+	}
+	// Stop when the maximum number of distance calculations is reached
+	if s.maxChecks > 0 && s.checks.Add(1) > s.maxChecks {
+		return
+	}
+	axis := node.Axis
 
 	// Use the vector Functions
 	dist, _ := distanceFunc(node.Vector, target)
-	axisDiff := math.Abs(target.Data[axis] - node.Vector.Data[axis])
+	axisDiff := math.Abs(target.Data[axis] - node.Vector.Get(axis))
 
 	// Just push it into the queue if it is small enough it will be added
 	queue.In <- HeapChannelStruct{node: node, dist: dist, diff: axisDiff, Filter: s.Filter}
 	var primary, secondary *Node.Node
-	if target.Data[axis] < node.Vector.Data[axis] {
+	if target.Data[axis] < node.Vector.Get(axis) {
 		primary = node.Left
 		secondary = node.Right
 	} else {
@@ -58,19 +75,45 @@ func (s *SearchUnit) NearestNeighbors(node *Node.Node, target *Vector.Vector, qu
 		s.Chan <- &SearchData{Node: primary, Target: target, Queue: queue, DistanceFunc: distanceFunc, DimensionDiff: dimensionDiff, SU: s}
 	}()
 
-	// If the distance is smaller than the dimensionDiff we need to search the other side
-	if axisDiff < dimensionDiff.Data[axis]*s.dimensionMultiplier {
+	// Search the other side if it may hold closer vectors
+	if secondary != nil && s.searchSecondary(axis, axisDiff, queue, dimensionDiff) {
 		s.AddToWaitGroup()
 		// We put this in a goroutine to prevent a possible deadlock
 		go func() {
-			s.Chan <- &SearchData{secondary, target, queue, distanceFunc, dimensionDiff, s}
+			s.Chan <- &SearchData{Node: secondary, Target: target, Queue: queue, DistanceFunc: distanceFunc,
+				DimensionDiff: dimensionDiff, SU: s, PlaneDistance: axisDiff}
 		}()
 	}
 }
 
-// NewSearchUnit returns a new SearchUnit
-func NewSearchUnit(filter *[]Filter.Filter, dimensionMultiplier float64) *SearchUnit {
-	return &SearchUnit{dimensionMultiplier: dimensionMultiplier, Filter: filter, Chan: Searcher.GetChan(), wg: &sync.WaitGroup{}}
+// searchSecondary decides if the other side of a split is searched. With the euclidean distance the other side is
+// searched if the hypersphere around the target with the worst distance of the results (scaled by the accuracy)
+// crosses the splitting plane - an accuracy of 1 never misses a closer vector. Cosine searches and searches with the
+// axis range test search the other side if the target is within the accuracy share of the range of the axis to
+// the plane.
+func (s *SearchUnit) searchSecondary(axis int, axisDiff float64, queue *HeapControl, dimensionDiff *Vector.Vector) bool {
+	if s.euclid {
+		return axisDiff < queue.Worst()*s.accuracy
+	}
+	return axisDiff < dimensionDiff.Data[axis]*s.accuracy
+}
+
+// NewSearchUnit returns a new SearchUnit. The accuracy controls how often the other side of a split is searched.
+// Euclidean searches use the plane test of searchSecondary, without an accuracy it never misses a closer vector.
+// Cosine searches never get the plane test - the worst distance is no bound for the distance to a splitting plane -
+// they use the axis range test like euclidean searches with axisRange set, without an accuracy the other side is
+// searched within the DefaultSearchAccuracy share of the range of the axis.
+// A search stops after maxChecks distance calculations - 0 is unlimited.
+func NewSearchUnit(filter *[]Filter.Filter, accuracy float64, maxChecks int, euclid bool, axisRange bool) *SearchUnit {
+	planeTest := euclid && !axisRange
+	if accuracy == 0 {
+		accuracy = DefaultSearchAccuracy
+		if planeTest {
+			accuracy = 1
+		}
+	}
+	return &SearchUnit{accuracy: accuracy, euclid: planeTest, maxChecks: int64(maxChecks), Filter: filter,
+		Chan: Searcher.GetChan(), wg: &sync.WaitGroup{}}
 }
 
 // AddToWaitGroup blocks until the SearchUnit is finished
@@ -134,6 +134,132 @@ double cosine_distance_avx(const double* a, const double* b, int n) {
     return 1.0 - (final_sum_ab / (sqrt(final_sum_a) * sqrt(final_sum_b)));
 }
 
+// horizontal sum of the 8 floats in a __m256
+static inline double hsum_ps_avx(__m256 v) {
+    __m128 lo = _mm256_castps256_ps128(v);
+    __m128 hi = _mm256_extractf128_ps(v, 1);
+    lo = _mm_add_ps(lo, hi);
+    lo = _mm_hadd_ps(lo, lo);
+    lo = _mm_hadd_ps(lo, lo);
+    return (double)_mm_cvtss_f32(lo);
+}
+
+double euclidean_distance_f32_avx(const float* a, const float* b, int n) {
+    __m256 sum1 = _mm256_setzero_ps();
+    __m256 sum2 = _mm256_setzero_ps();
+    int i;
+
+    // Process 16 elements at a time
+    for (i = 0; i <= n - 16; i += 16) {
+        __m256 diff1 = _mm256_sub_ps(_mm256_loadu_ps(&a[i]), _mm256_loadu_ps(&b[i]));
+        __m256 diff2 = _mm256_sub_ps(_mm256_loadu_ps(&a[i + 8]), _mm256_loadu_ps(&b[i + 8]));
+        sum1 = _mm256_add_ps(sum1, _mm256_mul_ps(diff1, diff1));
+        sum2 = _mm256_add_ps(sum2, _mm256_mul_ps(diff2, diff2));
+    }
+
+    // Handle the remaining elements (if any) in chunks of 8
+    for (; i <= n - 8; i += 8) {
+        __m256 diff = _mm256_sub_ps(_mm256_loadu_ps(&a[i]), _mm256_loadu_ps(&b[i]));
+        sum1 = _mm256_add_ps(sum1, _mm256_mul_ps(diff, diff));
+    }
+    double final_sum = hsum_ps_avx(_mm256_add_ps(sum1, sum2));
+
+    // Handle the remaining elements (if any) one by one
+    for (; i < n; i++) {
+        double diff = a[i] - b[i];
+        final_sum += diff * diff;
+    }
+
+    return sqrt(final_sum);
+}
+
+double cosine_distance_f32_avx(const float* a, const float* b, int n) {
+    __m256 sum_a = _mm256_setzero_ps();
+    __m256 sum_b = _mm256_setzero_ps();
+    __m256 sum_ab = _mm256_setzero_ps();
+    int i;
+
+    // Process 8 elements at a time
+    for (i = 0; i <= n - 8; i += 8) {
+        __m256 va = _mm256_loadu_ps(&a[i]);
+        __m256 vb = _mm256_loadu_ps(&b[i]);
+        sum_ab = _mm256_add_ps(sum_ab, _mm256_mul_ps(va, vb));
+        sum_a = _mm256_add_ps(sum_a, _mm256_mul_ps(va, va));
+        sum_b = _mm256_add_ps(sum_b, _mm256_mul_ps(vb, vb));
+    }
+    double final_sum_ab = hsum_ps_avx(sum_ab);
+    double final_sum_a = hsum_ps_avx(sum_a);
+    double final_sum_b = hsum_ps_avx(sum_b);
+
+    // Handle the remaining elements (if any) one by one
+    for (; i < n; i++) {
+        final_sum_ab += (double)a[i] * b[i];
+        final_sum_a += (double)a[i] * a[i];
+        final_sum_b += (double)b[i] * b[i];
+    }
+
+    return 1.0 - (final_sum_ab / (sqrt(final_sum_a) * sqrt(final_sum_b)));
+}
+
+// load 8 int8 codes and restore them to floats: base + scale * code
+static inline __m256 dequantize_avx(const int8_t* c, const float* base, const float* scale) {
+    __m128i codes = _mm_loadl_epi64((const __m128i*)c);
+    __m128 lo = _mm_cvtepi32_ps(_mm_cvtepi8_epi32(codes));
+    __m128 hi = _mm_cvtepi32_ps(_mm_cvtepi8_epi32(_mm_srli_si128(codes, 4)));
+    __m256 values = _mm256_insertf128_ps(_mm256_castps128_ps256(lo), hi, 1);
+    return _mm256_add_ps(_mm256_loadu_ps(base), _mm256_mul_ps(_mm256_loadu_ps(scale), values));
+}
+
+double euclidean_distance_int8_avx(const int8_t* c, const float* q, const float* base, const float* scale, int n) {
+    __m256 sum = _mm256_setzero_ps();
+    int i;
+
+    // Process 8 elements at a time
+    for (i = 0; i <= n - 8; i += 8) {
+        __m256 diff = _mm256_sub_ps(dequantize_avx(&c[i], &base[i], &scale[i]), _mm256_loadu_ps(&q[i]));
+        sum = _mm256_add_ps(sum, _mm256_mul_ps(diff, diff));
+    }
+    double final_sum = hsum_ps_avx(sum);
+
+    // Handle the remaining elements (if any) one by one
+    for (; i < n; i++) {
+        double diff = (base[i] + scale[i] * c[i]) - q[i];
+        final_sum += diff * diff;
+    }
+
+    return sqrt(final_sum);
+}
+
+double cosine_distance_int8_avx(const int8_t* c, const float* q, const float* base, const float* scale, int n) {
+    __m256 sum_a = _mm256_setzero_ps();
+    __m256 sum_b = _mm256_setzero_ps();
+    __m256 sum_ab = _mm256_setzero_ps();
+    int i;
+
+    // Process 8 elements at a time
+    for (i = 0; i <= n - 8; i += 8) {
+        __m256 va = dequantize_avx(&c[i], &base[i], &scale[i]);
+        __m256 vb = _mm256_loadu_ps(&q[i]);
+        sum_ab = _mm256_add_ps(sum_ab, _mm256_mul_ps(va, vb));
+        sum_a = _mm256_add_ps(sum_a, _mm256_mul_ps(va, va));
+        sum_b = _mm256_add_ps(sum_b, _mm256_mul_ps(vb, vb));
+    }
+    double final_sum_ab = hsum_ps_avx(sum_ab);
+    double final_sum_a = hsum_ps_avx(sum_a);
+    double final_sum_b = hsum_ps_avx(sum_b);
+
+    // Handle the remaining elements (if any) one by one
+    for (; i < n; i++) {
+        double va = base[i] + scale[i] * c[i];
+        double vb = q[i];
+        final_sum_ab += va * vb;
+        final_sum_a += va * va;
+        final_sum_b += vb * vb;
+    }
+
+    return 1.0 - (final_sum_ab / (sqrt(final_sum_a) * sqrt(final_sum_b)));
+}
+
 // dummy for x86 x64
 double euclidean_distance_neon(double *array1, double *array2, int len){
 	return 0;
@@ -223,6 +349,22 @@ double cosine_distance_avx(const double* a, const double* b, int n){
 	return 0;
 }
 
+double euclidean_distance_f32_avx(const float* a, const float* b, int n) {
+	return 0;
+}
+
+double cosine_distance_f32_avx(const float* a, const float* b, int n) {
+	return 0;
+}
+
+double euclidean_distance_int8_avx(const int8_t* c, const float* q, const float* base, const float* scale, int n) {
+	return 0;
+}
+
+double cosine_distance_int8_avx(const int8_t* c, const float* q, const float* base, const float* scale, int n) {
+	return 0;
+}
+
 #else
 
 double euclidean_distance_avx(const double* a, const double* b, int n) {
@@ -241,8 +383,50 @@ double cosine_distance_neon(double *array1, double *array2, int len) {
 	return 0;
 }
 
+double euclidean_distance_f32_avx(const float* a, const float* b, int n) {
+	return 0;
+}
+
+double cosine_distance_f32_avx(const float* a, const float* b, int n) {
+	return 0;
+}
+
+double euclidean_distance_int8_avx(const int8_t* c, const float* q, const float* base, const float* scale, int n) {
+	return 0;
+}
+
+double cosine_distance_int8_avx(const int8_t* c, const float* q, const float* base, const float* scale, int n) {
+	return 0;
+}
+
 #endif
 
+// The batch kernels compute the distances of the target to count vectors stored one after another in block.
+// One call covers many vectors, so the cgo overhead is paid once per batch.
+void euclidean_distances_avx(const double* target, const double* block, double* out, int count, int n) {
+    for (int i = 0; i < count; i++) {
+        out[i] = euclidean_distance_avx(target, block + (long)i * n, n);
+    }
+}
+
+void cosine_distances_avx(const double* target, const double* block, double* out, int count, int n) {
+    for (int i = 0; i < count; i++) {
+        out[i] = cosine_distance_avx(target, block + (long)i * n, n);
+    }
+}
+
+void euclidean_distances_neon(double* target, double* block, double* out, int count, int n) {
+    for (int i = 0; i < count; i++) {
+        out[i] = euclidean_distance_neon(target, block + (long)i * n, n);
+    }
+}
+
+void cosine_distances_neon(double* target, double* block, double* out, int count, int n) {
+    for (int i = 0; i < count; i++) {
+        out[i] = cosine_distance_neon(target, block + (long)i * n, n);
+    }
+}
+
 */
 import "C"
 
@@ -266,6 +450,44 @@ type CollectionConfig struct {
 	VectorDimension  int
 	DistanceFuncName string
 	DiagonalLength   float64
+	FormatVersion    int // missing in configs of older versions - 0 is the gzip+gob format
+	Precision        string
+	QuantizerMin     []float64
+	QuantizerMax     []float64
+	IndexType        string // missing in configs of older versions - the kdtree
+	IndexParams      map[string]int
+	SearchAccuracy   float64 // missing in configs of older versions - the default search accuracy
+	MaxChecks        int
+	AxisRange        bool          // missing in configs of older versions - the plane test for euclidean collections
+	Schema           []SchemaField // missing in configs of older versions - no schema
+}
+
+// SchemaField declares the type of a payload field of a Collection
+type SchemaField struct {
+	Name     string // the field path
+	Type     string // string, number, bool, datetime, geo, array or object
+	Required bool   // points without the field are rejected
+	Indexed  bool   // a payload index is kept for the field
+}
+
+// SearchParams are per request settings of a search - zero values use the settings of the index
+type SearchParams struct {
+	EfSearch  int     // number of candidates of a hnsw search
+	NProbe    int     // number of lists an ivf search scans
+	Exact     bool    // compare the target to every vector in full precision instead of searching the index
+	Traverse  bool    // search the index without the planner, even if it would search the filtered vectors by brute force
+	Accuracy  float64 // how often the KD-Tree search searches the other side of a split
+	MaxChecks int     // maximum number of distance calculations of a KD-Tree search
+	AxisRange bool    // use the axis range test of the KD-Tree search instead of the plane test
+}
+
+// RecallReport is the recall@k of the approximate search of a collection measured against the exact search
+type RecallReport struct {
+	Recall            float64
+	K                 int
+	Samples           int
+	ApproximateMillis float64 // average time of an approximate search
+	ExactMillis       float64 // average time of an exact search
 }
 
 // ResultSet is the result of a search
@@ -336,6 +558,142 @@ func (u *Util) CosineDistanceNEON(vector1, vector2 *Vector.Vector) (float64, err
 	return float64(C.cosine_distance_neon((*C.double)(unsafe.Pointer(&vector1.Data[0])), (*C.double)(unsafe.Pointer(&vector2.Data[0])), C.int(vector1.Length))), nil
 }
 
+// EuclideanDistances calculates the Euclidean distances of the target to the vectors stored one after another in block
+func (u *Util) EuclideanDistances(target, block, out []float64) {
+	n := len(target)
+	for i := range out {
+		var sum float64
+		for j, value := range block[i*n : (i+1)*n] {
+			diff := value - target[j]
+			sum += diff * diff
+		}
+		out[i] = math.Sqrt(sum)
+	}
+}
+
+// EuclideanDistancesAVX256 calculates the Euclidean distances of the target to the vectors stored one after another in block using AVX256
+func (u *Util) EuclideanDistancesAVX256(target, block, out []float64) {
+	if len(out) == 0 {
+		return
+	}
+	C.euclidean_distances_avx((*C.double)(unsafe.Pointer(&target[0])), (*C.double)(unsafe.Pointer(&block[0])),
+		(*C.double)(unsafe.Pointer(&out[0])), C.int(len(out)), C.int(len(target)))
+}
+
+// EuclideanDistancesNEON calculates the Euclidean distances of the target to the vectors stored one after another in block using ARM/NEON
+func (u *Util) EuclideanDistancesNEON(target, block, out []float64) {
+	if len(out) == 0 {
+		return
+	}
+	C.euclidean_distances_neon((*C.double)(unsafe.Pointer(&target[0])), (*C.double)(unsafe.Pointer(&block[0])),
+		(*C.double)(unsafe.Pointer(&out[0])), C.int(len(out)), C.int(len(target)))
+}
+
+// CosineDistances calculates the Cosine distances of the target to the vectors stored one after another in block
+func (u *Util) CosineDistances(target, block, out []float64) {
+	n := len(target)
+	var norm float64
+	for _, value := range target {
+		norm += value * value
+	}
+	for i := range out {
+		var sum, sum1 float64
+		for j, value := range block[i*n : (i+1)*n] {
+			sum += value * target[j]
+			sum1 += value * value
+		}
+		out[i] = 1 - (sum / (math.Sqrt(sum1) * math.Sqrt(norm)))
+	}
+}
+
+// CosineDistancesAVX256 calculates the Cosine distances of the target to the vectors stored one after another in block using AVX256
+func (u *Util) CosineDistancesAVX256(target, block, out []float64) {
+	if len(out) == 0 {
+		return
+	}
+	C.cosine_distances_avx((*C.double)(unsafe.Pointer(&target[0])), (*C.double)(unsafe.Pointer(&block[0])),
+		(*C.double)(unsafe.Pointer(&out[0])), C.int(len(out)), C.int(len(target)))
+}
+
+// CosineDistancesNEON calculates the Cosine distances of the target to the vectors stored one after another in block using ARM/NEON
+func (u *Util) CosineDistancesNEON(target, block, out []float64) {
+	if len(out) == 0 {
+		return
+	}
+	C.cosine_distances_neon((*C.double)(unsafe.Pointer(&target[0])), (*C.double)(unsafe.Pointer(&block[0])),
+		(*C.double)(unsafe.Pointer(&out[0])), C.int(len(out)), C.int(len(target)))
+}
+
+// EuclideanDistance32 calculates the Euclidean distance between two float32 vectors
+func (u *Util) EuclideanDistance32(vector1, vector2 *Vector.Vector) (float64, error) {
+	var sum float64
+	for i := 0; i < vector1.Length; i++ {
+		diff := float64(vector1.Data32[i] - vector2.Data32[i])
+		sum += diff * diff
+	}
+	return math.Sqrt(sum), nil
+}
+
+// EuclideanDistance32AVX256 calculates the Euclidean distance between two float32 vectors using AVX256
+func (u *Util) EuclideanDistance32AVX256(vector1, vector2 *Vector.Vector) (float64, error) {
+	return float64(C.euclidean_distance_f32_avx((*C.float)(unsafe.Pointer(&vector1.Data32[0])), (*C.float)(unsafe.Pointer(&vector2.Data32[0])), C.int(vector1.Length))), nil
+}
+
+// CosineDistance32 calculates the Cosine distance between two float32 vectors
+func (u *Util) CosineDistance32(vector1, vector2 *Vector.Vector) (float64, error) {
+	var sum, sum1, sum2 float64
+	for i := 0; i < vector1.Length; i++ {
+		a, b := float64(vector1.Data32[i]), float64(vector2.Data32[i])
+		sum += a * b
+		sum1 += a * a
+		sum2 += b * b
+	}
+	return 1 - (sum / (math.Sqrt(sum1) * math.Sqrt(sum2))), nil
+}
+
+// CosineDistance32AVX256 calculates the Cosine distance between two float32 vectors using AVX256
+func (u *Util) CosineDistance32AVX256(vector1, vector2 *Vector.Vector) (float64, error) {
+	return float64(C.cosine_distance_f32_avx((*C.float)(unsafe.Pointer(&vector1.Data32[0])), (*C.float)(unsafe.Pointer(&vector2.Data32[0])), C.int(vector1.Length))), nil
+}
+
+// EuclideanDistanceInt8 calculates the Euclidean distance between a quantized vector and a float32 vector
+func (u *Util) EuclideanDistanceInt8(vector1, vector2 *Vector.Vector) (float64, error) {
+	q := vector1.Quantizer
+	var sum float64
+	for i := 0; i < vector1.Length; i++ {
+		diff := float64(q.Base[i] + q.Scale[i]*float32(vector1.Quant[i]) - vector2.Data32[i])
+		sum += diff * diff
+	}
+	return math.Sqrt(sum), nil
+}
+
+// EuclideanDistanceInt8AVX256 calculates the Euclidean distance between a quantized vector and a float32 vector using AVX256
+func (u *Util) EuclideanDistanceInt8AVX256(vector1, vector2 *Vector.Vector) (float64, error) {
+	q := vector1.Quantizer
+	return float64(C.euclidean_distance_int8_avx((*C.int8_t)(unsafe.Pointer(&vector1.Quant[0])), (*C.float)(unsafe.Pointer(&vector2.Data32[0])),
+		(*C.float)(unsafe.Pointer(&q.Base[0])), (*C.float)(unsafe.Pointer(&q.Scale[0])), C.int(vector1.Length))), nil
+}
+
+// CosineDistanceInt8 calculates the Cosine distance between a quantized vector and a float32 vector
+func (u *Util) CosineDistanceInt8(vector1, vector2 *Vector.Vector) (float64, error) {
+	q := vector1.Quantizer
+	var sum, sum1, sum2 float64
+	for i := 0; i < vector1.Length; i++ {
+		a, b := float64(q.Base[i]+q.Scale[i]*float32(vector1.Quant[i])), float64(vector2.Data32[i])
+		sum += a * b
+		sum1 += a * a
+		sum2 += b * b
+	}
+	return 1 - (sum / (math.Sqrt(sum1) * math.Sqrt(sum2))), nil
+}
+
+// CosineDistanceInt8AVX256 calculates the Cosine distance between a quantized vector and a float32 vector using AVX256
+func (u *Util) CosineDistanceInt8AVX256(vector1, vector2 *Vector.Vector) (float64, error) {
+	q := vector1.Quantizer
+	return float64(C.cosine_distance_int8_avx((*C.int8_t)(unsafe.Pointer(&vector1.Quant[0])), (*C.float)(unsafe.Pointer(&vector2.Data32[0])),
+		(*C.float)(unsafe.Pointer(&q.Base[0])), (*C.float)(unsafe.Pointer(&q.Scale[0])), C.int(vector1.Length))), nil
+}
+
 // FastSqrt is a faster implementation of the Sqrt function
 func (u *Util) FastSqrt(x float64) float64 {
 	i := math.Float64bits(x)
@@ -348,8 +706,8 @@ func (u *Util) FastSqrt(x float64) float64 {
 func (u *Util) GetMaxDimension(vector1, vector2 *Vector.Vector, wg *sync.WaitGroup) {
 	defer wg.Done()
 	for idx := range vector1.Data {
-		if vector2.Data[idx] > vector1.Data[idx] {
-			vector1.Data[idx] = vector2.Data[idx]
+		if vector2.Get(idx) > vector1.Data[idx] {
+			vector1.Data[idx] = vector2.Get(idx)
 		}
 	}
 }
@@ -358,8 +716,8 @@ func (u *Util) GetMaxDimension(vector1, vector2 *Vector.Vector, wg *sync.WaitGro
 func (u *Util) GetMinDimension(vector1, vector2 *Vector.Vector, wg *sync.WaitGroup) {
 	defer wg.Done()
 	for idx := range vector1.Data {
-		if vector2.Data[idx] < vector1.Data[idx] {
-			vector1.Data[idx] = vector2.Data[idx]
+		if vector2.Get(idx) < vector1.Data[idx] {
+			vector1.Data[idx] = vector2.Get(idx)
 		}
 	}
 }
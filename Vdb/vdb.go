@@ -8,6 +8,7 @@ import (
 	"VreeDB/Utils"
 	"VreeDB/Vector"
 	"fmt"
+	"math/rand"
 	"sort"
 	"time"
 )
@@ -37,17 +38,32 @@ func (v *Vdb) InitFileMapper() {
 	FileMapper.Mapper.Start(collections)
 }
 
-// AddCollection creates a new Collection
-func (v *Vdb) AddCollection(name string, vectorDimension int, distanceFunc string) error {
+// AddCollection creates a new Collection that keeps its vectors in memory with the given precision and is searched
+// with the given index type
+func (v *Vdb) AddCollection(name string, vectorDimension int, distanceFunc string, precision Vector.Precision,
+	indexType string, indexParams map[string]int) error {
 	// Check if collection allready exists
 	if _, ok := v.Collections[name]; ok {
 		return fmt.Errorf("Collection with name %s allready exists", name)
 	}
-	v.Collections[name] = Collection.NewCollection(name, vectorDimension, distanceFunc)
+	// Check the index before the collection is created
+	err := Collection.ValidateVectorIndex(indexType, indexParams, vectorDimension, precision)
+	if err != nil {
+		return err
+	}
+	collection, err := Collection.NewCollection(name, vectorDimension, distanceFunc, precision)
+	if err != nil {
+		return err
+	}
+	err = collection.SetVectorIndex(indexType, indexParams)
+	if err != nil {
+		return err
+	}
+	v.Collections[name] = collection
 	// Add the collection to the FileMapper
-	v.Mapper.AddCollection(name)
+	v.Mapper.AddCollection(name, v.Collections[name].FormatVersion)
 	// Write the Collection to the FS
-	err := v.Collections[name].WriteConfig()
+	err = v.Collections[name].WriteConfig()
 	if err != nil {
 		return err
 	}
@@ -60,6 +76,18 @@ func (v *Vdb) DeleteCollection(name string) error {
 	if _, ok := v.Collections[name]; !ok {
 		return fmt.Errorf("Collection with name %s does not exist", name)
 	}
+	// Remove the write-ahead log of the Collection
+	err := v.Collections[name].Wal.Delete()
+	if err != nil {
+		Logger.Log.Log("Error deleting wal: "+err.Error(), "ERROR")
+	}
+	// Remove the vector index of the Collection
+	if v.Collections[name].VectorIndex != nil {
+		err = v.Collections[name].VectorIndex.Delete()
+		if err != nil {
+			Logger.Log.Log("Error deleting vector index: "+err.Error(), "ERROR")
+		}
+	}
 	delete(v.Collections, name)
 	// Delete the Collection from the FileMapper
 	v.Mapper.DelCollection(name)
@@ -81,7 +109,7 @@ func (v *Vdb) DeletePoint(collectionName string, vector []float64) error {
 	// serach the point in the collection
 	novector := false
 	getid := true
-	result := v.Search(collectionName, &Vector.Vector{Data: vector}, Utils.NewHeapControl(1), 0, nil, &novector, &getid)
+	result := v.Search(collectionName, &Vector.Vector{Data: vector, Length: len(vector)}, Utils.NewHeapControl(1), 0, nil, true, Utils.SearchParams{}, &novector, &getid)
 	if len(result) == 0 {
 		return fmt.Errorf("Point with point %v not found in collection %s", vector, collectionName)
 	}
@@ -99,8 +127,11 @@ func (v *Vdb) DeletePoint(collectionName string, vector []float64) error {
 }
 
 // Search searches for the nearest neighbours of the given target vector
+// If rescore is set, collections with a lower precision search more candidates and rescore them in full precision.
+// The params are passed to the vector index of the collection, exact params compare the target to every vector.
+// Filtered searches are planned by Collection.PlanSearch, they return k results if enough vectors pass the filter.
 func (v *Vdb) Search(collectionName string, target *Vector.Vector, queue *Utils.HeapControl, maxDistancePercent float64,
-	filter *[]Filter.Filter, getvector, getid *bool) []*Utils.ResultSet {
+	filter *[]Filter.Filter, rescore bool, params Utils.SearchParams, getvector, getid *bool) []*Utils.ResultSet {
 	v.Collections[collectionName].Mut.RLock()
 	defer v.Collections[collectionName].Mut.RUnlock()
 
@@ -109,21 +140,12 @@ func (v *Vdb) Search(collectionName string, target *Vector.Vector, queue *Utils.
 		return []*Utils.ResultSet{}
 	}
 
-	// Start the Queue Thread
-	queue.StartThreads()
-
-	// Add 1 to the queue waitgroup
-	queue.AddToWaitGroup()
+	// Convert the target to the precision of the collection
+	v.Collections[collectionName].PrepareTarget(target)
 
 	// Get the starting time
 	t := time.Now()
-	su := Utils.NewSearchUnit(filter, 0.1)
-
-	// search
-	su.Search(v.Collections[collectionName].Nodes, target, queue, v.Collections[collectionName].DistanceFunc, v.Collections[collectionName].DimensionDiff)
-
-	// Close the channel and wait for the Queue to finish
-	queue.CloseChannel()
+	k := queue.MaxResults
 
 	// Here we have some time to do some other stuff
 	filterRes := false
@@ -132,31 +154,79 @@ func (v *Vdb) Search(collectionName string, target *Vector.Vector, queue *Utils.
 	}
 
 	// Create the ResultSet
-	results := make([]*Utils.ResultSet, queue.MaxResults)
+	results := make([]*Utils.ResultSet, k)
 
-	// Wait for the Queue to finish
-	queue.Wg.Wait()
+	// Rescoring needs more candidates - the exact search is already in full precision
+	rescore = rescore && !params.Exact && v.Collections[collectionName].Precision != Vector.Float64
+	if rescore {
+		queue.MaxResults *= Collection.RescoreOversampling
+	}
+
+	var data []*Utils.HeapItem
+	if params.Exact {
+		// The exact search compares the target to every vector of the collection
+		data = v.Collections[collectionName].ExactSearch(target.Data, k, filter)
+	} else {
+		// The vector index or the KD-Tree is traversed with the filter
+		traverse := func(filter *[]Filter.Filter) []*Utils.HeapItem {
+			if v.Collections[collectionName].VectorIndex != nil {
+				// Collections with a vector index are searched with it
+				return v.Collections[collectionName].VectorIndex.Search(target, queue.MaxResults, filter, params)
+			}
+
+			// Start the Queue Thread
+			queue.StartThreads()
+
+			// Add 1 to the queue waitgroup
+			queue.AddToWaitGroup()
+
+			su := v.Collections[collectionName].NewSearchUnit(filter, params)
+
+			// search
+			su.Search(v.Collections[collectionName].Nodes, target, queue, v.Collections[collectionName].DistanceFunc, v.Collections[collectionName].DimensionDiff)
+
+			// Close the channel and wait for the Queue to finish
+			queue.CloseChannel()
+			queue.Wg.Wait()
+
+			// Get the nodes from the queue
+			return queue.GetNodes()
+		}
+		if params.Traverse {
+			data = traverse(filter)
+		} else {
+			// The planner chooses between a brute force search of the filtered vectors and a filtered traversal
+			plan := v.Collections[collectionName].PlanSearch(filter)
+			var exact bool
+			data, exact = plan.Search(v.Collections[collectionName], target, k, traverse)
+			// The brute force search is already in full precision
+			rescore = rescore && !exact
+		}
+	}
+
+	// Rescore the candidates in full precision
+	if rescore {
+		data = v.Collections[collectionName].Rescore(data, target, k)
+		queue.MaxResults = k
+	}
 
 	// Print the time it took
 	Logger.Log.Log("Search took: "+time.Since(t).String(), "INFO")
 
-	// Get the nodes from the queue
-	data := queue.GetNodes()
-	dataLen := len(data)
-
 	// If this collection uses euclid and we have a maxDistancePercent > 0 we need to filter the results
 	if filterRes {
 		// If a result is greater than maxDistancePercent * DiagonalLength we remove it
-		for i := 0; i < dataLen; i++ {
+		for i := 0; i < len(data); i++ {
 			if data[i].Distance > maxDistancePercent*v.Collections[collectionName].DiagonalLength {
 				data = append(data[:i], data[i+1:]...)
 				i--
 			}
 		}
 	}
+	dataLen := len(data)
 
 	// only create a new slice if the dataLen is smaller than the MaxResults
-	if dataLen < queue.MaxResults {
+	if dataLen < k {
 		results = make([]*Utils.ResultSet, dataLen)
 	}
 
@@ -170,7 +240,7 @@ func (v *Vdb) Search(collectionName string, target *Vector.Vector, queue *Utils.
 		// if getvector is true we also return the vector
 		var vd *[]float64
 		if *getvector {
-			vd = &data[i].Node.Vector.Data
+			vd = data[i].Node.Vector.GetData()
 		}
 		// if getid is true we also return the id
 		var id string
@@ -187,8 +257,10 @@ func (v *Vdb) Search(collectionName string, target *Vector.Vector, queue *Utils.
 	return results
 }
 
+// IndexSearch searches for the nearest neighbours of the given target vector with the given payload index value.
+// Collections with a vector index and range indexes search with the index value as an additional filter.
 func (v *Vdb) IndexSearch(collectionName string, target *Vector.Vector, queue *Utils.HeapControl, maxDistancePercent float64, filter *[]Filter.Filter,
-	indexName string, indexValue any, getvector, getid *bool) []*Utils.ResultSet {
+	indexName string, indexValue any, rescore bool, params Utils.SearchParams, getvector, getid *bool) []*Utils.ResultSet {
 	v.Collections[collectionName].Mut.RLock()
 	defer v.Collections[collectionName].Mut.RUnlock()
 
@@ -197,21 +269,12 @@ func (v *Vdb) IndexSearch(collectionName string, target *Vector.Vector, queue *U
 		return []*Utils.ResultSet{}
 	}
 
-	// Start the Queue Thread
-	queue.StartThreads()
-
-	// Add 1 to the queue waitgroup
-	queue.AddToWaitGroup()
+	// Convert the target to the precision of the collection
+	v.Collections[collectionName].PrepareTarget(target)
 
 	// Get the starting time
 	t := time.Now()
-	su := Utils.NewSearchUnit(filter, 0.1)
-
-	// search
-	su.Search(v.Collections[collectionName].Indexes[indexName].Entries[indexValue], target, queue, v.Collections[collectionName].DistanceFunc, v.Collections[collectionName].DimensionDiff)
-
-	// Close the channel and wait for the Queue to finish
-	queue.CloseChannel()
+	k := queue.MaxResults
 
 	// Here we have some time to do some other stuff
 	filterRes := false
@@ -220,31 +283,90 @@ func (v *Vdb) IndexSearch(collectionName string, target *Vector.Vector, queue *U
 	}
 
 	// Create the ResultSet
-	results := make([]*Utils.ResultSet, queue.MaxResults)
+	results := make([]*Utils.ResultSet, k)
 
-	// Wait for the Queue to finish
-	queue.Wg.Wait()
+	// Rescoring needs more candidates - the exact search is already in full precision
+	rescore = rescore && !params.Exact && v.Collections[collectionName].Precision != Vector.Float64
+	if rescore {
+		queue.MaxResults *= Collection.RescoreOversampling
+	}
+
+	// The index may have been dropped
+	if _, ok := v.Collections[collectionName].Indexes[indexName]; !ok {
+		return []*Utils.ResultSet{}
+	}
+
+	// The exact search and the vector indexes use the index value as an additional filter
+	indexFilter := []Filter.Filter{{Field: v.Collections[collectionName].Indexes[indexName].Key, Op: Filter.Equal, Value: indexValue}}
+	if filter != nil {
+		indexFilter = append(indexFilter, *filter...)
+	}
+
+	var data []*Utils.HeapItem
+	if params.Exact {
+		// The exact search compares the target to every vector of the collection
+		data = v.Collections[collectionName].ExactSearch(target.Data, k, &indexFilter)
+	} else {
+		// The planner chooses between a brute force search of the filtered vectors and a filtered traversal
+		plan := v.Collections[collectionName].PlanSearch(&indexFilter)
+		var exact bool
+		data, exact = plan.Search(v.Collections[collectionName], target, k, func(planned *[]Filter.Filter) []*Utils.HeapItem {
+			if v.Collections[collectionName].VectorIndex != nil {
+				// Collections with a vector index are searched with it
+				return v.Collections[collectionName].VectorIndex.Search(target, queue.MaxResults, planned, params)
+			}
+
+			// Start the Queue Thread
+			queue.StartThreads()
+
+			// Add 1 to the queue waitgroup
+			queue.AddToWaitGroup()
+
+			// The sub kd tree of the index value only holds vectors with the value, range indexes have no sub kd
+			// trees - the whole tree is searched with the index value as an additional filter
+			node := v.Collections[collectionName].Indexes[indexName].Entries[indexValue]
+			if v.Collections[collectionName].Indexes[indexName].Type == Collection.RangeIndex {
+				node = v.Collections[collectionName].Nodes
+			}
+			su := v.Collections[collectionName].NewSearchUnit(planned, params)
+
+			// search
+			su.Search(node, target, queue, v.Collections[collectionName].DistanceFunc, v.Collections[collectionName].DimensionDiff)
+
+			// Close the channel and wait for the Queue to finish
+			queue.CloseChannel()
+			queue.Wg.Wait()
+
+			// Get the nodes from the queue
+			return queue.GetNodes()
+		})
+		// The brute force search is already in full precision
+		rescore = rescore && !exact
+	}
+
+	// Rescore the candidates in full precision
+	if rescore {
+		data = v.Collections[collectionName].Rescore(data, target, k)
+		queue.MaxResults = k
+	}
 
 	// Print the time it took
 	Logger.Log.Log("Search took: "+time.Since(t).String(), "INFO")
 
-	// Get the nodes from the queue
-	data := queue.GetNodes()
-	dataLen := len(data)
-
 	// If this collection uses euclid and we have a maxDistancePercent > 0 we need to filter the results
 	if filterRes {
 		// If a result is greater than maxDistancePercent * DiagonalLength we remove it
-		for i := 0; i < dataLen; i++ {
+		for i := 0; i < len(data); i++ {
 			if data[i].Distance > maxDistancePercent*v.Collections[collectionName].DiagonalLength {
 				data = append(data[:i], data[i+1:]...)
 				i--
 			}
 		}
 	}
+	dataLen := len(data)
 
 	// only create a new slice if the dataLen is smaller than the MaxResults
-	if dataLen < queue.MaxResults {
+	if dataLen < k {
 		results = make([]*Utils.ResultSet, dataLen)
 	}
 
@@ -258,7 +380,7 @@ func (v *Vdb) IndexSearch(collectionName string, target *Vector.Vector, queue *U
 		// if getvector is true we also return the vector
 		var vd *[]float64
 		if *getvector {
-			vd = &data[i].Node.Vector.Data
+			vd = data[i].Node.Vector.GetData()
 		}
 		// if getid is true we also return the id
 		var id string
@@ -286,3 +408,82 @@ func (v *Vdb) DeleteWithFilter(col string, filters []Filter.Filter) error {
 	}
 	return nil
 }
+
+// Recall measures the recall@k of the approximate search of a collection. Random vectors of the collection are used
+// as queries, the vector itself is left out of both results. The approximate search uses the given rescore and params
+// and always traverses the index - the planner would search small or filtered collections by brute force.
+func (v *Vdb) Recall(collectionName string, k, samples int, filter *[]Filter.Filter, rescore bool, params Utils.SearchParams) (*Utils.RecallReport, error) {
+	c, ok := v.Collections[collectionName]
+	if !ok {
+		return nil, fmt.Errorf("Collection %s does not exist", collectionName)
+	} else if k < 1 || samples < 1 {
+		return nil, fmt.Errorf("k and samples must be at least 1")
+	}
+
+	// Pick the queries
+	c.Mut.RLock()
+	ids := make([]string, 0, len(*c.Space))
+	for id, vector := range *c.Space {
+		if !vector.IsDeleted() {
+			ids = append(ids, id)
+		}
+	}
+	rand.Shuffle(len(ids), func(i, j int) {
+		ids[i], ids[j] = ids[j], ids[i]
+	})
+	ids = ids[:min(samples, len(ids))]
+	queries := make([][]float64, len(ids))
+	for i, id := range ids {
+		queries[i] = append([]float64{}, *(*c.Space)[id].GetData()...)
+	}
+	c.Mut.RUnlock()
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("Collection %s is empty", collectionName)
+	}
+
+	exactParams := params
+	exactParams.Exact = true
+	params.Exact = false
+	params.Traverse = true
+	novector, getid := false, true
+	report := &Utils.RecallReport{K: k, Samples: len(queries)}
+	var hits, total int
+	var approximateTime, exactTime time.Duration
+	for i, query := range queries {
+		t := time.Now()
+		exact := v.Search(collectionName, Vector.NewVector("", query, nil, ""), Utils.NewHeapControl(k+1), 0, filter, false, exactParams, &novector, &getid)
+		exactTime += time.Since(t)
+		t = time.Now()
+		approximate := v.Search(collectionName, Vector.NewVector("", query, nil, ""), Utils.NewHeapControl(k+1), 0, filter, rescore, params, &novector, &getid)
+		approximateTime += time.Since(t)
+
+		// Count the exact neighbours the approximate search found
+		found := make(map[string]bool)
+		for _, result := range withoutId(approximate, ids[i], k) {
+			found[result.Id] = true
+		}
+		for _, result := range withoutId(exact, ids[i], k) {
+			total++
+			if found[result.Id] {
+				hits++
+			}
+		}
+	}
+	if total > 0 {
+		report.Recall = float64(hits) / float64(total)
+	}
+	report.ApproximateMillis = float64(approximateTime.Microseconds()) / 1000 / float64(len(queries))
+	report.ExactMillis = float64(exactTime.Microseconds()) / 1000 / float64(len(queries))
+	return report, nil
+}
+
+// withoutId returns the first k results without the result with the given id
+func withoutId(results []*Utils.ResultSet, id string, k int) []*Utils.ResultSet {
+	filtered := make([]*Utils.ResultSet, 0, k)
+	for _, result := range results {
+		if result != nil && result.Id != id && len(filtered) < k {
+			filtered = append(filtered, result)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,140 @@
+package Vector
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Precision is the precision a Vector keeps its data in memory with - the files hold the full precision, float32
+// for Float32 vectors and float64 for all others
+type Precision uint8
+
+const (
+	// Float64 keeps the data as []float64
+	Float64 Precision = iota
+	// Float32 keeps the data as []float32
+	Float32
+	// Int8 keeps the data scalar quantized as []int8
+	Int8
+)
+
+// ParsePrecision returns the Precision for the given name - an empty name is Float64
+func ParsePrecision(name string) (Precision, error) {
+	switch strings.ToLower(name) {
+	case "", "float64":
+		return Float64, nil
+	case "float32":
+		return Float32, nil
+	case "int8":
+		return Int8, nil
+	}
+	return Float64, fmt.Errorf("Unknown precision %s - use float64, float32 or int8", name)
+}
+
+// String returns the name of the Precision
+func (p Precision) String() string {
+	switch p {
+	case Float32:
+		return "float32"
+	case Int8:
+		return "int8"
+	}
+	return "float64"
+}
+
+// Quantizer maps every dimension from its [min, max] range to the 256 values of an int8.
+// A value is restored as Base + Scale * code.
+type Quantizer struct {
+	Min   []float64
+	Max   []float64
+	Base  []float32
+	Scale []float32
+}
+
+// NewQuantizer returns a Quantizer for the given per dimension ranges
+func NewQuantizer(min, max []float64) *Quantizer {
+	q := &Quantizer{Min: min, Max: max, Base: make([]float32, len(min)), Scale: make([]float32, len(min))}
+	for i := range min {
+		scale := (max[i] - min[i]) / 255
+		q.Scale[i] = float32(scale)
+		q.Base[i] = float32(min[i] + 128*scale)
+	}
+	return q
+}
+
+// Covers returns true if all values are inside the range of the Quantizer
+func (q *Quantizer) Covers(data []float64) bool {
+	for i, v := range data {
+		if v < q.Min[i] || v > q.Max[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Quantize returns the codes of the given data - values outside the range are clamped
+func (q *Quantizer) Quantize(data []float64) []int8 {
+	codes := make([]int8, len(data))
+	for i, v := range data {
+		if q.Scale[i] == 0 {
+			codes[i] = -128
+			continue
+		}
+		code := math.Round((v-q.Min[i])/float64(q.Scale[i])) - 128
+		codes[i] = int8(math.Max(-128, math.Min(127, code)))
+	}
+	return codes
+}
+
+// Value returns the value of the code in dimension i
+func (q *Quantizer) Value(code int8, i int) float64 {
+	return float64(q.Base[i] + q.Scale[i]*float32(code))
+}
+
+// SetData stores the data in the precision of the vector. Int8 vectors need a Quantizer.
+func (v *Vector) SetData(data []float64) {
+	switch {
+	case v.Precision == Float32:
+		v.Data32 = make([]float32, len(data))
+		for i, value := range data {
+			v.Data32[i] = float32(value)
+		}
+		v.Data = nil
+	case v.Precision == Int8 && v.Quantizer != nil:
+		v.Quant = v.Quantizer.Quantize(data)
+		v.Data = nil
+	default:
+		v.Data = data
+	}
+}
+
+// SetCodes sets the quantizer and the codes it quantized the data of the vector to
+func (v *Vector) SetCodes(quantizer *Quantizer, codes []int8) {
+	v.mut.Lock()
+	defer v.mut.Unlock()
+	v.Quantizer = quantizer
+	v.Quant, v.Data = codes, nil
+	v.Indexed = false
+}
+
+// Get returns the value of dimension i, whatever precision the vector is kept in
+func (v *Vector) Get(i int) float64 {
+	switch {
+	case v.Data != nil:
+		return v.Data[i]
+	case v.Data32 != nil:
+		return float64(v.Data32[i])
+	case v.Quant != nil:
+		return v.Quantizer.Value(v.Quant[i], i)
+	}
+	return 0
+}
+
+// ToFloat32 sets Data32 from Data - used for search targets of collections with a lower precision
+func (v *Vector) ToFloat32() {
+	v.Data32 = make([]float32, len(v.Data))
+	for i, value := range v.Data {
+		v.Data32[i] = float32(value)
+	}
+}
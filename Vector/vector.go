@@ -12,8 +12,13 @@ type Vector struct {
 	Id                 string
 	Collection         string
 	Data               []float64
+	Data32             []float32
+	Quant              []int8
+	Quantizer          *Quantizer
+	Precision          Precision
 	Length             int
 	CLength            int
+	PLength            int
 	Payload            *map[string]interface{}
 	DataStart          int64
 	PayloadStart       int64
@@ -36,24 +41,72 @@ func NewVector(id string, data []float64, payload *map[string]interface{}, colle
 	}
 
 	if collection != "" {
-		// This will write the vector to the memory mapped file
-		ds, clen, err := FileMapper.Mapper.WriteVector(data, collection)
-		if err != nil {
-			// if we cannot write to the file we panic
-			panic(err)
-		}
-		// Write the Payload to the memory mapped File
-		ps, err := FileMapper.Mapper.WritePayload(payload, collection)
-		if err != nil {
-			// if we cannot write to the file we panic
-			panic(err)
-		}
-		return &Vector{Id: id, Data: data, Length: len(data), DataStart: ds, Indexed: true, mut: &sync.RWMutex{}, Collection: collection, PayloadStart: ps, CLength: clen, SaveVectorPosition: -1}
+		// The vector will be written to the memory mapped file when it is inserted into the collection
+		return &Vector{Id: id, Data: data, Length: len(data), Payload: payload, Indexed: false, mut: &sync.RWMutex{}, Collection: collection, DataStart: -1, PayloadStart: -1, SaveVectorPosition: -1}
 	} else {
 		return &Vector{Id: id, Data: data, Length: len(data), Payload: payload, Indexed: false, mut: &sync.RWMutex{}, Collection: collection, SaveVectorPosition: -1}
 	}
 }
 
+// Persist writes the data and the payload of the vector to the memory mapped file of its collection.
+// The payload will not be kept in memory afterwards, the data is kept in the precision of the vector.
+func (v *Vector) Persist() error {
+	v.mut.Lock()
+	defer v.mut.Unlock()
+	// This will write the vector to the memory mapped file
+	ds, clen, err := FileMapper.Mapper.WriteVector(v.Data, v.Collection)
+	if err != nil {
+		return err
+	}
+	// Write the Payload to the memory mapped File
+	ps, plen, err := FileMapper.Mapper.WritePayload(v.Payload, v.Collection)
+	if err != nil {
+		return err
+	}
+	v.DataStart, v.CLength, v.PayloadStart, v.PLength = ds, clen, ps, plen
+	v.Payload = nil
+	v.SetData(v.Data)
+	return nil
+}
+
+// PersistBatch writes the data and the payloads of many vectors of the same collection with a single append.
+// The payloads will not be kept in memory afterwards, the data is kept in the precision of the vectors.
+func PersistBatch(vectors []*Vector, collection string) error {
+	arrs := make([][]float64, len(vectors))
+	payloads := make([]*map[string]interface{}, len(vectors))
+	for i, v := range vectors {
+		arrs[i] = v.Data
+		payloads[i] = v.Payload
+	}
+	svs, err := FileMapper.Mapper.WritePoints(arrs, payloads, collection)
+	if err != nil {
+		return err
+	}
+	for i, v := range vectors {
+		v.mut.Lock()
+		v.DataStart, v.CLength, v.PayloadStart, v.PLength = svs[i].DataStart, int(svs[i].DataLength), svs[i].PayloadStart, int(svs[i].PayloadLength)
+		v.Payload = nil
+		v.SetData(v.Data)
+		v.mut.Unlock()
+	}
+	return nil
+}
+
+// PersistPayload writes only the payload of the vector to the memory mapped file of its collection. The data of
+// the vector is the data of the given vector that is already in the file.
+func (v *Vector) PersistPayload(data *Vector) error {
+	v.mut.Lock()
+	defer v.mut.Unlock()
+	ps, plen, err := FileMapper.Mapper.WritePayload(v.Payload, v.Collection)
+	if err != nil {
+		return err
+	}
+	v.DataStart, v.CLength, v.PayloadStart, v.PLength = data.DataStart, data.CLength, ps, plen
+	v.Payload = nil
+	v.SetData(v.Data)
+	return nil
+}
+
 // Unindex will read the data from the file and cache it in the Vector
 func (v *Vector) Unindex() {
 	// Protect the data from being written to while we read it
@@ -63,16 +116,28 @@ func (v *Vector) Unindex() {
 	if v.DataStart < 0 {
 		return
 	}
-	v.Data = *FileMapper.Mapper.ReadVector(v.DataStart, v.Length, v.Collection)
+	v.SetData(*FileMapper.Mapper.ReadVector(v.DataStart, v.Length, v.Collection))
 	v.Indexed = false
 }
 
+// Release drops the data kept in memory - it will be read from the file when it is needed
+func (v *Vector) Release() {
+	v.mut.Lock()
+	defer v.mut.Unlock()
+	if v.DataStart < 0 {
+		return
+	}
+	v.Data, v.Data32, v.Quant = nil, nil, nil
+	v.Indexed = true
+}
+
 // GetData will return the data of the vector
 func (v *Vector) GetData() *[]float64 {
 	// Protect the data from being written to while we read it
 	v.mut.RLock()
 	defer v.mut.RUnlock()
-	if v.Indexed {
+	// Vectors with a lower precision are read from the file in full precision
+	if v.Indexed || (v.Data == nil && v.DataStart >= 0) {
 		return FileMapper.Mapper.ReadVector(v.DataStart, v.Length, v.Collection)
 	}
 	return &v.Data
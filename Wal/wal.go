@@ -0,0 +1,281 @@
+package Wal
+
+import (
+	"VreeDB/ArgsParser"
+	"VreeDB/Logger"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+)
+
+// Operation is the type of mutation stored in a Record
+type Operation uint8
+
+const (
+	// Insert records a new vector together with its data and payload
+	Insert Operation = 1
+	// Delete records the deletion of one or more vectors
+	Delete Operation = 2
+	// Upsert records a vector that replaces the vector with the same ID (if any) together with its data and payload
+	Upsert Operation = 3
+	// Payload records the new payload of an existing vector - the data of the vector stays in place
+	Payload Operation = 4
+)
+
+// headerSize is the size of the frame header: length (uint32), checksum (uint32) and sequence number (uint64)
+const headerSize = 16
+
+// Record is a single mutation in the write-ahead log
+type Record struct {
+	Seq     uint64
+	Op      Operation
+	IDs     []string
+	Data    []float64
+	Payload map[string]interface{}
+}
+
+// Wal is the per collection write-ahead log. Every mutation is appended as a checksummed frame
+// before it is applied to the collection files, so acknowledged writes can be replayed after a crash.
+type Wal struct {
+	Collection string
+	path       string
+	file       *os.File
+	seq        uint64
+	size       int64
+	mut        sync.Mutex
+}
+
+// NewWal opens (or creates) the write-ahead log of the given collection. A torn frame at the end of the
+// log (e.g. because the process was killed while writing) will be truncated.
+func NewWal(collection string) (*Wal, error) {
+	w := &Wal{Collection: collection, path: *ArgsParser.Ap.FileStore + collection + "_wal.bin"}
+
+	// Open the file for reading and writing
+	file, err := os.OpenFile(w.path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		Logger.Log.Log("Error opening wal file: "+err.Error(), "ERROR")
+		return nil, err
+	}
+	w.file = file
+
+	// Find the end of the valid part of the log and the last sequence number
+	end, err := w.scan(nil)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	// Cut off everything after the last valid frame
+	err = file.Truncate(end)
+	if err != nil {
+		file.Close()
+		Logger.Log.Log("Error truncating wal file: "+err.Error(), "ERROR")
+		return nil, err
+	}
+	_, err = file.Seek(end, io.SeekStart)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	w.size = end
+	return w, nil
+}
+
+// Append writes a new Record to the log and returns its sequence number. If walsync is set the
+// log will be synced to disk before Append returns.
+func (w *Wal) Append(op Operation, ids []string, data []float64, payload *map[string]interface{}) (uint64, error) {
+	// Create the record
+	record := &Record{Op: op, IDs: ids, Data: data}
+	if payload != nil {
+		record.Payload = *payload
+	}
+	err := w.AppendBatch([]*Record{record})
+	if err != nil {
+		return 0, err
+	}
+	return record.Seq, nil
+}
+
+// AppendBatch writes the Records to the log with a single write (and a single sync if walsync is set).
+// The sequence numbers are assigned to the Records.
+func (w *Wal) AppendBatch(records []*Record) error {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	// Build the frames
+	var frames bytes.Buffer
+	seq := w.seq
+	for _, record := range records {
+		seq++
+		record.Seq = seq
+		frame, err := encodeFrame(record)
+		if err != nil {
+			return err
+		}
+		frames.Write(frame)
+	}
+
+	// Write the frames
+	_, err := w.file.Write(frames.Bytes())
+	if err != nil {
+		Logger.Log.Log("Error writing wal record: "+err.Error(), "ERROR")
+		// Cut off a possible partial frame so the log stays readable
+		w.file.Truncate(w.size)
+		w.file.Seek(w.size, io.SeekStart)
+		return err
+	}
+
+	// Sync the log to disk
+	if *ArgsParser.Ap.WalSync {
+		err = w.file.Sync()
+		if err != nil {
+			Logger.Log.Log("Error syncing wal file: "+err.Error(), "ERROR")
+			return err
+		}
+	}
+	w.seq = seq
+	w.size += int64(frames.Len())
+	return nil
+}
+
+// encodeFrame encodes the record and builds its frame: length, checksum over seq and body, seq, body
+func encodeFrame(record *Record) ([]byte, error) {
+	// Encode the record - every record gets its own encoder so that it can be decoded on its own
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+	var body bytes.Buffer
+	err := gob.NewEncoder(&body).Encode(record)
+	if err != nil {
+		Logger.Log.Log("Error encoding wal record: "+err.Error(), "ERROR")
+		return nil, err
+	}
+
+	frame := make([]byte, headerSize+body.Len())
+	binary.LittleEndian.PutUint32(frame[0:4], uint32(body.Len()))
+	binary.LittleEndian.PutUint64(frame[8:16], record.Seq)
+	copy(frame[headerSize:], body.Bytes())
+	binary.LittleEndian.PutUint32(frame[4:8], crc32.ChecksumIEEE(frame[8:]))
+	return frame, nil
+}
+
+// Replay calls fn for every valid Record in the log, in the order they were written
+func (w *Wal) Replay(fn func(*Record) error) error {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	_, err := w.scan(fn)
+	return err
+}
+
+// Checkpoint truncates the log. It must only be called when all records are applied to the collection
+// files and these files are synced to disk.
+func (w *Wal) Checkpoint() error {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	err := w.file.Truncate(0)
+	if err != nil {
+		Logger.Log.Log("Error truncating wal file: "+err.Error(), "ERROR")
+		return err
+	}
+	_, err = w.file.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	w.size = 0
+	return w.file.Sync()
+}
+
+// Size returns the size of the log in bytes
+func (w *Wal) Size() int64 {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	return w.size
+}
+
+// Close closes the log file
+func (w *Wal) Close() error {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	return w.file.Close()
+}
+
+// Delete closes and removes the log file
+func (w *Wal) Delete() error {
+	err := w.Close()
+	if err != nil {
+		return err
+	}
+	return os.Remove(w.path)
+}
+
+// scan reads all frames from the start of the file and returns the offset after the last valid frame.
+// Reading stops at the first frame that is incomplete, has a wrong checksum or a non increasing sequence number.
+// If fn is not nil it will be called for every valid Record.
+func (w *Wal) scan(fn func(*Record) error) (int64, error) {
+	_, err := w.file.Seek(0, io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+	// Seek back to the end of the log when we are done
+	defer w.file.Seek(0, io.SeekEnd)
+
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+
+	// The size of the file is needed to detect torn frames
+	info, err := w.file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	var offset int64
+	var last uint64
+	header := make([]byte, headerSize)
+	for {
+		// Read the header
+		if _, err := io.ReadFull(w.file, header); err != nil {
+			break
+		}
+		length := binary.LittleEndian.Uint32(header[0:4])
+		checksum := binary.LittleEndian.Uint32(header[4:8])
+		seq := binary.LittleEndian.Uint64(header[8:16])
+		if offset+int64(headerSize)+int64(length) > info.Size() {
+			break
+		}
+
+		// Read the body
+		frame := make([]byte, 8+int(length))
+		copy(frame, header[8:16])
+		if _, err := io.ReadFull(w.file, frame[8:]); err != nil {
+			break
+		}
+
+		// Validate the frame
+		if crc32.ChecksumIEEE(frame) != checksum || seq <= last {
+			Logger.Log.Log(fmt.Sprintf("Invalid wal frame at offset %d in collection %s - ignoring the rest of the log", offset, w.Collection), "WARNING")
+			break
+		}
+
+		// Decode the record
+		record := &Record{}
+		if err := gob.NewDecoder(bytes.NewReader(frame[8:])).Decode(record); err != nil {
+			Logger.Log.Log("Error decoding wal record: "+err.Error(), "WARNING")
+			break
+		}
+		if fn != nil {
+			if err := fn(record); err != nil {
+				return offset, err
+			}
+		}
+		last = seq
+		offset += int64(headerSize) + int64(length)
+	}
+	if last > w.seq {
+		w.seq = last
+	}
+	return offset, nil
+}